@@ -0,0 +1,252 @@
+// Command geobench measures radius queries on generated or seeded locations.
+//
+// By default it generates -points locations, clustered around cities
+// (including ones near the antimeridian and the poles) with some spread over
+// the globe, and compares a full haversine scan with the geohash cover of
+// geo.Within over the same points sorted by geohash: candidates examined,
+// time per query, and that both find the same locations.
+//
+// With -db it runs the same comparison in SQL against user_locations, using
+// DB_STRING like the server; seed it first with go run ./cmd/seed -users
+// 100000.
+//
+//	go run ./cmd/geobench -points 100000 -queries 500
+//	go run ./cmd/geobench -db -queries 100
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"matcha/database"
+	"matcha/geo"
+)
+
+// radii are the radiuses benchmarked, in km.
+var radii = []float64{5, 25, 100, 500, 2000}
+
+// cities are the cluster centers of generated points.
+var cities = []geo.Point{
+	{Lat: 48.8566, Lon: 2.3522},    // Paris
+	{Lat: 45.7640, Lon: 4.8357},    // Lyon
+	{Lat: 40.7128, Lon: -74.0060},  // New York
+	{Lat: -36.8485, Lon: 174.7633}, // Auckland
+	{Lat: -18.1416, Lon: 178.4419}, // Suva, by the antimeridian
+	{Lat: 64.7333, Lon: -177.5000}, // Anadyr, across it
+	{Lat: 78.2232, Lon: 15.6267},   // Longyearbyen
+	{Lat: -77.8460, Lon: 166.6760}, // McMurdo
+}
+
+type indexedPoint struct {
+	hash  string
+	point geo.Point
+}
+
+type result struct {
+	queries    int
+	found      int
+	scanned    int
+	candidates int
+	scan       time.Duration
+	indexed    time.Duration
+}
+
+func main() {
+	points := flag.Int("points", 100000, "number of generated locations")
+	queries := flag.Int("queries", 500, "queries per radius")
+	seed := flag.Int64("seed", 42, "random seed")
+	useDB := flag.Bool("db", false, "query user_locations instead of generated points")
+	flag.Parse()
+
+	rng := rand.New(rand.NewSource(*seed))
+	var results map[float64]*result
+	if *useDB {
+		results = benchDB(rng, *queries)
+	} else {
+		results = benchMemory(rng, *points, *queries)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "RADIUS\tFOUND/QUERY\tSCANNED/QUERY\tCANDIDATES/QUERY\tSCAN\tINDEXED\tSPEEDUP")
+	for _, radius := range radii {
+		r := results[radius]
+		if r == nil || r.queries == 0 {
+			continue
+		}
+		n := float64(r.queries)
+		// The database does not say how many rows the index let through.
+		candidates := "-"
+		if !*useDB {
+			candidates = fmt.Sprintf("%.1f", float64(r.candidates)/n)
+		}
+		fmt.Fprintf(w, "%gkm\t%.1f\t%.0f\t%s\t%s\t%s\t%.1fx\n", radius,
+			float64(r.found)/n, float64(r.scanned)/n, candidates,
+			r.scan/time.Duration(r.queries), r.indexed/time.Duration(r.queries),
+			float64(r.scan)/float64(max(r.indexed, 1)))
+	}
+	w.Flush()
+}
+
+// generate returns n points, nine in ten around a city.
+func generate(rng *rand.Rand, n int) []geo.Point {
+	points := make([]geo.Point, n)
+	for i := range points {
+		if rng.Intn(10) == 0 {
+			points[i] = geo.Point{Lat: rng.Float64()*180 - 90, Lon: rng.Float64()*360 - 180}
+			continue
+		}
+		city := cities[rng.Intn(len(cities))]
+		points[i] = wrap(geo.Point{Lat: city.Lat + rng.NormFloat64()*0.5, Lon: city.Lon + rng.NormFloat64()*0.8})
+	}
+	return points
+}
+
+// wrap brings a point back into [-90, 90] and [-180, 180].
+func wrap(p geo.Point) geo.Point {
+	if p.Lat > 90 {
+		p.Lat, p.Lon = 180-p.Lat, p.Lon+180
+	} else if p.Lat < -90 {
+		p.Lat, p.Lon = -180-p.Lat, p.Lon+180
+	}
+	for p.Lon > 180 {
+		p.Lon -= 360
+	}
+	for p.Lon < -180 {
+		p.Lon += 360
+	}
+	return p
+}
+
+func benchMemory(rng *rand.Rand, n, queries int) map[float64]*result {
+	points := generate(rng, n)
+	indexed := make([]indexedPoint, n)
+	for i, p := range points {
+		indexed[i] = indexedPoint{hash: geo.Encode(p, geo.HashPrecision), point: p}
+	}
+	sort.Slice(indexed, func(i, j int) bool { return indexed[i].hash < indexed[j].hash })
+
+	results := make(map[float64]*result, len(radii))
+	for _, radius := range radii {
+		r := &result{}
+		results[radius] = r
+		for q := 0; q < queries; q++ {
+			center := points[rng.Intn(n)]
+
+			start := time.Now()
+			scanFound := 0
+			for _, p := range points {
+				if geo.DistanceKm(center, p) <= radius {
+					scanFound++
+				}
+			}
+			r.scan += time.Since(start)
+			r.scanned += n
+
+			start = time.Now()
+			found := 0
+			candidates := 0
+			check := func(from, to int) {
+				for _, p := range indexed[from:to] {
+					candidates++
+					if geo.DistanceKm(center, p.point) <= radius {
+						found++
+					}
+				}
+			}
+			if cover := geo.Cover(center, radius); cover == nil {
+				check(0, n)
+			} else {
+				for _, hashRange := range geo.Ranges(cover) {
+					from := sort.Search(n, func(i int) bool { return indexed[i].hash >= hashRange.From })
+					to := n
+					if hashRange.To != "" {
+						to = sort.Search(n, func(i int) bool { return indexed[i].hash >= hashRange.To })
+					}
+					check(from, to)
+				}
+			}
+			r.indexed += time.Since(start)
+
+			if found != scanFound {
+				log.Fatalf("Radius %gkm around %v: the scan finds %d points, the geohash cover %d", radius, center, scanFound, found)
+			}
+			r.queries++
+			r.found += found
+			r.candidates += candidates
+		}
+	}
+	return results
+}
+
+func benchDB(rng *rand.Rand, queries int) map[float64]*result {
+	db := database.DbConnect()
+	defer db.Close()
+	ctx := context.Background()
+
+	index, err := geo.DetectIndex(ctx, db)
+	if err != nil {
+		log.Fatalf("Error detecting the spatial index: %v", err)
+	}
+	var total int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM user_locations").Scan(&total); err != nil {
+		log.Fatalf("Error counting locations: %v", err)
+	}
+	if total == 0 {
+		log.Fatal("user_locations is empty; seed it with go run ./cmd/seed -users 100000")
+	}
+	log.Printf("%d locations, %s index", total, index)
+
+	rows, err := db.QueryContext(ctx, "SELECT lat, lon FROM user_locations ORDER BY md5(user_id::text || $1) LIMIT $2",
+		fmt.Sprint(rng.Int63()), queries)
+	if err != nil {
+		log.Fatalf("Error picking query centers: %v", err)
+	}
+	centers := []geo.Point{}
+	for rows.Next() {
+		var p geo.Point
+		if err := rows.Scan(&p.Lat, &p.Lon); err != nil {
+			log.Fatalf("Error picking query centers: %v", err)
+		}
+		centers = append(centers, p)
+	}
+	rows.Close()
+
+	results := make(map[float64]*result, len(radii))
+	for _, radius := range radii {
+		r := &result{}
+		results[radius] = r
+		for _, center := range centers {
+			var scanFound, found int
+
+			start := time.Now()
+			err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM user_locations l WHERE haversine_km($1, $2, l.public_lat, l.public_lon) <= $3",
+				center.Lat, center.Lon, radius).Scan(&scanFound)
+			if err != nil {
+				log.Fatalf("Error scanning locations: %v", err)
+			}
+			r.scan += time.Since(start)
+			r.scanned += total
+
+			within, args := index.Within("l", center, radius, nil)
+			start = time.Now()
+			if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM user_locations l WHERE "+within, args...).Scan(&found); err != nil {
+				log.Fatalf("Error querying locations: %v", err)
+			}
+			r.indexed += time.Since(start)
+
+			if found != scanFound {
+				log.Fatalf("Radius %gkm around %v: the scan finds %d locations, geo.Within %d", radius, center, scanFound, found)
+			}
+			r.queries++
+			r.found += found
+		}
+	}
+	return results
+}
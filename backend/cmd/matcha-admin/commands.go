@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"matcha/store"
+	"matcha/utils"
+)
+
+func runUser(ctx context.Context, a *app, args []string) error {
+	rest, err := parseArgs(flag.NewFlagSet("user", flag.ContinueOnError), args, 1, 1)
+	if err != nil {
+		return err
+	}
+	user, err := a.findUser(ctx, rest[0])
+	if err != nil {
+		return err
+	}
+	return a.print(user, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "ID\t%d\n", user.ID)
+		fmt.Fprintf(w, "Username\t%s\n", user.Username)
+		fmt.Fprintf(w, "Email\t%s\n", user.Email)
+		fmt.Fprintf(w, "Name\t%s %s\n", user.FirstName, user.LastName)
+		fmt.Fprintf(w, "Verified\t%t\n", user.Verified)
+		fmt.Fprintf(w, "Logged in\t%t\n", user.HasSession)
+		fmt.Fprintf(w, "Fame rating\t%.2f\n", user.FameRating)
+		fmt.Fprintf(w, "Created\t%s\n", formatTime(&user.CreatedAt))
+		fmt.Fprintf(w, "Last seen\t%s\n", formatTime(user.LastSeen))
+		fmt.Fprintf(w, "Banned\t%s\n", formatTime(user.BannedAt))
+		if user.BanReason != nil {
+			fmt.Fprintf(w, "Ban reason\t%s\n", *user.BanReason)
+		}
+		fmt.Fprintf(w, "Admin\t%t\n", user.IsAdmin)
+	})
+}
+
+// userAction resolves the single user argument of a command, applies fn and
+// prints the outcome.
+func userAction(ctx context.Context, a *app, fs *flag.FlagSet, args []string, action string, fn func(user *store.User) (string, error)) error {
+	rest, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	user, err := a.findUser(ctx, rest[0])
+	if err != nil {
+		return err
+	}
+	detail, err := fn(user)
+	if err != nil {
+		return err
+	}
+	return a.printAction(actionResult{Action: action, UserID: user.ID, Detail: detail})
+}
+
+func runVerify(ctx context.Context, a *app, args []string) error {
+	return userAction(ctx, a, flag.NewFlagSet("verify", flag.ContinueOnError), args, "verified", func(user *store.User) (string, error) {
+		return user.Username, a.store.VerifyUser(ctx, user.ID)
+	})
+}
+
+func runResetPassword(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("reset-password", flag.ContinueOnError)
+	password := fs.String("password", "", "new password; a random one is generated and printed when empty")
+	return userAction(ctx, a, fs, args, "password reset", func(user *store.User) (string, error) {
+		newPassword := *password
+		generated := newPassword == ""
+		if generated {
+			// The suffix guarantees the upper, lower and digit characters
+			// ValidatePassword requires whatever the random part contains.
+			newPassword = utils.GenerateToken()[:20] + "Aa9"
+		}
+		if err := utils.ValidatePassword(newPassword); err != nil {
+			return "", err
+		}
+		hash, err := utils.HashPassword(newPassword)
+		if err != nil {
+			return "", err
+		}
+		if err := a.store.SetPasswordHash(ctx, user.ID, hash); err != nil {
+			return "", err
+		}
+		if generated {
+			return "new password " + newPassword, nil
+		}
+		return user.Username, nil
+	})
+}
+
+func runRevokeSessions(ctx context.Context, a *app, args []string) error {
+	return userAction(ctx, a, flag.NewFlagSet("revoke-sessions", flag.ContinueOnError), args, "sessions revoked", func(user *store.User) (string, error) {
+		return user.Username, a.store.RevokeSessions(ctx, user.ID)
+	})
+}
+
+func runBan(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("ban", flag.ContinueOnError)
+	reason := fs.String("reason", "", "reason recorded with the ban")
+	return userAction(ctx, a, fs, args, "banned", func(user *store.User) (string, error) {
+		return user.Username, a.store.BanUser(ctx, user.ID, *reason)
+	})
+}
+
+func runUnban(ctx context.Context, a *app, args []string) error {
+	return userAction(ctx, a, flag.NewFlagSet("unban", flag.ContinueOnError), args, "unbanned", func(user *store.User) (string, error) {
+		return user.Username, a.store.UnbanUser(ctx, user.ID)
+	})
+}
+
+func runGrantAdmin(ctx context.Context, a *app, args []string) error {
+	return userAction(ctx, a, flag.NewFlagSet("grant-admin", flag.ContinueOnError), args, "admin granted", func(user *store.User) (string, error) {
+		return user.Username, a.store.SetAdmin(ctx, user.ID, true)
+	})
+}
+
+func runRevokeAdmin(ctx context.Context, a *app, args []string) error {
+	return userAction(ctx, a, flag.NewFlagSet("revoke-admin", flag.ContinueOnError), args, "admin revoked", func(user *store.User) (string, error) {
+		return user.Username, a.store.SetAdmin(ctx, user.ID, false)
+	})
+}
+
+func runReports(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("reports", flag.ContinueOnError)
+	all := fs.Bool("all", false, "include resolved reports")
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+	reports, err := a.store.ListReports(ctx, *all)
+	if err != nil {
+		return err
+	}
+	return a.print(reports, func(w *tabwriter.Writer) {
+		fmt.Fprintln(w, "ID\tCREATED\tREPORTER\tREPORTED\tREASON\tRESOLVED\tRESOLUTION")
+		for _, r := range reports {
+			resolution := "-"
+			if r.Resolution != nil {
+				resolution = *r.Resolution
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s (%d)\t%s (%d)\t%s\t%s\t%s\n",
+				r.ID, formatTime(&r.CreatedAt), r.ReporterUsername, r.ReporterID, r.ReportedUsername, r.ReportedID,
+				r.Reason, formatTime(r.ResolvedAt), resolution)
+		}
+	})
+}
+
+func runResolveReport(ctx context.Context, a *app, args []string) error {
+	rest, err := parseArgs(flag.NewFlagSet("resolve-report", flag.ContinueOnError), args, 2, -1)
+	if err != nil {
+		return err
+	}
+	reportID, err := strconv.Atoi(rest[0])
+	if err != nil {
+		return fmt.Errorf("invalid report ID %q", rest[0])
+	}
+	resolution := joinArgs(rest[1:])
+	if err := a.store.ResolveReport(ctx, reportID, resolution); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return fmt.Errorf("no report with ID %d", reportID)
+		}
+		return err
+	}
+	return a.printAction(actionResult{Action: fmt.Sprintf("report %d resolved", reportID), Detail: resolution})
+}
+
+func runRecomputeFame(ctx context.Context, a *app, args []string) error {
+	if _, err := parseArgs(flag.NewFlagSet("recompute-fame", flag.ContinueOnError), args, 0, 0); err != nil {
+		return err
+	}
+	count, err := a.store.RecomputeFameRatings(ctx)
+	if err != nil {
+		return err
+	}
+	return a.printAction(actionResult{Action: "fame ratings recomputed", Detail: fmt.Sprintf("%d users", count)})
+}
+
+func runRecomputeRecommendations(ctx context.Context, a *app, args []string) error {
+	if _, err := parseArgs(flag.NewFlagSet("recompute-recommendations", flag.ContinueOnError), args, 0, 0); err != nil {
+		return err
+	}
+	count, err := a.store.RecomputeRecommendations(ctx)
+	if err != nil {
+		return err
+	}
+	return a.printAction(actionResult{Action: "recommendations recomputed", Detail: fmt.Sprintf("%d users", count)})
+}
+
+func runPurgeTags(ctx context.Context, a *app, args []string) error {
+	if _, err := parseArgs(flag.NewFlagSet("purge-tags", flag.ContinueOnError), args, 0, 0); err != nil {
+		return err
+	}
+	count, err := a.store.DeleteUnusedTags(ctx)
+	if err != nil {
+		return err
+	}
+	return a.printAction(actionResult{Action: "tags purged", Detail: fmt.Sprintf("%d tags", count)})
+}
+
+func runMergeTag(ctx context.Context, a *app, args []string) error {
+	rest, err := parseArgs(flag.NewFlagSet("merge-tag", flag.ContinueOnError), args, 2, 2)
+	if err != nil {
+		return err
+	}
+	// Names are stored lower-cased without the leading '#'.
+	for i := range rest {
+		rest[i] = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(rest[i]), "#"))
+	}
+	target, moved, err := a.store.MergeTag(ctx, rest[0], rest[1])
+	if err != nil {
+		return err
+	}
+	return a.printAction(actionResult{Action: "tag merged", Detail: fmt.Sprintf("%s -> %s, %d users moved", rest[0], target, moved)})
+}
+
+func runPurgeUploads(ctx context.Context, a *app, args []string) error {
+	fs := flag.NewFlagSet("purge-uploads", flag.ContinueOnError)
+	dir := fs.String("dir", "./uploads", "uploads directory served by the backend")
+	dryRun := fs.Bool("dry-run", false, "only list the files that would be deleted")
+	minAge := fs.Duration("min-age", time.Hour, "skip files modified more recently, which may belong to an upload in progress")
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return err
+	}
+
+	var files []string
+	var err error
+	if *dryRun {
+		files, err = a.store.OrphanedUploads(ctx, *dir, *minAge)
+	} else {
+		files, err = a.store.PurgeOrphanedUploads(ctx, *dir, *minAge)
+	}
+	if err != nil {
+		return err
+	}
+
+	result := struct {
+		DryRun bool     `json:"dry_run"`
+		Files  []string `json:"files"`
+	}{*dryRun, files}
+	return a.print(result, func(w *tabwriter.Writer) {
+		verb := "deleted"
+		if *dryRun {
+			verb = "would delete"
+		}
+		for _, file := range files {
+			fmt.Fprintf(w, "%s %s\n", verb, file)
+		}
+		fmt.Fprintf(w, "%d files %s\n", len(files), verb)
+	})
+}
@@ -0,0 +1,162 @@
+// Command matcha-admin runs support operations against the Matcha database.
+//
+// Usage:
+//
+//	matcha-admin [-json] <command> [flags] [args]
+//
+// It connects with DB_STRING like the server. Run without arguments for the
+// list of commands.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"matcha/database"
+	"matcha/store"
+)
+
+type command struct {
+	usage string
+	help  string
+	run   func(ctx context.Context, app *app, args []string) error
+}
+
+var commands = map[string]command{
+	"user":                      {"user <id|username|email>", "Show account details", runUser},
+	"verify":                    {"verify <user>", "Mark the user's email as verified", runVerify},
+	"reset-password":            {"reset-password [-password P] <user>", "Set a new password (generated when omitted) and log the user out", runResetPassword},
+	"revoke-sessions":           {"revoke-sessions <user>", "Log the user out", runRevokeSessions},
+	"reports":                   {"reports [-all]", "List open reports (-all includes resolved ones)", runReports},
+	"resolve-report":            {"resolve-report <report-id> <resolution...>", "Close a report", runResolveReport},
+	"ban":                       {"ban [-reason R] <user>", "Ban a user and end their session", runBan},
+	"unban":                     {"unban <user>", "Lift a ban", runUnban},
+	"recompute-fame":            {"recompute-fame", "Recalculate every fame rating now", runRecomputeFame},
+	"recompute-recommendations": {"recompute-recommendations", "Rebuild the collaborative-filtering recommendations from likes", runRecomputeRecommendations},
+	"purge-uploads":             {"purge-uploads [-dir D] [-min-age 1h] [-dry-run]", "Delete uploaded files no image or avatar references", runPurgeUploads},
+	"purge-tags":                {"purge-tags", "Delete the tags no user has", runPurgeTags},
+	"merge-tag":                 {"merge-tag <source> <target>", "Make source an alias of target, moving its users to target", runMergeTag},
+	"grant-admin":               {"grant-admin <user>", "Give the user access to the /admin API", runGrantAdmin},
+	"revoke-admin":              {"revoke-admin <user>", "Remove the user's access to the /admin API", runRevokeAdmin},
+}
+
+type app struct {
+	store *store.Store
+	json  bool
+}
+
+func main() {
+	jsonOutput := flag.Bool("json", false, "print results as JSON")
+	timeout := flag.Duration("timeout", 30*time.Second, "deadline for the whole command")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	db := database.DbConnect()
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	a := &app{store: store.New(db), json: *jsonOutput}
+	if err := cmd.run(ctx, a, flag.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: matcha-admin [-json] [-timeout 30s] <command> [args]\n\nCommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w := tabwriter.NewWriter(os.Stderr, 0, 4, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\t%s\n", commands[name].usage, commands[name].help)
+	}
+	w.Flush()
+}
+
+// parseArgs parses the command's own flags and checks the number of
+// positional arguments left; max < 0 means no upper bound.
+func parseArgs(fs *flag.FlagSet, args []string, min, max int) ([]string, error) {
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	rest := fs.Args()
+	if len(rest) < min || (max >= 0 && len(rest) > max) {
+		return nil, errors.New("wrong number of arguments")
+	}
+	return rest, nil
+}
+
+func (a *app) findUser(ctx context.Context, ref string) (*store.User, error) {
+	user, err := a.store.FindUser(ctx, ref)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, fmt.Errorf("no user matches %q", ref)
+	}
+	return user, err
+}
+
+// print writes value as JSON, or calls text to write the human readable form.
+func (a *app) print(value interface{}, text func(w *tabwriter.Writer)) error {
+	if a.json {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	text(w)
+	return w.Flush()
+}
+
+type actionResult struct {
+	Action string `json:"action"`
+	UserID int    `json:"user_id,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (a *app) printAction(result actionResult) error {
+	return a.print(result, func(w *tabwriter.Writer) {
+		fmt.Fprintf(w, "%s", result.Action)
+		if result.UserID != 0 {
+			fmt.Fprintf(w, " (user %d)", result.UserID)
+		}
+		if result.Detail != "" {
+			fmt.Fprintf(w, ": %s", result.Detail)
+		}
+		fmt.Fprintln(w)
+	})
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func joinArgs(args []string) string {
+	return strings.TrimSpace(strings.Join(args, " "))
+}
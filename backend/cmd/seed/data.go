@@ -0,0 +1,98 @@
+package main
+
+var tagNames = []string{
+	"travel", "cooking", "music", "sports", "reading", "movies", "photography",
+	"gaming", "yoga", "hiking", "dancing", "art", "coding", "fitness", "fashion",
+	"food", "nature", "pets", "running", "cycling", "swimming", "coffee", "wine",
+	"beach", "mountains", "cinema", "series", "concerts", "festivals", "guitar",
+	"piano", "singing", "painting", "drawing", "writing", "poetry", "science",
+	"history", "politics", "philosophy", "psychology", "meditation", "volunteering",
+	"languages", "surfing", "skiing", "climbing", "camping", "fishing", "gardening",
+}
+
+type city struct {
+	name     string
+	lat, lon float64
+}
+
+var cities = []city{
+	{"Paris", 48.8566, 2.3522},
+	{"Lyon", 45.7640, 4.8357},
+	{"Marseille", 43.2965, 5.3698},
+	{"Toulouse", 43.6047, 1.4442},
+	{"Nice", 43.7102, 7.2620},
+	{"Nantes", 47.2184, -1.5536},
+	{"Bordeaux", 44.8378, -0.5792},
+	{"Lille", 50.6292, 3.0573},
+	{"Rennes", 48.1173, -1.6778},
+	{"Strasbourg", 48.5734, 7.7521},
+	{"Montpellier", 43.6108, 3.8767},
+	{"Grenoble", 45.1885, 5.7245},
+	{"Dijon", 47.3220, 5.0415},
+	{"Angers", 47.4784, -0.5632},
+	{"Clermont-Ferrand", 45.7772, 3.0870},
+}
+
+var maleFirstNames = []string{
+	"Lucas", "Hugo", "Louis", "Gabriel", "Arthur", "Jules", "Adam", "Raphaël",
+	"Léo", "Nathan", "Théo", "Tom", "Noah", "Maxime", "Antoine", "Paul",
+	"Julien", "Thomas", "Nicolas", "Alexandre", "Mathis", "Enzo", "Clément", "Victor",
+	"James", "Daniel", "Michael", "David", "Samuel", "Ethan",
+}
+
+var femaleFirstNames = []string{
+	"Emma", "Jade", "Louise", "Alice", "Chloé", "Léa", "Manon", "Inès",
+	"Camille", "Sarah", "Juliette", "Zoé", "Lina", "Clara", "Anna", "Élodie",
+	"Margaux", "Lucie", "Pauline", "Mathilde", "Océane", "Laura", "Charlotte", "Eva",
+	"Emily", "Olivia", "Sophie", "Grace", "Hannah", "Mia",
+}
+
+var lastNames = []string{
+	"Martin", "Bernard", "Dubois", "Thomas", "Robert", "Richard", "Petit", "Durand",
+	"Leroy", "Moreau", "Simon", "Laurent", "Lefèvre", "Michel", "Garcia", "David",
+	"Bertrand", "Roux", "Vincent", "Fournier", "Morel", "Girard", "André", "Mercier",
+	"Dupont", "Lambert", "Bonnet", "François", "Martinez", "Legrand", "Garnier", "Faure",
+	"Smith", "Johnson", "Brown", "Wilson", "Taylor", "Clarke",
+}
+
+// interestSets are the genders a generated user is interested in.
+var interestSets = [][]string{{"Man"}, {"Woman"}, {"Man", "Woman"}}
+
+// Bios are built from one opener and one or two details.
+var bioOpeners = []string{
+	"Toujours partant·e pour une nouvelle aventure.",
+	"Curieux·se de nature, j'aime découvrir de nouveaux endroits.",
+	"Fan de brunchs le dimanche et de longues balades.",
+	"Je cherche quelqu'un avec qui partager de bons moments.",
+	"Passionné·e par ce que je fais, et par les gens que je rencontre.",
+	"Always up for a good conversation and a better coffee.",
+	"Weekend explorer, weekday planner.",
+}
+
+var bioDetails = []string{
+	"Mon dernier coup de cœur : un petit resto caché près de chez moi.",
+	"Je cuisine mieux que je ne chante, promis.",
+	"Un bon livre et un plaid, et la soirée est parfaite.",
+	"Je rêve de faire le tour du monde en train.",
+	"Les concerts en plein air, c'est ma passion.",
+	"Je ne dis jamais non à une partie de jeux de société.",
+	"Lève-tôt pour aller courir, couche-tard pour les séries.",
+	"I can talk about space documentaries for hours.",
+	"Looking for someone who laughs at my bad puns.",
+	"Dog person, but cats are fine too.",
+}
+
+var chatLines = []string{
+	"Salut ! Ton profil m'a bien fait sourire 😊",
+	"Hello ! Comment se passe ta semaine ?",
+	"Coucou, tu fais quoi de beau ce week-end ?",
+	"J'ai vu que tu aimais voyager, c'est quoi ton meilleur souvenir ?",
+	"Haha, pareil pour moi !",
+	"Ça a l'air génial, raconte !",
+	"Tu connais un bon café dans le coin ?",
+	"On pourrait se retrouver pour un verre un de ces jours ?",
+	"Avec plaisir ! Jeudi soir, ça te va ?",
+	"Parfait, à jeudi alors 🙂",
+	"Hi! How's your day going?",
+	"That sounds like a lot of fun.",
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// seedUser is everything inserted for one generated account, decided before
+// touching the database so the same seed always yields the same data.
+type seedUser struct {
+	username     string
+	email        string
+	firstName    string
+	lastName     string
+	gender       string
+	interestedIn []string
+	birthday     time.Time
+	bio          string
+	lat, lon     float64
+	tags         []string
+	avatarURL    string
+}
+
+type seedView struct {
+	viewer, viewed int
+	at             time.Time
+}
+
+type seedLike struct {
+	liker, liked int
+	at           time.Time
+}
+
+type seedMessage struct {
+	sender, receiver int
+	content          string
+	at               time.Time
+}
+
+// seedActivity references users by their index in the generated slice.
+type seedActivity struct {
+	views    []seedView
+	likes    []seedLike
+	messages []seedMessage
+}
+
+type generator struct {
+	rng    *rand.Rand
+	now    time.Time
+	domain string
+}
+
+func newGenerator(seed int64, now time.Time, domain string) *generator {
+	return &generator{rng: rand.New(rand.NewSource(seed)), now: now, domain: domain}
+}
+
+func (g *generator) pick(values []string) string {
+	return values[g.rng.Intn(len(values))]
+}
+
+// between returns a random integer in [min, max].
+func (g *generator) between(min, max int) int {
+	return min + g.rng.Intn(max-min+1)
+}
+
+func (g *generator) daysAgo(maxDays int) time.Time {
+	return g.now.Add(-time.Duration(g.rng.Int63n(int64(maxDays) * int64(24*time.Hour))))
+}
+
+func (g *generator) users(count int) []seedUser {
+	users := make([]seedUser, count)
+	for i := range users {
+		users[i] = g.user(i + 1)
+	}
+	return users
+}
+
+func (g *generator) user(index int) seedUser {
+	gender := "Man"
+	firstName := g.pick(maleFirstNames)
+	if g.rng.Intn(2) == 0 {
+		gender = "Woman"
+		firstName = g.pick(femaleFirstNames)
+	}
+	lastName := g.pick(lastNames)
+
+	// Usernames must start with a letter, use [A-Za-z0-9-] and fit in 30
+	// characters; the index keeps them unique.
+	base := asciiFold(strings.ToLower(firstName + lastName))
+	suffix := fmt.Sprint(index)
+	if len(base) > 30-len(suffix) {
+		base = base[:30-len(suffix)]
+	}
+	username := base + suffix
+
+	bio := g.pick(bioOpeners) + " " + g.pick(bioDetails)
+	if g.rng.Intn(2) == 0 {
+		bio += " " + g.pick(bioDetails)
+	}
+
+	c := cities[g.rng.Intn(len(cities))]
+
+	portraits := "men"
+	if gender == "Woman" {
+		portraits = "women"
+	}
+
+	return seedUser{
+		username:     username,
+		email:        username + "@" + g.domain,
+		firstName:    firstName,
+		lastName:     lastName,
+		gender:       gender,
+		interestedIn: interestSets[g.rng.Intn(len(interestSets))],
+		birthday:     g.now.AddDate(-g.between(18, 49), 0, -g.rng.Intn(365)),
+		bio:          bio,
+		// Within about 10km of the city centre.
+		lat:       c.lat + (g.rng.Float64()*0.2 - 0.1),
+		lon:       c.lon + (g.rng.Float64()*0.2 - 0.1),
+		tags:      g.sample(tagNames, g.between(3, 8)),
+		avatarURL: fmt.Sprintf("https://randomuser.me/api/portraits/%s/%d.jpg", portraits, g.between(1, 99)),
+	}
+}
+
+func (g *generator) sample(values []string, n int) []string {
+	picked := make([]string, 0, n)
+	for _, i := range g.rng.Perm(len(values))[:n] {
+		picked = append(picked, values[i])
+	}
+	return picked
+}
+
+// activity gives every user between 10 and 100 views, a share of which turn
+// into likes. Some likes are returned, making matches, and about half of the
+// matches have a short conversation.
+func (g *generator) activity(count int) seedActivity {
+	var activity seedActivity
+	if count < 2 {
+		return activity
+	}
+
+	liked := make(map[[2]int]bool)
+	for viewed := 0; viewed < count; viewed++ {
+		views := g.between(10, 100)
+		if views > count-1 {
+			views = count - 1
+		}
+		likeRate := 0.1 + g.rng.Float64()*0.8
+
+		// Views are at most 100 out of count users, so drawing until enough
+		// distinct viewers come up stays cheap, unlike a permutation of all
+		// users for each of them.
+		seen := make(map[int]bool, views)
+		for len(seen) < views {
+			viewer := g.rng.Intn(count)
+			if viewer == viewed || seen[viewer] {
+				continue
+			}
+			seen[viewer] = true
+			viewedAt := g.daysAgo(30)
+			activity.views = append(activity.views, seedView{viewer, viewed, viewedAt})
+			if g.rng.Float64() < likeRate {
+				activity.likes = append(activity.likes, seedLike{viewer, viewed, viewedAt.Add(time.Duration(g.rng.Intn(3600)) * time.Second)})
+				liked[[2]int{viewer, viewed}] = true
+			}
+		}
+	}
+
+	// Range over a copy: returned likes are appended to activity.likes.
+	for _, like := range append([]seedLike(nil), activity.likes...) {
+		if liked[[2]int{like.liked, like.liker}] || g.rng.Float64() >= 0.3 {
+			continue
+		}
+		likedBack := like.at.Add(time.Duration(g.between(1, 48)) * time.Hour)
+		if likedBack.After(g.now) {
+			likedBack = g.now
+		}
+		activity.likes = append(activity.likes, seedLike{like.liked, like.liker, likedBack})
+		liked[[2]int{like.liked, like.liker}] = true
+
+		if g.rng.Intn(2) == 0 {
+			activity.messages = append(activity.messages, g.conversation(like.liker, like.liked, likedBack)...)
+		}
+	}
+
+	return activity
+}
+
+func (g *generator) conversation(a, b int, matchedAt time.Time) []seedMessage {
+	messages := make([]seedMessage, g.between(2, 8))
+	at := matchedAt
+	for i := range messages {
+		at = at.Add(time.Duration(g.between(1, 180)) * time.Minute)
+		if at.After(g.now) {
+			at = g.now
+		}
+		sender, receiver := a, b
+		if i%2 == 1 {
+			sender, receiver = b, a
+		}
+		messages[i] = seedMessage{sender, receiver, g.pick(chatLines), at}
+	}
+	return messages
+}
+
+// accents covers the accented letters of the name lists in data.go.
+var accents = strings.NewReplacer(
+	"à", "a", "â", "a", "ç", "c", "é", "e", "è", "e", "ê", "e", "ë", "e",
+	"î", "i", "ï", "i", "ô", "o", "ù", "u", "û", "u", "ü", "u",
+)
+
+// asciiFold strips accents and drops every character a username cannot hold.
+func asciiFold(s string) string {
+	var b strings.Builder
+	for _, r := range accents.Replace(s) {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,173 @@
+// Command seed fills a development database with generated users, their
+// profiles, views, likes, matches and conversations.
+//
+// The same -seed always produces the same users and activity; timestamps are
+// relative to the day the command runs. Accounts use the -domain email domain
+// so they can be told apart from real ones and removed with -reset.
+//
+//	go run ./cmd/seed -users 500 -seed 42
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"matcha/database"
+	"matcha/store"
+	"matcha/utils"
+)
+
+// batchSize is the number of users (or users' activity) written per
+// transaction.
+const batchSize = 50
+
+func main() {
+	count := flag.Int("users", 500, "number of users to create")
+	seed := flag.Int64("seed", 42, "random seed")
+	password := flag.String("password", "Password123!", "password of every generated account")
+	domain := flag.String("domain", "matcha-test.com", "email domain of generated accounts")
+	reset := flag.Bool("reset", false, "delete the accounts of -domain before seeding")
+	flag.Parse()
+
+	if *count < 1 {
+		log.Fatal("-users must be at least 1")
+	}
+
+	db := database.DbConnect()
+	defer db.Close()
+	st := store.New(db)
+	ctx := context.Background()
+
+	existing, err := st.CountUsersByEmailDomain(ctx, *domain)
+	if err != nil {
+		log.Fatalf("Error counting existing users: %v", err)
+	}
+	if existing > 0 {
+		if !*reset {
+			log.Fatalf("%d users with @%s already exist; rerun with -reset to replace them", existing, *domain)
+		}
+		deleted, err := st.DeleteUsersByEmailDomain(ctx, *domain)
+		if err != nil {
+			log.Fatalf("Error deleting existing users: %v", err)
+		}
+		log.Printf("Deleted %d users with @%s", deleted, *domain)
+	}
+
+	// Every account shares the password, so it is hashed once.
+	passwordHash, err := utils.HashPassword(*password)
+	if err != nil {
+		log.Fatalf("Error hashing password: %v", err)
+	}
+
+	now := time.Now().UTC().Truncate(24 * time.Hour)
+	gen := newGenerator(*seed, now, *domain)
+	users := gen.users(*count)
+	activity := gen.activity(*count)
+
+	ids, err := createUsers(ctx, st, users, passwordHash)
+	if err != nil {
+		log.Fatalf("Error creating users: %v", err)
+	}
+	log.Printf("Created %d users", len(ids))
+
+	if err := createActivity(ctx, st, ids, activity); err != nil {
+		log.Fatalf("Error creating activity: %v", err)
+	}
+	log.Printf("Created %d views, %d likes and %d messages", len(activity.views), len(activity.likes), len(activity.messages))
+
+	// Fame ratings are only updated by the fame job; rate the new profiles now.
+	rated, err := st.RecomputeFameRatings(ctx)
+	if err != nil {
+		log.Fatalf("Error computing fame ratings: %v", err)
+	}
+	log.Printf("Computed fame ratings of %d users", rated)
+	log.Printf("Log in as any <username>@%s with password %q", *domain, *password)
+}
+
+// createUsers inserts the generated users and returns their database IDs in
+// the same order.
+func createUsers(ctx context.Context, st *store.Store, users []seedUser, passwordHash string) ([]int, error) {
+	ids := make([]int, 0, len(users))
+	for start := 0; start < len(users); start += batchSize {
+		end := min(start+batchSize, len(users))
+		err := st.WithTx(ctx, func(tx *store.Store) error {
+			for _, u := range users[start:end] {
+				id, err := tx.CreateUser(ctx, store.NewUser{
+					Username:     u.username,
+					Email:        u.email,
+					PasswordHash: passwordHash,
+					FirstName:    u.firstName,
+					LastName:     u.lastName,
+					Gender:       u.gender,
+					InterestedIn: u.interestedIn,
+					Birthday:     u.birthday,
+					Bio:          u.bio,
+					Verified:     true,
+				})
+				if err != nil {
+					return err
+				}
+				if err := tx.SetLocation(ctx, id, u.lat, u.lon); err != nil {
+					return err
+				}
+				if err := tx.AddUserTags(ctx, id, u.tags); err != nil {
+					return err
+				}
+				if err := tx.AddImage(ctx, id, u.avatarURL, true); err != nil {
+					return err
+				}
+				ids = append(ids, id)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("  %d/%d users", end, len(users))
+	}
+	return ids, nil
+}
+
+// createActivity writes views, then likes, then messages, each in batches.
+func createActivity(ctx context.Context, st *store.Store, ids []int, activity seedActivity) error {
+	err := inBatches(ctx, st, len(activity.views), func(tx *store.Store, i int) error {
+		v := activity.views[i]
+		return tx.RecordView(ctx, ids[v.viewer], ids[v.viewed], v.at)
+	})
+	if err != nil {
+		return err
+	}
+	err = inBatches(ctx, st, len(activity.likes), func(tx *store.Store, i int) error {
+		l := activity.likes[i]
+		return tx.AddLike(ctx, ids[l.liker], ids[l.liked], l.at)
+	})
+	if err != nil {
+		return err
+	}
+	return inBatches(ctx, st, len(activity.messages), func(tx *store.Store, i int) error {
+		m := activity.messages[i]
+		return tx.AddMessage(ctx, ids[m.sender], ids[m.receiver], m.content, m.at)
+	})
+}
+
+// inBatches calls fn for 0..n-1, committing every batchSize*20 calls.
+func inBatches(ctx context.Context, st *store.Store, n int, fn func(tx *store.Store, i int) error) error {
+	const size = batchSize * 20
+	for start := 0; start < n; start += size {
+		end := min(start+size, n)
+		err := st.WithTx(ctx, func(tx *store.Store) error {
+			for i := start; i < end; i++ {
+				if err := fn(tx, i); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+// Package geo answers radius questions about user locations: distances,
+// the boxes and geohash cells a circle spans, and the SQL conditions that
+// find the locations within a radius using the spatial index of
+// user_locations. Other users only ever see published positions, snapped to
+// a grid, and distances rounded to buckets.
+//
+// Circles are handled on the sphere: one that crosses the antimeridian spans
+// two boxes, and one that contains a pole spans every longitude.
+package geo
+
+import "math"
+
+// EarthRadiusKm is the mean Earth radius, as used by haversine_km in SQL.
+const EarthRadiusKm = 6371.0
+
+// kmPerDegree is the length of a degree of latitude, or of longitude at the
+// equator.
+const kmPerDegree = EarthRadiusKm * math.Pi / 180
+
+// Point is a location in degrees.
+type Point struct {
+	Lat, Lon float64
+}
+
+// DistanceKm is the great-circle distance between a and b, by the haversine
+// formula.
+func DistanceKm(a, b Point) float64 {
+	dLat := radians(b.Lat - a.Lat)
+	dLon := radians(b.Lon - a.Lon)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(radians(a.Lat))*math.Cos(radians(b.Lat))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	// Rounding can push h just over 1 for antipodal points.
+	return 2 * EarthRadiusKm * math.Asin(math.Sqrt(math.Min(1, h)))
+}
+
+// Box is a latitude/longitude rectangle, bounds included. MinLon <= MaxLon:
+// a box never crosses the antimeridian.
+type Box struct {
+	MinLat, MaxLat float64
+	MinLon, MaxLon float64
+}
+
+// Contains reports whether p is in the box.
+func (b Box) Contains(p Point) bool {
+	return p.Lat >= b.MinLat && p.Lat <= b.MaxLat && p.Lon >= b.MinLon && p.Lon <= b.MaxLon
+}
+
+// World is the box of every location.
+var World = Box{MinLat: -90, MaxLat: 90, MinLon: -180, MaxLon: 180}
+
+// BoundingBoxes returns the smallest boxes containing every point within
+// radiusKm of center: one box, or two when the circle crosses the
+// antimeridian. A circle containing a pole spans every longitude between its
+// lowest (or highest) latitude and the pole.
+func BoundingBoxes(center Point, radiusKm float64) []Box {
+	dLat := radiusKm / kmPerDegree
+	minLat, maxLat := center.Lat-dLat, center.Lat+dLat
+	if minLat <= -90 || maxLat >= 90 {
+		return []Box{{MinLat: math.Max(minLat, -90), MaxLat: math.Min(maxLat, 90), MinLon: -180, MaxLon: 180}}
+	}
+
+	// The widest point of the circle is not on center's parallel but closer
+	// to the pole: its longitude span is asin(sin(r) / cos(lat)).
+	angular := radiusKm / EarthRadiusKm
+	ratio := math.Sin(angular) / math.Cos(radians(center.Lat))
+	if ratio >= 1 {
+		return []Box{{MinLat: minLat, MaxLat: maxLat, MinLon: -180, MaxLon: 180}}
+	}
+	dLon := degrees(math.Asin(ratio))
+	minLon, maxLon := center.Lon-dLon, center.Lon+dLon
+
+	switch {
+	case minLon < -180:
+		return []Box{
+			{MinLat: minLat, MaxLat: maxLat, MinLon: minLon + 360, MaxLon: 180},
+			{MinLat: minLat, MaxLat: maxLat, MinLon: -180, MaxLon: maxLon},
+		}
+	case maxLon > 180:
+		return []Box{
+			{MinLat: minLat, MaxLat: maxLat, MinLon: minLon, MaxLon: 180},
+			{MinLat: minLat, MaxLat: maxLat, MinLon: -180, MaxLon: maxLon - 360},
+		}
+	}
+	return []Box{{MinLat: minLat, MaxLat: maxLat, MinLon: minLon, MaxLon: maxLon}}
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
@@ -0,0 +1,147 @@
+package geo
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoundingBoxes(t *testing.T) {
+	tests := []struct {
+		name     string
+		center   Point
+		radiusKm float64
+		want     []Box
+	}{
+		{
+			name:     "on the equator",
+			center:   Point{Lat: 0, Lon: 10},
+			radiusKm: kmPerDegree,
+			want:     []Box{{MinLat: -1, MaxLat: 1, MinLon: 9, MaxLon: 11}},
+		},
+		{
+			name:     "widest poleward of the center",
+			center:   Point{Lat: 60, Lon: 0},
+			radiusKm: kmPerDegree,
+			want: []Box{{MinLat: 59, MaxLat: 61,
+				MinLon: -degrees(math.Asin(2 * math.Sin(radians(1)))), MaxLon: degrees(math.Asin(2 * math.Sin(radians(1))))}},
+		},
+		{
+			name:     "crossing the antimeridian eastwards",
+			center:   Point{Lat: 0, Lon: 179.5},
+			radiusKm: kmPerDegree,
+			want: []Box{
+				{MinLat: -1, MaxLat: 1, MinLon: 178.5, MaxLon: 180},
+				{MinLat: -1, MaxLat: 1, MinLon: -180, MaxLon: -179.5},
+			},
+		},
+		{
+			name:     "crossing the antimeridian westwards",
+			center:   Point{Lat: 0, Lon: -179.5},
+			radiusKm: kmPerDegree,
+			want: []Box{
+				{MinLat: -1, MaxLat: 1, MinLon: 179.5, MaxLon: 180},
+				{MinLat: -1, MaxLat: 1, MinLon: -180, MaxLon: -178.5},
+			},
+		},
+		{
+			name:     "containing the north pole",
+			center:   Point{Lat: 89.5, Lon: 30},
+			radiusKm: kmPerDegree,
+			want:     []Box{{MinLat: 88.5, MaxLat: 90, MinLon: -180, MaxLon: 180}},
+		},
+		{
+			name:     "containing the south pole",
+			center:   Point{Lat: -89.5, Lon: -120},
+			radiusKm: kmPerDegree,
+			want:     []Box{{MinLat: -90, MaxLat: -88.5, MinLon: -180, MaxLon: 180}},
+		},
+		{
+			// Rounding makes sin(r) / cos(lat) reach 1 while the circle stops
+			// just short of the pole.
+			name:     "ratio of 1 just short of the pole",
+			center:   Point{Lat: 81.490400000049476, Lon: 0},
+			radiusKm: 946.22434776903458,
+			want:     []Box{{MinLat: 72.98080000009897, MaxLat: 89.99999999999999, MinLon: -180, MaxLon: 180}},
+		},
+		{
+			name:     "larger than a hemisphere",
+			center:   Point{Lat: 10, Lon: 0},
+			radiusKm: 110 * kmPerDegree,
+			want:     []Box{{MinLat: -90, MaxLat: 90, MinLon: -180, MaxLon: 180}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := BoundingBoxes(tt.center, tt.radiusKm)
+			if !assert.Len(t, got, len(tt.want)) {
+				return
+			}
+			for i, want := range tt.want {
+				assert.InDelta(t, want.MinLat, got[i].MinLat, 1e-9, "box %d MinLat", i)
+				assert.InDelta(t, want.MaxLat, got[i].MaxLat, 1e-9, "box %d MaxLat", i)
+				assert.InDelta(t, want.MinLon, got[i].MinLon, 1e-9, "box %d MinLon", i)
+				assert.InDelta(t, want.MaxLon, got[i].MaxLon, 1e-9, "box %d MaxLon", i)
+			}
+		})
+	}
+}
+
+// testCircles are circles in the easy middle of the map and where boxes
+// and geohash cells break: the antimeridian and the poles.
+var testCircles = []struct {
+	name     string
+	center   Point
+	radiusKm float64
+}{
+	{"paris", Point{Lat: 48.8566, Lon: 2.3522}, 25},
+	{"wide", Point{Lat: -33.87, Lon: 151.21}, 400},
+	{"antimeridian", Point{Lat: -16.5, Lon: 179.95}, 40},
+	{"antimeridian west", Point{Lat: 65.2, Lon: -179.8}, 60},
+	{"near the north pole", Point{Lat: 89.7, Lon: 45}, 50},
+	{"around the south pole", Point{Lat: -89.95, Lon: -10}, 30},
+	{"equator and prime meridian", Point{Lat: 0, Lon: 0}, 15},
+}
+
+// destination is the point distanceKm away from p in the direction of
+// bearing, in radians clockwise from north, with its longitude in
+// [-180, 180).
+func destination(p Point, bearing, distanceKm float64) Point {
+	angular := distanceKm / EarthRadiusKm
+	lat1, lon1 := radians(p.Lat), radians(p.Lon)
+	lat2 := math.Asin(math.Sin(lat1)*math.Cos(angular) + math.Cos(lat1)*math.Sin(angular)*math.Cos(bearing))
+	lon2 := lon1 + math.Atan2(math.Sin(bearing)*math.Sin(angular)*math.Cos(lat1),
+		math.Cos(angular)-math.Sin(lat1)*math.Sin(lat2))
+	lon := math.Mod(degrees(lon2)+540, 360) - 180
+	return Point{Lat: degrees(lat2), Lon: lon}
+}
+
+// pointsAround returns n random points spread evenly over the disc of 1.5
+// radii around center, so that 4 in 9 fall inside the circle.
+func pointsAround(rng *rand.Rand, center Point, radiusKm float64, n int) []Point {
+	points := make([]Point, n)
+	for i := range points {
+		points[i] = destination(center, rng.Float64()*2*math.Pi, 1.5*radiusKm*math.Sqrt(rng.Float64()))
+	}
+	return points
+}
+
+func TestBoundingBoxesContainTheCircle(t *testing.T) {
+	for _, circle := range testCircles {
+		t.Run(circle.name, func(t *testing.T) {
+			boxes := BoundingBoxes(circle.center, circle.radiusKm)
+			for degree := 0; degree < 360; degree++ {
+				// Just inside the edge, so rounding cannot push it out.
+				p := destination(circle.center, radians(float64(degree)), circle.radiusKm*(1-1e-9))
+				contained := false
+				for _, box := range boxes {
+					contained = contained || box.Contains(p)
+				}
+				assert.True(t, contained, "bearing %d°: %+v not in %+v", degree, p, boxes)
+			}
+		})
+	}
+}
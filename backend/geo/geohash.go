@@ -0,0 +1,160 @@
+package geo
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// HashPrecision is the length of the geohashes of published positions stored
+// in user_locations.geohash (cells of about 5m).
+const HashPrecision = 9
+
+// Covers use cells of at most maxCoverPrecision characters, and at most
+// maxCoverCells of them; a circle needing more is not prefiltered.
+const (
+	maxCoverPrecision = 6
+	maxCoverCells     = 32
+)
+
+const base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Encode returns the geohash of p with precision characters. It matches the
+// geohash_encode SQL function.
+func Encode(p Point, precision int) string {
+	minLat, maxLat := -90.0, 90.0
+	minLon, maxLon := -180.0, 180.0
+	hash := make([]byte, 0, precision)
+	bits, bit := 0, 0
+	even := true
+	for len(hash) < precision {
+		if even {
+			mid := (minLon + maxLon) / 2
+			if p.Lon >= mid {
+				bits = bits*2 + 1
+				minLon = mid
+			} else {
+				bits = bits * 2
+				maxLon = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if p.Lat >= mid {
+				bits = bits*2 + 1
+				minLat = mid
+			} else {
+				bits = bits * 2
+				maxLat = mid
+			}
+		}
+		even = !even
+		bit++
+		if bit == 5 {
+			hash = append(hash, base32[bits])
+			bits, bit = 0, 0
+		}
+	}
+	return string(hash)
+}
+
+// cellSize is the size in degrees of the geohash cells with precision
+// characters. Even bits split longitude, so it gets the extra bit of odd
+// lengths.
+func cellSize(precision int) (latDeg, lonDeg float64) {
+	lonBits := (5*precision + 1) / 2
+	latBits := 5 * precision / 2
+	return 180 / math.Exp2(float64(latBits)), 360 / math.Exp2(float64(lonBits))
+}
+
+// Cover returns the geohash prefixes of the cells intersecting the bounding
+// boxes of the circle, at the finest precision needing at most maxCoverCells
+// cells, sorted. It returns nil when the circle needs more cells at every
+// precision, and locations should be checked without a prefilter.
+func Cover(center Point, radiusKm float64) []string {
+	boxes := BoundingBoxes(center, radiusKm)
+	for precision := maxCoverPrecision; precision >= 1; precision-- {
+		if cells := coverCells(boxes, precision); cells != nil {
+			return cells
+		}
+	}
+	return nil
+}
+
+// coverCells lists the cells of boxes at precision, or returns nil when there
+// are more than maxCoverCells.
+func coverCells(boxes []Box, precision int) []string {
+	latDeg, lonDeg := cellSize(precision)
+	rows := int(math.Round(180 / latDeg))
+	cols := int(math.Round(360 / lonDeg))
+	index := func(value, origin, size float64, count int) int {
+		return max(0, min(int(math.Floor((value-origin)/size)), count-1))
+	}
+
+	count := 0
+	for _, box := range boxes {
+		r := index(box.MaxLat, -90, latDeg, rows) - index(box.MinLat, -90, latDeg, rows) + 1
+		c := index(box.MaxLon, -180, lonDeg, cols) - index(box.MinLon, -180, lonDeg, cols) + 1
+		count += r * c
+		if count > maxCoverCells {
+			return nil
+		}
+	}
+
+	seen := make(map[string]bool, count)
+	cells := make([]string, 0, count)
+	for _, box := range boxes {
+		for row := index(box.MinLat, -90, latDeg, rows); row <= index(box.MaxLat, -90, latDeg, rows); row++ {
+			for col := index(box.MinLon, -180, lonDeg, cols); col <= index(box.MaxLon, -180, lonDeg, cols); col++ {
+				// Encoding the center of the cell gives its hash.
+				center := Point{Lat: -90 + (float64(row)+0.5)*latDeg, Lon: -180 + (float64(col)+0.5)*lonDeg}
+				hash := Encode(center, precision)
+				if !seen[hash] {
+					seen[hash] = true
+					cells = append(cells, hash)
+				}
+			}
+		}
+	}
+	sort.Strings(cells)
+	return cells
+}
+
+// HashRange is a range of geohashes, From included, To excluded. An empty To
+// is unbounded.
+type HashRange struct {
+	From, To string
+}
+
+// Ranges merges sorted prefixes of the same length into the ranges of
+// geohashes starting with one of them. Consecutive cells, which are often
+// neighbours in the Z-order of geohashes, become one range.
+func Ranges(prefixes []string) []HashRange {
+	ranges := []HashRange{}
+	for _, prefix := range prefixes {
+		next, ok := nextHash(prefix)
+		if !ok {
+			next = ""
+		}
+		if n := len(ranges); n > 0 && ranges[n-1].To == prefix {
+			ranges[n-1].To = next
+			continue
+		}
+		ranges = append(ranges, HashRange{From: prefix, To: next})
+	}
+	return ranges
+}
+
+// nextHash returns the geohash following hash among those of its length, or
+// false for the last one.
+func nextHash(hash string) (string, bool) {
+	b := []byte(hash)
+	for i := len(b) - 1; i >= 0; i-- {
+		pos := strings.IndexByte(base32, b[i])
+		if pos < len(base32)-1 {
+			b[i] = base32[pos+1]
+			return string(b), true
+		}
+		b[i] = base32[0]
+	}
+	return "", false
+}
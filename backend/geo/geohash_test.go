@@ -0,0 +1,73 @@
+package geo
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		point     Point
+		precision int
+		want      string
+	}{
+		{Point{Lat: 57.64911, Lon: 10.40744}, 11, "u4pruydqqvj"},
+		{Point{Lat: 42.6, Lon: -5.6}, 5, "ezs42"},
+		{Point{Lat: 48.8566, Lon: 2.3522}, 9, "u09tvw0f6"},
+		{Point{Lat: -90, Lon: -180}, 6, "000000"},
+		{Point{Lat: 90, Lon: 180}, 6, "zzzzzz"},
+		{Point{Lat: 0, Lon: 0}, 4, "s000"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Encode(tt.point, tt.precision), "%+v", tt.point)
+	}
+}
+
+// inRanges reports whether hash starts within one of ranges.
+func inRanges(hash string, ranges []HashRange) bool {
+	for _, r := range ranges {
+		if hash >= r.From && (r.To == "" || hash < r.To) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestCoverMatchesHaversineScan checks that every point within the radius,
+// found by scanning with DistanceKm, has a geohash in the ranges Within
+// filters on.
+func TestCoverMatchesHaversineScan(t *testing.T) {
+	rng := rand.New(rand.NewPCG(48, 1))
+	for _, circle := range testCircles {
+		t.Run(circle.name, func(t *testing.T) {
+			cover := Cover(circle.center, circle.radiusKm)
+			if !assert.NotNil(t, cover, "the circle should be prefiltered") {
+				return
+			}
+			ranges := Ranges(cover)
+			within := 0
+			for _, p := range pointsAround(rng, circle.center, circle.radiusKm, 2000) {
+				if DistanceKm(circle.center, p) > circle.radiusKm {
+					continue
+				}
+				within++
+				hash := Encode(p, HashPrecision)
+				assert.True(t, inRanges(hash, ranges), "%+v (%s) is within %gkm but outside %v", p, hash, circle.radiusKm, cover)
+				covered := false
+				for _, prefix := range cover {
+					covered = covered || strings.HasPrefix(hash, prefix)
+				}
+				assert.True(t, covered, "%+v (%s) is within %gkm but not covered by %v", p, hash, circle.radiusKm, cover)
+			}
+			assert.Greater(t, within, 500)
+		})
+	}
+}
+
+func TestRanges(t *testing.T) {
+	assert.Equal(t, []HashRange{{From: "u0", To: "u2"}, {From: "u4", To: "u5"}, {From: "zz", To: ""}},
+		Ranges([]string{"u0", "u1", "u4", "zz"}))
+}
@@ -0,0 +1,31 @@
+package geo
+
+import "math"
+
+// GridKm is the size of the cells locations are snapped to before other users
+// see them (fuzz_lat and fuzz_lon in SQL).
+const GridKm = 2.0
+
+// distanceBuckets are the steps distances shown to other users are rounded
+// up to, each up to its bound.
+var distanceBuckets = []struct{ below, step float64 }{
+	{20, 2},
+	{50, 5},
+	{200, 10},
+	{math.Inf(1), 50},
+}
+
+// RoundDistanceKm rounds a distance up to its bucket: 2km steps below 20km,
+// then 5km below 50km, 10km below 200km and 50km beyond. Negative distances,
+// which mean unknown, are returned as is.
+func RoundDistanceKm(km float64) float64 {
+	if km < 0 {
+		return km
+	}
+	for _, bucket := range distanceBuckets {
+		if km < bucket.below {
+			return math.Max(bucket.step, math.Ceil(km/bucket.step)*bucket.step)
+		}
+	}
+	return km
+}
@@ -0,0 +1,84 @@
+package geo
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// Index is the spatial index radius conditions are written for.
+type Index string
+
+const (
+	// GeohashIndex uses the B-tree index on user_locations.geohash, always
+	// available.
+	GeohashIndex Index = "geohash"
+	// PostGISIndex uses the GiST index on the locations' geography, created
+	// by the migrations when PostGIS is installed.
+	PostGISIndex Index = "postgis"
+)
+
+// postgisIndexName is the GiST index DetectIndex looks for.
+const postgisIndexName = "idx_user_locations_geography"
+
+// DetectIndex returns PostGISIndex when user_locations has its PostGIS index,
+// and GeohashIndex otherwise.
+func DetectIndex(ctx context.Context, db *sql.DB) (Index, error) {
+	var postgis bool
+	err := db.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM pg_indexes WHERE tablename = 'user_locations' AND indexname = $1)
+		   AND EXISTS(SELECT 1 FROM pg_extension WHERE extname = 'postgis')
+	`, postgisIndexName).Scan(&postgis)
+	if err != nil {
+		return GeohashIndex, err
+	}
+	if postgis {
+		return PostGISIndex, nil
+	}
+	return GeohashIndex, nil
+}
+
+// Within returns the SQL condition that the user_locations row alias is
+// within radiusKm of center, appending its parameters to args. Locations are
+// taken at their published position (public_lat, public_lon), as other users
+// see them. The index narrows the candidates; haversine_km has the final say
+// so both indexes return the same rows.
+func (index Index) Within(alias string, center Point, radiusKm float64, args []interface{}) (string, []interface{}) {
+	param := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+	// Typed so that every use of a parameter agrees on it.
+	lat := param(center.Lat) + "::double precision"
+	lon := param(center.Lon) + "::double precision"
+	radius := param(radiusKm) + "::double precision"
+	distance := "haversine_km(" + lat + ", " + lon + ", " + alias + ".public_lat, " + alias + ".public_lon) <= " + radius
+
+	if index == PostGISIndex {
+		// Matches the expression of idx_user_locations_geography. The
+		// spheroid distance is within 0.5% of haversine_km, hence the margin.
+		return "ST_DWithin(" + Geography(alias) + ", ST_SetSRID(ST_MakePoint(" + lon + ", " + lat + "), 4326)::geography, " +
+			radius + " * 1005) AND " + distance, args
+	}
+
+	cover := Cover(center, radiusKm)
+	if cover == nil {
+		return distance, args
+	}
+	ranges := make([]string, 0, len(cover))
+	for _, r := range Ranges(cover) {
+		condition := alias + ".geohash >= " + param(r.From)
+		if r.To != "" {
+			condition += " AND " + alias + ".geohash < " + param(r.To)
+		}
+		ranges = append(ranges, "("+condition+")")
+	}
+	return "(" + strings.Join(ranges, " OR ") + ") AND " + distance, args
+}
+
+// Geography is the PostGIS geography of the published position of the
+// user_locations row alias, as indexed by idx_user_locations_geography.
+func Geography(alias string) string {
+	return "ST_SetSRID(ST_MakePoint(" + alias + ".public_lon, " + alias + ".public_lat), 4326)::geography"
+}
@@ -0,0 +1,101 @@
+package geo
+
+import (
+	"context"
+	"database/sql"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testConn returns a connection to the migrated database in TEST_DB_STRING,
+// which has geohash_encode and haversine_km, or skips the test.
+func testConn(t *testing.T) *sql.Conn {
+	dsn := os.Getenv("TEST_DB_STRING")
+	if dsn == "" {
+		t.Skip("TEST_DB_STRING is not set")
+	}
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	// Temporary tables only exist on the connection that created them.
+	conn, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// TestWithinMatchesHaversineScan runs the Within condition of every test
+// circle over random locations, with geohashes from geohash_encode, and
+// compares the rows it finds with a DistanceKm scan. It also checks that
+// Encode matches geohash_encode for each of those locations.
+func TestWithinMatchesHaversineScan(t *testing.T) {
+	conn := testConn(t)
+	ctx := context.Background()
+
+	_, err := conn.ExecContext(ctx, `
+		CREATE TEMPORARY TABLE geo_test_locations (
+			id INTEGER PRIMARY KEY,
+			public_lat DOUBLE PRECISION NOT NULL,
+			public_lon DOUBLE PRECISION NOT NULL,
+			geohash TEXT NOT NULL
+		)
+	`)
+	require.NoError(t, err)
+
+	rng := rand.New(rand.NewPCG(48, 2))
+	var points []Point
+	for _, circle := range testCircles {
+		points = append(points, pointsAround(rng, circle.center, circle.radiusKm, 300)...)
+	}
+	for id, p := range points {
+		var hash string
+		err := conn.QueryRowContext(ctx, `
+			INSERT INTO geo_test_locations (id, public_lat, public_lon, geohash)
+			VALUES ($1, $2, $3, geohash_encode($2, $3, $4))
+			RETURNING geohash
+		`, id, p.Lat, p.Lon, HashPrecision).Scan(&hash)
+		require.NoError(t, err)
+		assert.Equal(t, Encode(p, HashPrecision), hash, "%+v", p)
+	}
+
+	for _, circle := range testCircles {
+		t.Run(circle.name, func(t *testing.T) {
+			want := []int{}
+			// Go and SQL may round differently right on the edge.
+			edge := map[int]bool{}
+			for id, p := range points {
+				distance := DistanceKm(circle.center, p)
+				switch {
+				case distance > circle.radiusKm-1e-6 && distance < circle.radiusKm+1e-6:
+					edge[id] = true
+				case distance <= circle.radiusKm:
+					want = append(want, id)
+				}
+			}
+
+			condition, args := GeohashIndex.Within("l", circle.center, circle.radiusKm, nil)
+			rows, err := conn.QueryContext(ctx, "SELECT id FROM geo_test_locations l WHERE "+condition, args...)
+			require.NoError(t, err)
+			defer rows.Close()
+			got := []int{}
+			for rows.Next() {
+				var id int
+				require.NoError(t, rows.Scan(&id))
+				if !edge[id] {
+					got = append(got, id)
+				}
+			}
+			require.NoError(t, rows.Err())
+			sort.Ints(got)
+
+			assert.NotEmpty(t, want)
+			assert.Equal(t, want, got)
+		})
+	}
+}
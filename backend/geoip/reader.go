@@ -0,0 +1,80 @@
+// Package geoip looks up the approximate location of IP addresses in a local
+// MaxMind DB file (.mmdb), such as GeoLite2 City or DB-IP City Lite, so
+// lookups need no network access.
+package geoip
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+
+	"matcha/geo"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+// ErrNoLocation is returned by Lookup for addresses the database does not
+// locate: private ranges, or records without coordinates.
+var ErrNoLocation = errors.New("geoip: no location for this address")
+
+// Location is where an address is, to within AccuracyKm.
+type Location struct {
+	geo.Point
+	AccuracyKm float64
+}
+
+// Reader looks addresses up in a MaxMind DB file. It is safe for concurrent
+// use.
+type Reader struct {
+	db *maxminddb.Reader
+	// DatabaseType is the type recorded in the metadata, e.g.
+	// "GeoLite2-City".
+	DatabaseType string
+}
+
+// cityRecord is the part of a City record that Lookup reads.
+type cityRecord struct {
+	Location struct {
+		Latitude       *float64 `maxminddb:"latitude"`
+		Longitude      *float64 `maxminddb:"longitude"`
+		AccuracyRadius uint16   `maxminddb:"accuracy_radius"`
+	} `maxminddb:"location"`
+}
+
+// Open reads the database at path.
+func Open(path string) (*Reader, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{db: db, DatabaseType: db.Metadata.DatabaseType}, nil
+}
+
+// Close releases the database file.
+func (r *Reader) Close() error {
+	return r.db.Close()
+}
+
+// Lookup returns the location of ip, or ErrNoLocation.
+func (r *Reader) Lookup(ip net.IP) (Location, error) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return Location{}, ErrNoLocation
+	}
+	addr = addr.Unmap()
+	if addr.Is6() && r.db.Metadata.IPVersion == 4 {
+		return Location{}, ErrNoLocation
+	}
+
+	var record cityRecord
+	if err := r.db.Lookup(addr).Decode(&record); err != nil {
+		return Location{}, err
+	}
+	if record.Location.Latitude == nil || record.Location.Longitude == nil {
+		return Location{}, ErrNoLocation
+	}
+	return Location{
+		Point:      geo.Point{Lat: *record.Location.Latitude, Lon: *record.Location.Longitude},
+		AccuracyKm: float64(record.Location.AccuracyRadius),
+	}, nil
+}
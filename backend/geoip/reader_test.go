@@ -0,0 +1,95 @@
+package geoip
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCityDB writes a small City database: Paris for 81.2.69.0/24 and
+// 2a01:e0a::/32, and a record without coordinates for 89.160.20.0/24.
+func writeCityDB(t *testing.T, ipVersion, recordSize int) string {
+	tree, err := mmdbwriter.New(mmdbwriter.Options{
+		DatabaseType: "GeoLite2-City",
+		IPVersion:    ipVersion,
+		RecordSize:   recordSize,
+	})
+	require.NoError(t, err)
+
+	paris := mmdbtype.Map{"location": mmdbtype.Map{
+		"latitude":        mmdbtype.Float64(48.8566),
+		"longitude":       mmdbtype.Float64(2.3522),
+		"accuracy_radius": mmdbtype.Uint16(20),
+	}}
+	countryOnly := mmdbtype.Map{"country": mmdbtype.Map{"iso_code": mmdbtype.String("SE")}}
+	networks := map[string]mmdbtype.DataType{"81.2.69.0/24": paris, "89.160.20.0/24": countryOnly}
+	if ipVersion == 6 {
+		networks["2a01:e0a::/32"] = paris
+	}
+	for cidr, record := range networks {
+		_, network, err := net.ParseCIDR(cidr)
+		require.NoError(t, err)
+		require.NoError(t, tree.Insert(network, record))
+	}
+
+	path := filepath.Join(t.TempDir(), "city.mmdb")
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+	_, err = tree.WriteTo(file)
+	require.NoError(t, err)
+	return path
+}
+
+func TestLookup(t *testing.T) {
+	for _, ipVersion := range []int{4, 6} {
+		for _, recordSize := range []int{24, 28, 32} {
+			reader, err := Open(writeCityDB(t, ipVersion, recordSize))
+			require.NoError(t, err)
+			defer reader.Close()
+			assert.Equal(t, "GeoLite2-City", reader.DatabaseType)
+
+			addresses := []string{"81.2.69.160", "::ffff:81.2.69.160"}
+			if ipVersion == 6 {
+				addresses = append(addresses, "2a01:e0a::1")
+			}
+			for _, address := range addresses {
+				location, err := reader.Lookup(net.ParseIP(address))
+				if assert.NoError(t, err, "IPv%d, %d-bit records: %s", ipVersion, recordSize, address) {
+					assert.Equal(t, 48.8566, location.Lat)
+					assert.Equal(t, 2.3522, location.Lon)
+					assert.Equal(t, 20.0, location.AccuracyKm)
+				}
+			}
+
+			for _, address := range []string{"10.1.2.3", "192.168.1.1", "127.0.0.1", "89.160.20.112", "2a02:cf40::1"} {
+				_, err := reader.Lookup(net.ParseIP(address))
+				assert.ErrorIs(t, err, ErrNoLocation, "IPv%d, %d-bit records: %s", ipVersion, recordSize, address)
+			}
+		}
+	}
+}
+
+func TestOpenTruncated(t *testing.T) {
+	content, err := os.ReadFile(writeCityDB(t, 6, 28))
+	require.NoError(t, err)
+
+	for _, size := range []int{0, 100, len(content) / 2, len(content) - 10} {
+		path := filepath.Join(t.TempDir(), "truncated.mmdb")
+		require.NoError(t, os.WriteFile(path, content[:size], 0o644))
+		reader, err := Open(path)
+		if err == nil {
+			// Cut after the metadata is read, the tree or data section is
+			// missing: lookups must fail rather than panic.
+			_, err = reader.Lookup(net.ParseIP("81.2.69.160"))
+			reader.Close()
+		}
+		assert.Error(t, err, "file cut to %d of %d bytes", size, len(content))
+	}
+}
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE reports
+    ADD COLUMN IF NOT EXISTS resolved_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS resolution TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_reports_unresolved ON reports(created_at) WHERE resolved_at IS NULL;
+
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS banned_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS ban_reason TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+    DROP COLUMN IF EXISTS ban_reason,
+    DROP COLUMN IF EXISTS banned_at;
+
+DROP INDEX IF EXISTS idx_reports_unresolved;
+
+ALTER TABLE reports
+    DROP COLUMN IF EXISTS resolution,
+    DROP COLUMN IF EXISTS resolved_at;
+-- +goose StatementEnd
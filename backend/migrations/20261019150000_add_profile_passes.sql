@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS profile_passes (
+    passer_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    passed_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (passer_id, passed_id),
+    CHECK (passer_id <> passed_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_profile_passes_passer_created ON profile_passes(passer_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS profile_passes;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS user_preferences (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    min_age INTEGER,
+    max_age INTEGER,
+    max_distance_km DOUBLE PRECISION,
+    min_fame DOUBLE PRECISION,
+    max_fame DOUBLE PRECISION,
+    required_tags TEXT[] NOT NULL DEFAULT '{}',
+    dealbreakers TEXT[] NOT NULL DEFAULT '{}',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS user_preferences;
+-- +goose StatementEnd
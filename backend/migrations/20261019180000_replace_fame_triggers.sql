@@ -0,0 +1,92 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Fame ratings are computed by the server's fame job (store.RecomputeFameRatings).
+DROP TRIGGER IF EXISTS trigger_update_fame_on_view ON profile_views;
+DROP TRIGGER IF EXISTS trigger_update_fame_on_like ON profile_likes;
+DROP FUNCTION IF EXISTS update_fame_rating_on_view();
+DROP FUNCTION IF EXISTS update_fame_rating_on_like();
+DROP FUNCTION IF EXISTS calculate_fame_rating(INTEGER);
+
+CREATE TABLE IF NOT EXISTS fame_scores (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    rating DOUBLE PRECISION NOT NULL,
+    desirability DOUBLE PRECISION NOT NULL,
+    weighted_likes DOUBLE PRECISION NOT NULL,
+    decayed_views DOUBLE PRECISION NOT NULL,
+    likes INTEGER NOT NULL,
+    views INTEGER NOT NULL,
+    computed_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS fame_rating_history (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    rating DECIMAL(5,2) NOT NULL,
+    computed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_fame_rating_history_user ON fame_rating_history(user_id, computed_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS fame_rating_history;
+DROP TABLE IF EXISTS fame_scores;
+
+CREATE OR REPLACE FUNCTION calculate_fame_rating(user_id_param INTEGER)
+RETURNS DECIMAL AS $$
+DECLARE
+    likes_count INTEGER;
+    views_count INTEGER;
+    rating DECIMAL;
+BEGIN
+    SELECT COUNT(*) INTO likes_count FROM profile_likes WHERE liked_id = user_id_param;
+    SELECT COUNT(*) INTO views_count FROM profile_views WHERE viewed_id = user_id_param;
+
+    IF views_count = 0 THEN
+        rating := 0.00;
+    ELSE
+        rating := (likes_count::DECIMAL / views_count::DECIMAL) * 100.0;
+    END IF;
+    IF rating > 100 THEN
+        rating := 100.00;
+    END IF;
+
+    UPDATE users SET fame_rating = rating WHERE id = user_id_param;
+    RETURN rating;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION update_fame_rating_on_like()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        PERFORM calculate_fame_rating(OLD.liked_id);
+        RETURN OLD;
+    ELSE
+        PERFORM calculate_fame_rating(NEW.liked_id);
+        RETURN NEW;
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_update_fame_on_like
+AFTER INSERT OR DELETE ON profile_likes
+FOR EACH ROW
+EXECUTE FUNCTION update_fame_rating_on_like();
+
+CREATE OR REPLACE FUNCTION update_fame_rating_on_view()
+RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM calculate_fame_rating(NEW.viewed_id);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_update_fame_on_view
+AFTER INSERT ON profile_views
+FOR EACH ROW
+EXECUTE FUNCTION update_fame_rating_on_view();
+
+SELECT COUNT(calculate_fame_rating(id)) FROM users;
+-- +goose StatementEnd
@@ -0,0 +1,78 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS genders (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL UNIQUE,
+    sort_order INTEGER NOT NULL DEFAULT 0
+);
+
+INSERT INTO genders (name, sort_order) VALUES
+    ('Woman', 1),
+    ('Man', 2),
+    ('Non-binary', 3),
+    ('Genderfluid', 4),
+    ('Agender', 5),
+    ('Other', 6);
+
+ALTER TABLE users ADD COLUMN gender_id INTEGER REFERENCES genders(id);
+
+-- The genders a user wants to meet. Two users match when each one's gender
+-- is among the other's.
+CREATE TABLE IF NOT EXISTS user_interested_in (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    gender_id INTEGER NOT NULL REFERENCES genders(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, gender_id)
+);
+
+CREATE INDEX idx_user_interested_in_gender ON user_interested_in(gender_id);
+CREATE INDEX idx_users_gender_id ON users(gender_id);
+
+UPDATE users u SET gender_id = g.id FROM genders g WHERE g.name = u.gender;
+
+-- Orientations are read the way suggestions used to read them: case and
+-- surrounding spaces are ignored, short forms and suffixes are accepted, and
+-- a missing or unrecognized orientation means interested in both.
+INSERT INTO user_interested_in (user_id, gender_id)
+SELECT o.id, g.id
+FROM (
+    SELECT id, CASE
+            WHEN lower(trim(orientation)) IN ('likes men', 'men') THEN ARRAY['Man']
+            WHEN lower(trim(orientation)) IN ('likes women', 'women') THEN ARRAY['Woman']
+            WHEN lower(trim(orientation)) LIKE '%men and women' THEN ARRAY['Man', 'Woman']
+            WHEN lower(trim(orientation)) LIKE '%women' THEN ARRAY['Woman']
+            WHEN lower(trim(orientation)) LIKE '%men' THEN ARRAY['Man']
+            ELSE ARRAY['Man', 'Woman']
+        END AS names
+    FROM users
+) o
+JOIN genders g ON g.name = ANY(o.names);
+
+ALTER TABLE users DROP COLUMN gender;
+ALTER TABLE users DROP COLUMN orientation;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN gender TEXT;
+ALTER TABLE users ADD COLUMN orientation TEXT;
+
+-- Genders other than Man and Woman have no equivalent and are lost.
+UPDATE users u SET gender = g.name FROM genders g WHERE g.id = u.gender_id AND g.name IN ('Man', 'Woman');
+
+UPDATE users u SET orientation = CASE
+        WHEN i.names @> ARRAY['Man', 'Woman'] THEN 'likes men and women'
+        WHEN i.names @> ARRAY['Man'] THEN 'likes men'
+        WHEN i.names @> ARRAY['Woman'] THEN 'likes women'
+    END
+FROM (
+    SELECT ui.user_id, ARRAY_AGG(g.name) AS names
+    FROM user_interested_in ui
+    JOIN genders g ON g.id = ui.gender_id
+    GROUP BY ui.user_id
+) i
+WHERE i.user_id = u.id;
+
+DROP TABLE IF EXISTS user_interested_in;
+ALTER TABLE users DROP COLUMN IF EXISTS gender_id;
+DROP TABLE IF EXISTS genders;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    min_age INTEGER,
+    max_age INTEGER,
+    max_distance_km DOUBLE PRECISION,
+    min_fame DOUBLE PRECISION,
+    max_fame DOUBLE PRECISION,
+    tags TEXT[] NOT NULL DEFAULT '{}',
+    alerts BOOLEAN NOT NULL DEFAULT FALSE,
+    last_checked_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);
+
+CREATE INDEX idx_saved_searches_alerts ON saved_searches(last_checked_at) WHERE alerts;
+
+-- Profiles a saved search already matched; alerts are only sent for others.
+CREATE TABLE IF NOT EXISTS saved_search_results (
+    search_id INTEGER NOT NULL REFERENCES saved_searches(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    matched_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (search_id, user_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS saved_search_results;
+DROP TABLE IF EXISTS saved_searches;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Trigram matches for /tags/search, and usage counts per tag (the primary
+-- key of user_tags starts with user_id).
+CREATE INDEX tags_name_trgm_idx ON tags USING GIN (name gin_trgm_ops);
+CREATE INDEX user_tags_tag_id_idx ON user_tags (tag_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS user_tags_tag_id_idx;
+DROP INDEX IF EXISTS tags_name_trgm_idx;
+DROP EXTENSION IF EXISTS pg_trgm;
+-- +goose StatementEnd
@@ -0,0 +1,92 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A tag alias is a former or alternative name of a tag, such as "hike" for
+-- "hiking". Aliases are stored normalized, like tag names.
+CREATE TABLE tag_aliases (
+    alias TEXT PRIMARY KEY,
+    tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE
+);
+CREATE INDEX tag_aliases_tag_id_idx ON tag_aliases (tag_id);
+
+-- canonical_tag_name returns the name of the tag a normalized name is an
+-- alias of, or the name itself.
+CREATE FUNCTION canonical_tag_name(tag TEXT) RETURNS TEXT
+LANGUAGE sql STABLE AS $$
+    SELECT COALESCE(
+        (SELECT t.name FROM tag_aliases a JOIN tags t ON t.id = a.tag_id WHERE a.alias = tag),
+        tag)
+$$;
+
+-- Tags created before names were normalized can differ only by case,
+-- surrounding spaces or a leading '#'. Each group is merged into its oldest
+-- tag, renamed to the normalized name.
+CREATE TEMP TABLE tag_merges AS
+SELECT id AS source_id, normalized,
+       MIN(id) OVER (PARTITION BY normalized) AS target_id
+FROM (SELECT id, LOWER(LTRIM(BTRIM(name), '#')) AS normalized FROM tags) t;
+
+INSERT INTO user_tags (user_id, tag_id)
+SELECT ut.user_id, m.target_id
+FROM user_tags ut JOIN tag_merges m ON m.source_id = ut.tag_id
+WHERE m.source_id <> m.target_id
+ON CONFLICT DO NOTHING;
+
+DELETE FROM tags WHERE id IN (SELECT source_id FROM tag_merges WHERE source_id <> target_id);
+
+UPDATE tags t SET name = m.normalized
+FROM tag_merges m
+WHERE t.id = m.target_id AND m.source_id = m.target_id AND t.name <> m.normalized;
+
+DROP TABLE tag_merges;
+
+-- Common synonyms, English and French, of the tags users pick most.
+CREATE TEMP TABLE tag_synonyms (alias TEXT PRIMARY KEY, canonical TEXT NOT NULL);
+INSERT INTO tag_synonyms (alias, canonical) VALUES
+    ('hike', 'hiking'),
+    ('randonnee', 'hiking'),
+    ('rando', 'hiking'),
+    ('voyage', 'travel'),
+    ('voyages', 'travel'),
+    ('cuisine', 'cooking'),
+    ('musique', 'music'),
+    ('sport', 'sports'),
+    ('lecture', 'reading'),
+    ('movie', 'movies'),
+    ('films', 'movies'),
+    ('cinema', 'movies'),
+    ('photo', 'photography'),
+    ('danse', 'dancing'),
+    ('programming', 'coding');
+
+INSERT INTO tags (name) SELECT DISTINCT canonical FROM tag_synonyms ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO user_tags (user_id, tag_id)
+SELECT ut.user_id, c.id
+FROM user_tags ut
+JOIN tags a ON a.id = ut.tag_id
+JOIN tag_synonyms s ON s.alias = a.name
+JOIN tags c ON c.name = s.canonical
+ON CONFLICT DO NOTHING;
+
+DELETE FROM tags WHERE name IN (SELECT alias FROM tag_synonyms);
+
+INSERT INTO tag_aliases (alias, tag_id)
+SELECT s.alias, c.id FROM tag_synonyms s JOIN tags c ON c.name = s.canonical;
+
+DROP TABLE tag_synonyms;
+
+-- Tags saved in preferences and searches follow the merges.
+UPDATE user_preferences
+SET required_tags = ARRAY(SELECT DISTINCT canonical_tag_name(tag) FROM UNNEST(required_tags) tag)
+WHERE required_tags <> '{}';
+UPDATE saved_searches
+SET tags = ARRAY(SELECT DISTINCT canonical_tag_name(tag) FROM UNNEST(tags) tag)
+WHERE tags <> '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Merged tags are not split again.
+DROP FUNCTION IF EXISTS canonical_tag_name(TEXT);
+DROP TABLE IF EXISTS tag_aliases;
+-- +goose StatementEnd
@@ -0,0 +1,67 @@
+-- +goose Up
+-- +goose StatementBegin
+-- searchable lets users stay out of GET /search; bio_language is the text
+-- search configuration their bio is stemmed with.
+ALTER TABLE users
+    ADD COLUMN searchable BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN bio_language TEXT NOT NULL DEFAULT 'simple'
+        CHECK (bio_language IN ('simple', 'english', 'french')),
+    ADD COLUMN search_vector TSVECTOR;
+
+-- user_search_vector weighs names (A) over tags (B) over the bio (C). The bio
+-- is indexed both as written and stemmed in its language, so that exact
+-- words and their variants match.
+CREATE FUNCTION user_search_vector(uid INTEGER, username TEXT, first_name TEXT, bio TEXT, bio_language TEXT)
+RETURNS TSVECTOR
+LANGUAGE sql STABLE AS $$
+    SELECT setweight(to_tsvector('simple', COALESCE(username, '') || ' ' || COALESCE(first_name, '')), 'A')
+        || setweight(to_tsvector('simple', COALESCE((
+               SELECT string_agg(t.name, ' ') FROM user_tags ut JOIN tags t ON t.id = ut.tag_id
+               WHERE ut.user_id = uid), '')), 'B')
+        || setweight(to_tsvector('simple', COALESCE(bio, '')), 'C')
+        || setweight(to_tsvector(bio_language::regconfig, COALESCE(bio, '')), 'C')
+$$;
+
+CREATE FUNCTION update_user_search_vector() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector := user_search_vector(NEW.id, NEW.username, NEW.first_name, NEW.bio, NEW.bio_language);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_user_search_vector
+BEFORE INSERT OR UPDATE OF username, first_name, bio, bio_language ON users
+FOR EACH ROW EXECUTE FUNCTION update_user_search_vector();
+
+CREATE FUNCTION update_user_search_vector_on_tags() RETURNS TRIGGER AS $$
+DECLARE
+    uid INTEGER := CASE WHEN TG_OP = 'DELETE' THEN OLD.user_id ELSE NEW.user_id END;
+BEGIN
+    UPDATE users
+    SET search_vector = user_search_vector(id, username, first_name, bio, bio_language)
+    WHERE id = uid;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_user_search_vector_on_tags
+AFTER INSERT OR DELETE ON user_tags
+FOR EACH ROW EXECUTE FUNCTION update_user_search_vector_on_tags();
+
+UPDATE users SET search_vector = user_search_vector(id, username, first_name, bio, bio_language);
+
+CREATE INDEX users_search_vector_idx ON users USING GIN (search_vector);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TRIGGER IF EXISTS trigger_user_search_vector_on_tags ON user_tags;
+DROP TRIGGER IF EXISTS trigger_user_search_vector ON users;
+DROP FUNCTION IF EXISTS update_user_search_vector_on_tags();
+DROP FUNCTION IF EXISTS update_user_search_vector();
+DROP FUNCTION IF EXISTS user_search_vector(INTEGER, TEXT, TEXT, TEXT, TEXT);
+ALTER TABLE users
+    DROP COLUMN IF EXISTS search_vector,
+    DROP COLUMN IF EXISTS bio_language,
+    DROP COLUMN IF EXISTS searchable;
+-- +goose StatementEnd
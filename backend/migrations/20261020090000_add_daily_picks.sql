@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+-- picks_expire_at is when the daily picks job next computes the user's picks.
+ALTER TABLE users ADD COLUMN picks_expire_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS daily_picks (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    pick_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    match_score DOUBLE PRECISION NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, created_at, pick_id)
+);
+
+CREATE INDEX idx_daily_picks_user_expiry ON daily_picks(user_id, expires_at DESC);
+CREATE INDEX idx_daily_picks_pick ON daily_picks(user_id, pick_id, created_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS daily_picks;
+ALTER TABLE users DROP COLUMN IF EXISTS picks_expire_at;
+-- +goose StatementEnd
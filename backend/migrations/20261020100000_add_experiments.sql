@@ -0,0 +1,62 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Ranking experiments. Suggestions are scored by one running experiment at a
+-- time (ended_at IS NULL); viewers are bucketed into its variants by weight.
+CREATE TABLE IF NOT EXISTS experiments (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(64) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    started_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    ended_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_experiments_running ON experiments((ended_at IS NULL)) WHERE ended_at IS NULL;
+
+-- A NULL scoring weight keeps the server's MATCH_WEIGHT_* value.
+CREATE TABLE IF NOT EXISTS experiment_variants (
+    experiment_id INTEGER NOT NULL REFERENCES experiments(id) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    position INTEGER NOT NULL,
+    weight INTEGER NOT NULL CHECK (weight > 0),
+    distance_weight DOUBLE PRECISION CHECK (distance_weight >= 0),
+    tags_weight DOUBLE PRECISION CHECK (tags_weight >= 0),
+    fame_weight DOUBLE PRECISION CHECK (fame_weight >= 0),
+    collaborative_weight DOUBLE PRECISION CHECK (collaborative_weight >= 0),
+    PRIMARY KEY (experiment_id, name)
+);
+
+-- The first time a profile was suggested to a viewer during an experiment.
+CREATE TABLE IF NOT EXISTS experiment_impressions (
+    experiment_id INTEGER NOT NULL,
+    variant VARCHAR(64) NOT NULL,
+    viewer_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    shown_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (experiment_id, viewer_id, shown_id),
+    FOREIGN KEY (experiment_id, variant) REFERENCES experiment_variants(experiment_id, name) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_experiment_impressions_pair ON experiment_impressions(viewer_id, shown_id);
+CREATE INDEX idx_experiment_impressions_variant ON experiment_impressions(experiment_id, variant);
+
+-- What the viewer did with a suggested profile, at most once per kind.
+CREATE TABLE IF NOT EXISTS experiment_outcomes (
+    experiment_id INTEGER NOT NULL,
+    viewer_id INTEGER NOT NULL,
+    shown_id INTEGER NOT NULL,
+    outcome VARCHAR(16) NOT NULL CHECK (outcome IN ('like', 'pass', 'match', 'message')),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (experiment_id, viewer_id, shown_id, outcome),
+    FOREIGN KEY (experiment_id, viewer_id, shown_id)
+        REFERENCES experiment_impressions(experiment_id, viewer_id, shown_id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS experiment_outcomes;
+DROP TABLE IF EXISTS experiment_impressions;
+DROP TABLE IF EXISTS experiment_variants;
+DROP TABLE IF EXISTS experiments;
+-- +goose StatementEnd
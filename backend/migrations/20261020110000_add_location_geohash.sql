@@ -0,0 +1,152 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Geohash of a location, as geo.Encode computes it.
+CREATE OR REPLACE FUNCTION geohash_encode(lat DOUBLE PRECISION, lon DOUBLE PRECISION, len INTEGER)
+RETURNS TEXT
+LANGUAGE plpgsql IMMUTABLE STRICT AS $$
+DECLARE
+    alphabet CONSTANT TEXT := '0123456789bcdefghjkmnpqrstuvwxyz';
+    min_lat DOUBLE PRECISION := -90;
+    max_lat DOUBLE PRECISION := 90;
+    min_lon DOUBLE PRECISION := -180;
+    max_lon DOUBLE PRECISION := 180;
+    mid DOUBLE PRECISION;
+    hash TEXT := '';
+    code INTEGER := 0;
+    nbits INTEGER := 0;
+    even BOOLEAN := TRUE;
+BEGIN
+    WHILE length(hash) < len LOOP
+        IF even THEN
+            mid := (min_lon + max_lon) / 2;
+            IF lon >= mid THEN
+                code := code * 2 + 1;
+                min_lon := mid;
+            ELSE
+                code := code * 2;
+                max_lon := mid;
+            END IF;
+        ELSE
+            mid := (min_lat + max_lat) / 2;
+            IF lat >= mid THEN
+                code := code * 2 + 1;
+                min_lat := mid;
+            ELSE
+                code := code * 2;
+                max_lat := mid;
+            END IF;
+        END IF;
+        even := NOT even;
+        nbits := nbits + 1;
+        IF nbits = 5 THEN
+            hash := hash || substr(alphabet, code + 1, 1);
+            code := 0;
+            nbits := 0;
+        END IF;
+    END LOOP;
+    RETURN hash;
+END;
+$$;
+
+-- Radius queries (geo.Within) select ranges of geohash prefixes, compared
+-- byte by byte.
+ALTER TABLE user_locations ADD COLUMN geohash VARCHAR(12) COLLATE "C";
+
+CREATE OR REPLACE FUNCTION set_location_geohash() RETURNS TRIGGER
+LANGUAGE plpgsql AS $$
+BEGIN
+    NEW.geohash := geohash_encode(NEW.lat, NEW.lon, 9);
+    RETURN NEW;
+END;
+$$;
+
+CREATE TRIGGER user_locations_geohash
+BEFORE INSERT OR UPDATE OF lat, lon ON user_locations
+FOR EACH ROW EXECUTE FUNCTION set_location_geohash();
+
+UPDATE user_locations SET geohash = geohash_encode(lat, lon, 9);
+ALTER TABLE user_locations ALTER COLUMN geohash SET NOT NULL;
+CREATE INDEX idx_user_locations_geohash ON user_locations(geohash);
+
+-- The geohash index replaces the latitude and longitude indexes, and the
+-- API no longer calls nearby_users, whose box broke at the antimeridian and
+-- the poles.
+DROP FUNCTION IF EXISTS nearby_users(double precision, double precision, double precision, integer);
+DROP INDEX IF EXISTS idx_user_locations_lat;
+DROP INDEX IF EXISTS idx_user_locations_lon;
+
+-- Rounding could take asin out of its domain for antipodal points.
+CREATE OR REPLACE FUNCTION haversine_km(
+    lat1 DOUBLE PRECISION, lon1 DOUBLE PRECISION,
+    lat2 DOUBLE PRECISION, lon2 DOUBLE PRECISION
+) RETURNS DOUBLE PRECISION
+LANGUAGE SQL IMMUTABLE AS $$
+  SELECT 2 * 6371.0 * asin(
+    LEAST(1, sqrt(
+      pow(sin(radians(($3 - $1) / 2)), 2) +
+      cos(radians($1)) * cos(radians($3)) *
+      pow(sin(radians(($4 - $2) / 2)), 2)
+    ))
+  );
+$$;
+
+-- With PostGIS installed, geography queries get a GiST index, which
+-- geo.DetectIndex picks up.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'postgis') THEN
+        EXECUTE 'CREATE INDEX idx_user_locations_geography ON user_locations
+            USING GIST ((ST_SetSRID(ST_MakePoint(lon, lat), 4326)::geography))';
+    END IF;
+END;
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_user_locations_geography;
+DROP TRIGGER IF EXISTS user_locations_geohash ON user_locations;
+DROP FUNCTION IF EXISTS set_location_geohash();
+DROP INDEX IF EXISTS idx_user_locations_geohash;
+ALTER TABLE user_locations DROP COLUMN IF EXISTS geohash;
+DROP FUNCTION IF EXISTS geohash_encode(DOUBLE PRECISION, DOUBLE PRECISION, INTEGER);
+
+CREATE INDEX IF NOT EXISTS idx_user_locations_lat ON user_locations(lat);
+CREATE INDEX IF NOT EXISTS idx_user_locations_lon ON user_locations(lon);
+
+CREATE OR REPLACE FUNCTION nearby_users(
+  lat0 double precision,
+  lon0 double precision,
+  r_km double precision,
+  lim integer DEFAULT 50
+)
+RETURNS TABLE (
+  user_id integer,
+  avatar_url text,
+  bio text,
+  lat double precision,
+  lon double precision,
+  accuracy_m double precision,
+  updated_at timestamp,
+  distance_km double precision
+)
+LANGUAGE sql STABLE AS $$
+  WITH bbox AS (
+    SELECT
+      (r_km / 111.32) AS dlat,
+      (r_km / (111.32 * GREATEST(0.0001, cos(radians(lat0))))) AS dlon
+  )
+  SELECT
+    u.id, u.avatar_url, u.bio,
+    l.lat, l.lon, l.accuracy_m, l.updated_at,
+    haversine_km(lat0, lon0, l.lat, l.lon) AS distance_km
+  FROM users u
+  JOIN user_locations l ON l.user_id = u.id
+  JOIN bbox b ON TRUE
+  WHERE l.lat BETWEEN lat0 - b.dlat AND lat0 + b.dlat
+    AND l.lon BETWEEN lon0 - b.dlon AND lon0 + b.dlon
+    AND haversine_km(lat0, lon0, l.lat, l.lon) <= r_km
+  ORDER BY distance_km
+  LIMIT lim;
+$$;
+-- +goose StatementEnd
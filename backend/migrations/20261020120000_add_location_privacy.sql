@@ -0,0 +1,91 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Other users only see a location snapped to the center of its cell in a
+-- grid of 0.018° (2km) cells. Each user's grid is shifted by a random
+-- fraction of a cell, drawn once, so the published position is stable: it
+-- only moves when the user changes cell, and comparing answers never
+-- narrows a location down further than its cell.
+ALTER TABLE user_locations
+    ADD COLUMN grid_offset_lat DOUBLE PRECISION NOT NULL DEFAULT random(),
+    ADD COLUMN grid_offset_lon DOUBLE PRECISION NOT NULL DEFAULT random();
+
+CREATE OR REPLACE FUNCTION fuzz_lat(lat DOUBLE PRECISION, off DOUBLE PRECISION)
+RETURNS DOUBLE PRECISION
+LANGUAGE SQL IMMUTABLE STRICT AS $$
+  SELECT LEAST(90, GREATEST(-90, (floor((lat + 90) / 0.018 - off) + off + 0.5) * 0.018 - 90));
+$$;
+
+-- Longitude cells keep a width of about 2km at the snapped latitude, and
+-- span every longitude by the poles.
+CREATE OR REPLACE FUNCTION fuzz_lon(
+    lat DOUBLE PRECISION, lon DOUBLE PRECISION,
+    off_lat DOUBLE PRECISION, off_lon DOUBLE PRECISION
+) RETURNS DOUBLE PRECISION
+LANGUAGE SQL IMMUTABLE STRICT AS $$
+  SELECT x - 360 * floor((x + 180) / 360)
+  FROM (SELECT LEAST(360, 0.018 / GREATEST(cos(radians(fuzz_lat(lat, off_lat))), 0.00005)) AS step) s,
+  LATERAL (SELECT (floor((lon + 180) / s.step - off_lon) + off_lon + 0.5) * s.step - 180 AS x) c;
+$$;
+
+ALTER TABLE user_locations
+    ADD COLUMN public_lat DOUBLE PRECISION
+        GENERATED ALWAYS AS (fuzz_lat(lat, grid_offset_lat)) STORED,
+    ADD COLUMN public_lon DOUBLE PRECISION
+        GENERATED ALWAYS AS (fuzz_lon(lat, lon, grid_offset_lat, grid_offset_lon)) STORED;
+
+-- Radius queries run on the published positions. BEFORE triggers do not
+-- see generated columns yet, hence the functions.
+CREATE OR REPLACE FUNCTION set_location_geohash() RETURNS TRIGGER
+LANGUAGE plpgsql AS $$
+BEGIN
+    NEW.geohash := geohash_encode(
+        fuzz_lat(NEW.lat, NEW.grid_offset_lat),
+        fuzz_lon(NEW.lat, NEW.lon, NEW.grid_offset_lat, NEW.grid_offset_lon), 9);
+    RETURN NEW;
+END;
+$$;
+
+UPDATE user_locations SET geohash = geohash_encode(public_lat, public_lon, 9);
+
+DROP INDEX IF EXISTS idx_user_locations_geography;
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'postgis') THEN
+        EXECUTE 'CREATE INDEX idx_user_locations_geography ON user_locations
+            USING GIST ((ST_SetSRID(ST_MakePoint(public_lon, public_lat), 4326)::geography))';
+    END IF;
+END;
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_user_locations_geography;
+
+CREATE OR REPLACE FUNCTION set_location_geohash() RETURNS TRIGGER
+LANGUAGE plpgsql AS $$
+BEGIN
+    NEW.geohash := geohash_encode(NEW.lat, NEW.lon, 9);
+    RETURN NEW;
+END;
+$$;
+
+ALTER TABLE user_locations
+    DROP COLUMN IF EXISTS public_lat,
+    DROP COLUMN IF EXISTS public_lon,
+    DROP COLUMN IF EXISTS grid_offset_lat,
+    DROP COLUMN IF EXISTS grid_offset_lon;
+DROP FUNCTION IF EXISTS fuzz_lon(DOUBLE PRECISION, DOUBLE PRECISION, DOUBLE PRECISION, DOUBLE PRECISION);
+DROP FUNCTION IF EXISTS fuzz_lat(DOUBLE PRECISION, DOUBLE PRECISION);
+
+UPDATE user_locations SET geohash = geohash_encode(lat, lon, 9);
+
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'postgis') THEN
+        EXECUTE 'CREATE INDEX idx_user_locations_geography ON user_locations
+            USING GIST ((ST_SetSRID(ST_MakePoint(lon, lat), 4326)::geography))';
+    END IF;
+END;
+$$;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Where a location comes from: the user's device, or the GeoIP fallback
+-- applied at login to users who never shared one.
+ALTER TABLE user_locations
+    ADD COLUMN source VARCHAR(16) NOT NULL DEFAULT 'device'
+        CHECK (source IN ('device', 'ip'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_locations DROP COLUMN IF EXISTS source;
+-- +goose StatementEnd
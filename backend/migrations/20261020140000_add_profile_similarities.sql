@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Item-item similarities behind user_recommendations: the cosine similarity
+-- of the likers of two profiles, for the most similar profiles of each.
+CREATE TABLE IF NOT EXISTS profile_similarities (
+    profile_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    similar_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    similarity DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (profile_id, similar_id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS profile_similarities;
+-- +goose StatementEnd
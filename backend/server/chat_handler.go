@@ -5,12 +5,17 @@ import (
 	"encoding/json"
 	"log"
 	"strconv"
+	"time"
+
+	"matcha/store"
 
 	"github.com/gin-gonic/gin"
 )
 
 func SendMessageHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		senderIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -18,10 +23,7 @@ func SendMessageHandler(db *sql.DB) gin.HandlerFunc {
 		}
 		senderID := senderIDVal.(int)
 
-		var request struct {
-			ReceiverID int    `json:"receiver_id" binding:"required"`
-			Content    string `json:"content" binding:"required"`
-		}
+		var request SendMessageRequest
 
 		if err := c.BindJSON(&request); err != nil {
 			c.JSON(400, gin.H{"error": "Invalid request"})
@@ -34,7 +36,7 @@ func SendMessageHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		var isConnected bool
-		err := db.QueryRow(`
+		err := db.QueryRowContext(ctx, `
 			SELECT EXISTS(
 				SELECT 1 FROM profile_likes WHERE liker_id = $1 AND liked_id = $2
 			) AND EXISTS(
@@ -53,7 +55,7 @@ func SendMessageHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		var isBlocked bool
-		err = db.QueryRow(`
+		err = db.QueryRowContext(ctx, `
 			SELECT EXISTS(
 				SELECT 1 FROM blocks WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
 			)
@@ -70,7 +72,7 @@ func SendMessageHandler(db *sql.DB) gin.HandlerFunc {
 
 		var messageID int
 		var createdAt string
-		err = db.QueryRow(`
+		err = db.QueryRowContext(ctx, `
 			INSERT INTO messages (sender_id, receiver_id, content) 
 			VALUES ($1, $2, $3)
 			RETURNING id, created_at
@@ -81,9 +83,10 @@ func SendMessageHandler(db *sql.DB) gin.HandlerFunc {
 			c.JSON(500, gin.H{"error": "Error sending message"})
 			return
 		}
+		recordExperimentOutcome(ctx, db, senderID, request.ReceiverID, store.OutcomeMessage)
 
 		var senderUsername, senderFirstName string
-		db.QueryRow("SELECT username, first_name FROM users WHERE id = $1", senderID).Scan(&senderUsername, &senderFirstName)
+		db.QueryRowContext(ctx, "SELECT username, first_name FROM users WHERE id = $1", senderID).Scan(&senderUsername, &senderFirstName)
 
 		chatMessage := map[string]interface{}{
 			"type":        "chat_message",
@@ -101,12 +104,14 @@ func SendMessageHandler(db *sql.DB) gin.HandlerFunc {
 
 		CreateAndPushNotification(db, request.ReceiverID, "message", senderID, "New message from "+senderFirstName)
 
-		c.JSON(200, gin.H{"message": "Message sent", "id": messageID})
+		c.JSON(200, SendMessageResponse{Message: "Message sent", ID: messageID})
 	}
 }
 
 func GetChatHistoryHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -122,7 +127,7 @@ func GetChatHistoryHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		var mutualLike bool
-		err = db.QueryRow(`
+		err = db.QueryRowContext(ctx, `
 			SELECT EXISTS(
 				SELECT 1 FROM profile_likes WHERE liker_id = $1 AND liked_id = $2
 			) AND EXISTS(
@@ -134,12 +139,22 @@ func GetChatHistoryHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		rows, err := db.Query(`
+		page, err := parsePageParams(c, "messages")
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Pages walk backwards from the newest message; each page is returned
+		// oldest first so it can be prepended to the conversation as is.
+		rows, err := db.QueryContext(ctx, `
 			SELECT id, sender_id, receiver_id, content, created_at, read_at
 			FROM messages
-			WHERE (sender_id = $1 AND receiver_id = $2) OR (sender_id = $2 AND receiver_id = $1)
-			ORDER BY created_at ASC
-		`, currentUserID, otherUserID)
+			WHERE ((sender_id = $1 AND receiver_id = $2) OR (sender_id = $2 AND receiver_id = $1))
+			  AND ($3::timestamp IS NULL OR (created_at, id) < ($3::timestamp, $4))
+			ORDER BY created_at DESC, id DESC
+			LIMIT $5
+		`, currentUserID, otherUserID, page.cursorTime(), page.cursorID(), page.Limit+1)
 
 		if err != nil {
 			log.Printf("Error fetching messages: %v", err)
@@ -148,35 +163,42 @@ func GetChatHistoryHandler(db *sql.DB) gin.HandlerFunc {
 		}
 		defer rows.Close()
 
-		messages := []gin.H{}
+		messages := []ChatMessage{}
+		var nextCursor *string
+		var oldestCreatedAt time.Time
 		for rows.Next() {
-			var id, senderID, receiverID int
-			var content, createdAt string
+			if len(messages) == page.Limit {
+				nextCursor = timeCursor("messages", oldestCreatedAt, messages[len(messages)-1].ID)
+				break
+			}
+
+			var msg ChatMessage
+			var createdAt time.Time
 			var readAt sql.NullString
 
-			if err := rows.Scan(&id, &senderID, &receiverID, &content, &createdAt, &readAt); err != nil {
+			if err := rows.Scan(&msg.ID, &msg.SenderID, &msg.ReceiverID, &msg.Content, &createdAt, &readAt); err != nil {
 				continue
 			}
-
-			msg := gin.H{
-				"id":          id,
-				"sender_id":   senderID,
-				"receiver_id": receiverID,
-				"content":     content,
-				"created_at":  createdAt,
-			}
+			msg.CreatedAt = createdAt.Format(time.RFC3339Nano)
 			if readAt.Valid {
-				msg["read_at"] = readAt.String
+				msg.ReadAt = &readAt.String
 			}
 			messages = append(messages, msg)
+			oldestCreatedAt = createdAt
+		}
+
+		for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+			messages[i], messages[j] = messages[j], messages[i]
 		}
 
-		c.JSON(200, gin.H{"messages": messages})
+		c.JSON(200, ChatHistoryResponse{Messages: messages, NextCursor: nextCursor})
 	}
 }
 
 func GetConversationsHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -184,7 +206,13 @@ func GetConversationsHandler(db *sql.DB) gin.HandlerFunc {
 		}
 		userID := userIDVal.(int)
 
-		rows, err := db.Query(`
+		page, err := parsePageParams(c, "conversations")
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		rows, err := db.QueryContext(ctx, `
 			SELECT u.id, u.username, u.first_name, u.last_name, u.avatar_url
 			FROM users u
 			JOIN profile_likes l1 ON l1.liker_id = $1 AND l1.liked_id = u.id
@@ -192,7 +220,10 @@ func GetConversationsHandler(db *sql.DB) gin.HandlerFunc {
 			WHERE NOT EXISTS (
 				SELECT 1 FROM blocks b WHERE (b.blocker_id = $1 AND b.blocked_id = u.id) OR (b.blocker_id = u.id AND b.blocked_id = $1)
 			)
-		`, userID)
+			  AND u.id > $2
+			ORDER BY u.id ASC
+			LIMIT $3
+		`, userID, page.cursorID(), page.Limit+1)
 
 		if err != nil {
 			log.Printf("Error fetching conversations: %v", err)
@@ -201,25 +232,24 @@ func GetConversationsHandler(db *sql.DB) gin.HandlerFunc {
 		}
 		defer rows.Close()
 
-		users := []gin.H{}
+		users := []UserSummary{}
+		var nextCursor *string
 		for rows.Next() {
-			var id int
-			var username, firstName, lastName string
+			if len(users) == page.Limit {
+				nextCursor = idCursor("conversations", users[len(users)-1].ID)
+				break
+			}
+
+			var user UserSummary
 			var avatarURL sql.NullString
-			if err := rows.Scan(&id, &username, &firstName, &lastName, &avatarURL); err == nil {
-				user := gin.H{
-					"id":         id,
-					"username":   username,
-					"first_name": firstName,
-					"last_name":  lastName,
-				}
+			if err := rows.Scan(&user.ID, &user.Username, &user.FirstName, &user.LastName, &avatarURL); err == nil {
 				if avatarURL.Valid {
-					user["avatar_url"] = avatarURL.String
+					user.AvatarURL = &avatarURL.String
 				}
 				users = append(users, user)
 			}
 		}
 
-		c.JSON(200, gin.H{"conversations": users})
+		c.JSON(200, ConversationsResponse{Conversations: users, NextCursor: nextCursor})
 	}
 }
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strconv"
+
+	"matcha/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultDeckSize = 10
+	maxDeckSize     = 50
+)
+
+// PassProfileHandler records that the current user is not interested in a
+// profile. Passed profiles leave the deck and suggestions until the pass is
+// undone.
+func PassProfileHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		passerIDVal, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+		passerID := passerIDVal.(int)
+
+		passedID, err := strconv.Atoi(c.Param("userId"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		if passerID == passedID {
+			c.JSON(400, gin.H{"error": "Cannot pass own profile"})
+			return
+		}
+
+		var userExists bool
+		err = db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", passedID).Scan(&userExists)
+		if err != nil {
+			log.Printf("Error checking user existence: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		if !userExists {
+			c.JSON(404, gin.H{"error": "User not found"})
+			return
+		}
+
+		// Passing again makes it the pass an undo restores.
+		_, err = db.ExecContext(ctx, `
+			INSERT INTO profile_passes (passer_id, passed_id) VALUES ($1, $2)
+			ON CONFLICT (passer_id, passed_id) DO UPDATE SET created_at = CURRENT_TIMESTAMP
+		`, passerID, passedID)
+		if err != nil {
+			log.Printf("Error passing profile: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		recordExperimentOutcome(ctx, db, passerID, passedID, store.OutcomePass)
+
+		c.JSON(200, MessageResponse{Message: "Profile passed"})
+	}
+}
+
+// UndoLastPassHandler removes the current user's most recent pass, putting
+// that profile back in the deck.
+func UndoLastPassHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userIDVal, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+		userID := userIDVal.(int)
+
+		var passedID int
+		err := db.QueryRowContext(ctx, `
+			DELETE FROM profile_passes
+			WHERE passer_id = $1 AND passed_id = (
+				SELECT passed_id FROM profile_passes
+				WHERE passer_id = $1
+				ORDER BY created_at DESC, passed_id DESC
+				LIMIT 1
+			)
+			RETURNING passed_id
+		`, userID).Scan(&passedID)
+		if err == sql.ErrNoRows {
+			c.JSON(404, gin.H{"error": "No pass to undo"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error undoing pass: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		c.JSON(200, UndoPassResponse{Message: "Pass undone", UserID: passedID})
+	}
+}
+
+// GetDeckHandler returns the best-scored profiles the current user has not
+// acted on yet: liked (which includes matches), passed and blocked profiles
+// are left out, and saved preferences filter it like suggestions. The deck
+// has no cursor; the next call after acting on a profile returns the
+// following ones.
+func GetDeckHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userIDVal, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+		userID := userIDVal.(int)
+
+		size := defaultDeckSize
+		if sizeStr := c.Query("limit"); sizeStr != "" {
+			v, err := strconv.Atoi(sizeStr)
+			if err != nil || v <= 0 {
+				c.JSON(400, gin.H{"error": "limit must be a positive integer"})
+				return
+			}
+			size = min(v, maxDeckSize)
+		}
+
+		viewer, err := loadSuggestionViewer(ctx, db, userID)
+		if err != nil {
+			log.Printf("Error fetching current user: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		preferences, err := loadDiscoveryPreferences(ctx, db, userID)
+		if err != nil {
+			log.Printf("Error fetching discovery preferences: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		var filters discoveryFilters
+		filters.applyPreferences(preferences)
+
+		conditions, args, err := suggestionConditions(userID, viewer, filters)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		conditions = append(conditions, notLikedCondition)
+
+		candidates, err := rankByMatch(ctx, db, userID, viewer, conditions, args)
+		if err != nil {
+			log.Printf("Error loading deck: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		remaining := max(len(candidates)-size, 0)
+		candidates = candidates[:min(size, len(candidates))]
+		c.JSON(200, DeckResponse{Users: suggestionUsers(candidates), Remaining: remaining})
+	}
+}
+
+// notLikedCondition leaves out the profiles the viewer bound to $1 liked,
+// which includes matches.
+var notLikedCondition = sqlCondition{Name: "not_liked",
+	SQL: "NOT EXISTS (SELECT 1 FROM profile_likes WHERE liker_id = $1 AND liked_id = u.id)"}
+
+// rankByMatch returns the profiles meeting conditions, scored for userID and
+// best matches first.
+func rankByMatch(ctx context.Context, db *sql.DB, userID int, viewer *suggestionViewer, conditions []sqlCondition, args []interface{}) ([]suggestion, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT`+suggestionColumns+`
+		FROM users u
+		LEFT JOIN user_locations u_loc ON u.id = u_loc.user_id
+		WHERE `+joinConditions(conditions), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates, err := scanSuggestions(rows, viewer.Lat, viewer.Lon)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := newViewerScoreProfile(ctx, db, userID, viewer.Lat, viewer.Lon, viewer.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if err := scoreSuggestions(ctx, db, candidates, matchScorer, profile); err != nil {
+		return nil, err
+	}
+	sortSuggestions(candidates, suggestionSort{Key: "match", Desc: true})
+	return candidates, nil
+}
+
+// suggestionViewer is what ranking candidates needs to know about the user
+// they are shown to.
+type suggestionViewer struct {
+	Lat, Lon sql.NullFloat64
+	Tags     []string
+}
+
+func loadSuggestionViewer(ctx context.Context, db *sql.DB, userID int) (*suggestionViewer, error) {
+	viewer := &suggestionViewer{}
+	err := db.QueryRowContext(ctx, `
+		SELECT l.lat, l.lon
+		FROM users u
+		LEFT JOIN user_locations l ON l.user_id = u.id
+		WHERE u.id = $1
+	`, userID).Scan(&viewer.Lat, &viewer.Lon)
+	if err != nil {
+		return nil, err
+	}
+
+	tags, err := loadUserTags(ctx, db, []int{userID})
+	if err != nil {
+		return nil, err
+	}
+	viewer.Tags = stringsOrEmpty(tags[userID])
+	return viewer, nil
+}
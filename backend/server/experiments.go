@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strconv"
+	"strings"
+
+	"matcha/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxExperimentNameLength = 64
+	maxExperimentVariants   = 10
+)
+
+// experimentAssignment is the variant of the running experiment a viewer is
+// in.
+type experimentAssignment struct {
+	ExperimentID int
+	Variant      string
+}
+
+// assignVariant buckets a user into one of the experiment's variants, with
+// a probability proportional to the variant weights. The bucket only depends
+// on the experiment name and the user ID, so a user stays in the same
+// variant for the whole experiment without it being stored.
+func assignVariant(experiment *store.Experiment, userID int) store.ExperimentVariant {
+	total := 0
+	for _, variant := range experiment.Variants {
+		total += variant.Weight
+	}
+	h := fnv.New64a()
+	h.Write([]byte(experiment.Name + ":" + strconv.Itoa(userID)))
+	bucket := int(h.Sum64() % uint64(total))
+	for _, variant := range experiment.Variants {
+		if bucket < variant.Weight {
+			return variant
+		}
+		bucket -= variant.Weight
+	}
+	return experiment.Variants[len(experiment.Variants)-1]
+}
+
+// variantScoringConfig is config with the variant's weights.
+func variantScoringConfig(config scoringConfig, variant ExperimentVariant) scoringConfig {
+	override := func(target *float64, weight *float64) {
+		if weight != nil {
+			*target = *weight
+		}
+	}
+	override(&config.DistanceWeight, variant.DistanceWeight)
+	override(&config.TagsWeight, variant.TagsWeight)
+	override(&config.FameWeight, variant.FameWeight)
+	override(&config.CollaborativeWeight, variant.CollaborativeWeight)
+	return config
+}
+
+// suggestionScorer returns the scorer of the user's variant in the running
+// experiment and the assignment, or matchScorer and nil when no experiment
+// is running.
+func suggestionScorer(ctx context.Context, db *sql.DB, userID int) (Scorer, *experimentAssignment, error) {
+	experiment, err := store.New(db).RunningExperiment(ctx)
+	if err != nil || experiment == nil || len(experiment.Variants) == 0 {
+		return matchScorer, nil, err
+	}
+	variant := assignVariant(experiment, userID)
+	config := variantScoringConfig(matchConfig, experimentVariantResponse(variant))
+	return newWeightedScorer(config), &experimentAssignment{ExperimentID: experiment.ID, Variant: variant.Name}, nil
+}
+
+// recordImpressions logs the suggestions shown to a viewer from position
+// firstPosition of their ranking. Failures are logged, not returned, so they
+// do not fail the suggestions.
+func recordImpressions(ctx context.Context, db *sql.DB, assignment *experimentAssignment, viewerID int, suggestions []suggestion, firstPosition int) {
+	if assignment == nil || len(suggestions) == 0 {
+		return
+	}
+	ids := make([]int, len(suggestions))
+	for i := range suggestions {
+		ids[i] = suggestions[i].user.ID
+	}
+	err := store.New(db).RecordImpressions(ctx, assignment.ExperimentID, assignment.Variant, viewerID, ids, firstPosition)
+	if err != nil {
+		log.Printf("Error recording experiment impressions for user %d: %v", viewerID, err)
+	}
+}
+
+// recordExperimentOutcome attributes what a viewer did with a profile to the
+// running experiment, if it suggested that profile to them.
+func recordExperimentOutcome(ctx context.Context, db *sql.DB, viewerID, shownID int, outcome string) {
+	if err := store.New(db).RecordOutcome(ctx, viewerID, shownID, outcome); err != nil {
+		log.Printf("Error recording experiment %s outcome for user %d: %v", outcome, viewerID, err)
+	}
+}
+
+func experimentVariantResponse(variant store.ExperimentVariant) ExperimentVariant {
+	return ExperimentVariant{
+		Name:                variant.Name,
+		Weight:              variant.Weight,
+		DistanceWeight:      variant.DistanceWeight,
+		TagsWeight:          variant.TagsWeight,
+		FameWeight:          variant.FameWeight,
+		CollaborativeWeight: variant.CollaborativeWeight,
+	}
+}
+
+func experimentResponse(experiment store.Experiment) Experiment {
+	response := Experiment{
+		ID:          experiment.ID,
+		Name:        experiment.Name,
+		Description: experiment.Description,
+		StartedAt:   experiment.StartedAt,
+		EndedAt:     experiment.EndedAt,
+		Variants:    make([]ExperimentVariant, len(experiment.Variants)),
+	}
+	for i, variant := range experiment.Variants {
+		response.Variants[i] = experimentVariantResponse(variant)
+	}
+	return response
+}
+
+// validateExperiment checks a new experiment and returns it with trimmed
+// names.
+func validateExperiment(request StartExperimentRequest) (store.Experiment, error) {
+	experiment := store.Experiment{
+		Name:        strings.TrimSpace(request.Name),
+		Description: strings.TrimSpace(request.Description),
+	}
+	if experiment.Name == "" || len(experiment.Name) > maxExperimentNameLength {
+		return experiment, fmt.Errorf("Name must be 1 to %d characters", maxExperimentNameLength)
+	}
+	if len(request.Variants) < 2 || len(request.Variants) > maxExperimentVariants {
+		return experiment, fmt.Errorf("An experiment needs 2 to %d variants", maxExperimentVariants)
+	}
+
+	seen := make(map[string]bool, len(request.Variants))
+	for _, variant := range request.Variants {
+		variant.Name = strings.TrimSpace(variant.Name)
+		if variant.Name == "" || len(variant.Name) > maxExperimentNameLength {
+			return experiment, fmt.Errorf("Variant names must be 1 to %d characters", maxExperimentNameLength)
+		}
+		if seen[variant.Name] {
+			return experiment, fmt.Errorf("Duplicate variant %s", variant.Name)
+		}
+		seen[variant.Name] = true
+		if variant.Weight <= 0 {
+			return experiment, fmt.Errorf("Variant %s: weight must be positive", variant.Name)
+		}
+		for _, weight := range []*float64{variant.DistanceWeight, variant.TagsWeight, variant.FameWeight, variant.CollaborativeWeight} {
+			if weight != nil && *weight < 0 {
+				return experiment, fmt.Errorf("Variant %s: scoring weights cannot be negative", variant.Name)
+			}
+		}
+		// As with MATCH_WEIGHT_*, the collaborative signal cannot be the
+		// only one.
+		config := variantScoringConfig(matchConfig, variant)
+		if config.DistanceWeight+config.TagsWeight+config.FameWeight == 0 {
+			return experiment, fmt.Errorf("Variant %s: distance, tags and fame weights cannot all be 0", variant.Name)
+		}
+
+		experiment.Variants = append(experiment.Variants, store.ExperimentVariant{
+			Name:                variant.Name,
+			Weight:              variant.Weight,
+			DistanceWeight:      variant.DistanceWeight,
+			TagsWeight:          variant.TagsWeight,
+			FameWeight:          variant.FameWeight,
+			CollaborativeWeight: variant.CollaborativeWeight,
+		})
+	}
+	return experiment, nil
+}
+
+// ListExperimentsHandler lists every experiment, latest first.
+func ListExperimentsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		experiments, err := store.New(db).Experiments(c.Request.Context())
+		if err != nil {
+			log.Printf("Error fetching experiments: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		response := ExperimentsResponse{Experiments: make([]Experiment, len(experiments))}
+		for i, experiment := range experiments {
+			response.Experiments[i] = experimentResponse(experiment)
+		}
+		c.JSON(200, response)
+	}
+}
+
+// StartExperimentHandler starts a ranking experiment. Only one runs at a
+// time.
+func StartExperimentHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var request StartExperimentRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request data"})
+			return
+		}
+		experiment, err := validateExperiment(request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		started, err := store.New(db).StartExperiment(ctx, experiment)
+		if errors.Is(err, store.ErrExperimentRunning) {
+			c.JSON(400, gin.H{"error": "Another experiment is running"})
+			return
+		}
+		if errors.Is(err, store.ErrExperimentExists) {
+			c.JSON(400, gin.H{"error": "Experiment name already taken"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error starting experiment: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		log.Printf("Admin %d started experiment %s", c.GetInt("userID"), started.Name)
+		c.JSON(200, experimentResponse(*started))
+	}
+}
+
+// EndExperimentHandler stops a running experiment. Suggestions go back to
+// the default weights; the report keeps what was recorded.
+func EndExperimentHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		experimentID, err := strconv.Atoi(c.Param("experimentId"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid experiment ID"})
+			return
+		}
+
+		err = store.New(db).EndExperiment(ctx, experimentID)
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(404, gin.H{"error": "Running experiment not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error ending experiment %d: %v", experimentID, err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		log.Printf("Admin %d ended experiment %d", c.GetInt("userID"), experimentID)
+		c.JSON(200, MessageResponse{Message: "Experiment ended"})
+	}
+}
+
+// ExperimentReportHandler reports impressions, outcomes and conversion
+// rates per variant.
+func ExperimentReportHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		experimentID, err := strconv.Atoi(c.Param("experimentId"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid experiment ID"})
+			return
+		}
+
+		st := store.New(db)
+		experiment, err := st.Experiment(ctx, experimentID)
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(404, gin.H{"error": "Experiment not found"})
+			return
+		}
+		var variants []store.VariantReport
+		if err == nil {
+			variants, err = st.ExperimentReport(ctx, experimentID)
+		}
+		if err != nil {
+			log.Printf("Error reporting experiment %d: %v", experimentID, err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		response := ExperimentReportResponse{
+			Experiment: experimentResponse(*experiment),
+			Variants:   make([]VariantReport, len(variants)),
+		}
+		for i, variant := range variants {
+			rate := func(count int) float64 {
+				if variant.Impressions == 0 {
+					return 0
+				}
+				return float64(count) / float64(variant.Impressions)
+			}
+			response.Variants[i] = VariantReport{
+				Variant:     variant.Variant,
+				Weight:      variant.Weight,
+				Viewers:     variant.Viewers,
+				Impressions: variant.Impressions,
+				Likes:       variant.Likes,
+				Passes:      variant.Passes,
+				Matches:     variant.Matches,
+				Messages:    variant.Messages,
+				LikeRate:    rate(variant.Likes),
+				PassRate:    rate(variant.Passes),
+				MatchRate:   rate(variant.Matches),
+				MessageRate: rate(variant.Messages),
+			}
+		}
+		c.JSON(200, response)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"matcha/store"
+)
+
+// fameHistoryLength is the number of past ratings the explain endpoint
+// returns.
+const fameHistoryLength = 30
+
+// ExplainFameHandler returns the fame formula with its parameters and the
+// components of a user's latest rating, as computed by the fame job.
+func ExplainFameHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userID, err := strconv.Atoi(c.Param("userId"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
+		var userExists bool
+		err = db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", userID).Scan(&userExists)
+		if err != nil {
+			log.Printf("Error checking user existence: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		if !userExists {
+			c.JSON(404, gin.H{"error": "User not found"})
+			return
+		}
+
+		response := FameExplanationResponse{
+			Formula: store.FameFormula,
+			Parameters: FameParameters{
+				HalfLifeDays: store.FameHalfLife.Hours() / 24,
+				PriorViews:   store.FamePriorViews,
+				PriorRate:    store.FamePriorRate,
+				Damping:      store.FameDamping,
+			},
+			History: []FameHistoryEntry{},
+		}
+
+		st := store.New(db)
+		score, err := st.FameScore(ctx, userID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			log.Printf("Error fetching fame score: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		if score != nil {
+			response.Score = &FameComponents{
+				Rating:        score.Rating,
+				Desirability:  score.Desirability,
+				WeightedLikes: score.WeightedLikes,
+				DecayedViews:  score.DecayedViews,
+				Likes:         score.Likes,
+				Views:         score.Views,
+				ComputedAt:    score.ComputedAt,
+			}
+		}
+
+		history, err := st.FameHistory(ctx, userID, fameHistoryLength)
+		if err != nil {
+			log.Printf("Error fetching fame history: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		for _, entry := range history {
+			response.History = append(response.History, FameHistoryEntry{Rating: entry.Rating, ComputedAt: entry.ComputedAt})
+		}
+
+		c.JSON(200, response)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// genderColumn and interestedInColumn select the gender name and the
+// interested-in gender names of users u.
+const (
+	genderColumn       = `(SELECT name FROM genders WHERE id = u.gender_id)`
+	interestedInColumn = `ARRAY(
+		SELECT g.name FROM user_interested_in ui JOIN genders g ON g.id = ui.gender_id
+		WHERE ui.user_id = u.id ORDER BY g.sort_order, g.id)`
+)
+
+// Mutual interest between the viewer, bound to $1, and users u, as set
+// membership: u's gender is among the viewer's interests, and the viewer's
+// gender among u's. A viewer who has not said who they are interested in
+// sees every gender, and one without a gender is matched as a Woman; a
+// profile without a gender or interests is not suggested.
+const (
+	viewerInterestedFilter = `u.gender_id IS NOT NULL AND (
+		u.gender_id IN (SELECT gender_id FROM user_interested_in WHERE user_id = $1)
+		OR NOT EXISTS (SELECT 1 FROM user_interested_in WHERE user_id = $1))`
+	interestedBackFilter = `EXISTS (
+		SELECT 1 FROM user_interested_in ui
+		JOIN users me ON me.id = $1
+		WHERE ui.user_id = u.id AND ui.gender_id = COALESCE(
+			me.gender_id, (SELECT id FROM genders WHERE name = 'Woman')))`
+)
+
+// legacyOrientations maps the orientation strings the API accepted before
+// genders were normalized to the matching interested-in sets.
+var legacyOrientations = map[string][]string{
+	"likes men":           {"Man"},
+	"likes women":         {"Woman"},
+	"likes men and women": {"Man", "Woman"},
+}
+
+// legacyOrientation returns the orientation string of an interested-in set,
+// or nil when no legacy string describes it.
+func legacyOrientation(interestedIn []string) *string {
+	set := make(map[string]bool, len(interestedIn))
+	for _, name := range interestedIn {
+		set[name] = true
+	}
+	for orientation, names := range legacyOrientations {
+		if len(names) != len(set) {
+			continue
+		}
+		matches := true
+		for _, name := range names {
+			matches = matches && set[name]
+		}
+		if matches {
+			return stringPtr(orientation)
+		}
+	}
+	return nil
+}
+
+// loadGenders returns the gender IDs by lower-cased name, and the names in
+// display order.
+func loadGenders(ctx context.Context, db *sql.DB) (map[string]int, []string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, name FROM genders ORDER BY sort_order, id")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[string]int)
+	names := []string{}
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, nil, err
+		}
+		ids[strings.ToLower(name)] = id
+		names = append(names, name)
+	}
+	return ids, names, rows.Err()
+}
+
+// resolveGenders returns the IDs of the named genders, matched case
+// insensitively, without duplicates.
+func resolveGenders(ids map[string]int, known []string, names []string) ([]int, error) {
+	resolved := []int{}
+	seen := make(map[int]bool)
+	for _, name := range names {
+		id, ok := ids[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("Gender must be one of: %s", strings.Join(known, ", "))
+		}
+		if !seen[id] {
+			seen[id] = true
+			resolved = append(resolved, id)
+		}
+	}
+	return resolved, nil
+}
+
+// setInterestedIn replaces the interested-in set of a user.
+func setInterestedIn(ctx context.Context, tx *sql.Tx, userID int, genderIDs []int) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_interested_in WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO user_interested_in (user_id, gender_id)
+		SELECT $1, UNNEST($2::int[])
+	`, userID, pq.Array(genderIDs))
+	return err
+}
+
+// ListGendersHandler returns the genders users can pick, in display order.
+func ListGendersHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, names, err := loadGenders(c.Request.Context(), db)
+		if err != nil {
+			log.Printf("Error fetching genders: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		c.JSON(200, GendersResponse{Genders: names})
+	}
+}
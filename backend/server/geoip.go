@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"math"
+	"net"
+	"os"
+
+	"matcha/geoip"
+)
+
+// geoIP locates users from their IP address when they never shared their
+// location. It is nil, and the fallback off, without GEOIP_DB_PATH.
+var geoIP *geoip.Reader
+
+// minIPAccuracyM is the least accuracy given to IP locations: a record's
+// own radius is optimistic, they are a city at best.
+const minIPAccuracyM = 10000
+
+// OpenGeoIP loads the MaxMind DB file (GeoLite2 City or compatible) at
+// GEOIP_DB_PATH.
+func OpenGeoIP() {
+	path := os.Getenv("GEOIP_DB_PATH")
+	if path == "" {
+		return
+	}
+	reader, err := geoip.Open(path)
+	if err != nil {
+		log.Printf("Error opening the GeoIP database, IP locations are off: %v", err)
+		return
+	}
+	geoIP = reader
+	log.Printf("IP locations use %s (%s)", path, reader.DatabaseType)
+}
+
+// applyIPLocation sets the location of username from ip when they have none,
+// or only a previous IP location. A location from their device is never
+// replaced. Errors are logged: the fallback must not fail the login.
+func applyIPLocation(ctx context.Context, db *sql.DB, username, ip string) {
+	if geoIP == nil {
+		return
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return
+	}
+	location, err := geoIP.Lookup(parsed)
+	if err != nil {
+		if !errors.Is(err, geoip.ErrNoLocation) {
+			log.Printf("Error looking up the location of %s: %v", ip, err)
+		}
+		return
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO user_locations (user_id, lat, lon, accuracy_m, source, updated_at)
+		SELECT id, $2, $3, $4, 'ip', NOW() FROM users WHERE username = $1
+		ON CONFLICT (user_id) DO UPDATE
+		SET lat = EXCLUDED.lat, lon = EXCLUDED.lon, accuracy_m = EXCLUDED.accuracy_m, updated_at = NOW()
+		WHERE user_locations.source = 'ip'
+	`, username, location.Lat, location.Lon, math.Max(location.AccuracyKm*1000, minIPAccuracyM))
+	if err != nil {
+		log.Printf("Error setting the IP location of %s: %v", username, err)
+	}
+}
@@ -4,8 +4,9 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"matcha/geo"
+	"matcha/store"
 	"matcha/utils"
-	"math"
 	"regexp"
 	"strconv"
 	"strings"
@@ -16,45 +17,9 @@ import (
 )
 
 func haversineDistance(lat1, lon1, lat2, lon2 float64) float64 {
-	const earthRadiusKm = 6371.0
-
-	dLat := (lat2 - lat1) * math.Pi / 180.0
-	dLon := (lon2 - lon1) * math.Pi / 180.0
-
-	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
-		math.Cos(lat1*math.Pi/180.0)*math.Cos(lat2*math.Pi/180.0)*
-			math.Sin(dLon/2)*math.Sin(dLon/2)
-
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-
-	return earthRadiusKm * c
-}
-
-func calculateMatchScore(currentUserLat, currentUserLon, targetLat, targetLon float64, currentUserTags, targetTags []string, fameRating float64) float64 {
-	distance := haversineDistance(currentUserLat, currentUserLon, targetLat, targetLon)
-	distanceScore := math.Max(0.0, 100.0-(distance*0.2))
-	commonCount := 0
-	seenTags := make(map[string]bool)
-	for _, tag := range currentUserTags {
-		seenTags[tag] = true
-	}
-	for _, tag := range targetTags {
-		if seenTags[tag] {
-			commonCount++
-		}
-	}
-	totalTags := len(currentUserTags) + len(targetTags) - commonCount
-	tagsScore := 0.0
-	if totalTags > 0 {
-		tagsScore = (float64(commonCount) / float64(totalTags)) * 100.0
-	}
-
-	fScore := fameRating
-
-	return (0.5 * distanceScore) + (0.3 * tagsScore) + (0.2 * fScore)
+	return geo.DistanceKm(geo.Point{Lat: lat1, Lon: lon1}, geo.Point{Lat: lat2, Lon: lon2})
 }
 
-
 const maxUserTags = 20
 
 var tagNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,29}$`)
@@ -79,6 +44,8 @@ func normalizeTagName(raw string) (string, error) {
 
 func RegisterHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		username := c.PostForm("username")
 		password := c.PostForm("password")
 		email := c.PostForm("email")
@@ -108,7 +75,7 @@ func RegisterHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		var exists bool
-		err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE username = $1 OR email = $2)", username, email).Scan(&exists)
+		err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE username = $1 OR email = $2)", username, email).Scan(&exists)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Database error while checking user existence", "details": err.Error()})
 			return
@@ -129,7 +96,7 @@ func RegisterHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		_, err = db.Exec(
+		_, err = db.ExecContext(ctx, 
 			`INSERT INTO users (email, password_hash, first_name, last_name, username, birthday) 
          VALUES ($1, $2, $3, $4, $5, $6)`,
 			email, hashedPassword, firstName, lastName, username, birthday,
@@ -139,20 +106,22 @@ func RegisterHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		utils.SendVerificationEmail(email, db)
+		utils.SendVerificationEmail(ctx, email, db)
 
-		c.JSON(200, gin.H{"message": "User registered successfully"})
+		c.JSON(200, MessageResponse{Message: "User registered successfully"})
 	}
 
 }
 
 func LoginHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		username := c.PostForm("username")
 		password := c.PostForm("password")
 
 		var exists bool
-		err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)", username).Scan(&exists)
+		err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE username = $1)", username).Scan(&exists)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Database error while checking user existence", "details": err.Error()})
 			return
@@ -163,7 +132,7 @@ func LoginHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		var storedHashedPassword string
-		err = db.QueryRow("SELECT password_hash FROM users WHERE username = $1", username).Scan(&storedHashedPassword)
+		err = db.QueryRowContext(ctx, "SELECT password_hash FROM users WHERE username = $1", username).Scan(&storedHashedPassword)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Database error while retrieving user password", "details": err.Error()})
 			return
@@ -175,7 +144,7 @@ func LoginHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		var isVerified bool
-		err = db.QueryRow("SELECT verified FROM users WHERE username = $1", username).Scan(&isVerified)
+		err = db.QueryRowContext(ctx, "SELECT verified FROM users WHERE username = $1", username).Scan(&isVerified)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Database error while checking verification status", "details": err.Error()})
 			return
@@ -185,61 +154,82 @@ func LoginHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		var isBanned bool
+		err = db.QueryRowContext(ctx, "SELECT banned_at IS NOT NULL FROM users WHERE username = $1", username).Scan(&isBanned)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Database error while checking account status", "details": err.Error()})
+			return
+		}
+		if isBanned {
+			c.JSON(403, gin.H{"error": "User account is banned"})
+			return
+		}
+
 		sessionToken := utils.GenerateToken()
 
-		_, err = db.Exec("UPDATE users SET session_token = $1 WHERE username = $2", sessionToken, username)
+		_, err = db.ExecContext(ctx, "UPDATE users SET session_token = $1 WHERE username = $2", sessionToken, username)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Error updating session token", "details": err.Error()})
 			return
 		}
 
+		// ClientIP only follows X-Forwarded-For from TRUSTED_PROXIES, so
+		// users cannot pick the address they are located from.
+		applyIPLocation(ctx, db, username, c.ClientIP())
+
 		c.SetCookie("session_token", sessionToken, 3600*24, "/", "", false, true)
-		c.JSON(200, gin.H{"message": "Login successful"})
+		c.JSON(200, MessageResponse{Message: "Login successful"})
 	}
 }
 
 func VerifyHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		token := c.Query("token")
 
 		var email string
-		err := db.QueryRow("SELECT email FROM users WHERE verification_token = $1", token).Scan(&email)
+		err := db.QueryRowContext(ctx, "SELECT email FROM users WHERE verification_token = $1", token).Scan(&email)
 		if err != nil {
 			c.JSON(400, gin.H{"error": "Invalid or expired verification token"})
 			return
 		}
 
-		_, err = db.Exec("UPDATE users SET verified = TRUE, verification_token = NULL WHERE email = $1", email)
+		_, err = db.ExecContext(ctx, "UPDATE users SET verified = TRUE, verification_token = NULL WHERE email = $1", email)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Error updating user verification status", "details": err.Error()})
 			return
 		}
 
-		c.JSON(200, gin.H{"message": "Email verified successfully"})
+		c.JSON(200, MessageResponse{Message: "Email verified successfully"})
 	}
 }
 
 func LogoutHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		sessionToken, err := c.Cookie("session_token")
 		if err != nil {
 			c.JSON(400, gin.H{"error": "Not logged in"})
 			return
 		}
 
-		_, err = db.Exec("UPDATE users SET session_token = NULL WHERE session_token = $1", sessionToken)
+		_, err = db.ExecContext(ctx, "UPDATE users SET session_token = NULL WHERE session_token = $1", sessionToken)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Error logging out", "details": err.Error()})
 			return
 		}
 
 		c.SetCookie("session_token", "", -1, "/", "", false, true)
-		c.JSON(200, gin.H{"message": "Logout successful"})
+		c.JSON(200, MessageResponse{Message: "Logout successful"})
 	}
 }
 
 func PostTagHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -259,7 +249,7 @@ func PostTagHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		var userTagCount int
-		err := db.QueryRow("SELECT COUNT(*) FROM user_tags WHERE user_id = $1", userID).Scan(&userTagCount)
+		err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM user_tags WHERE user_id = $1", userID).Scan(&userTagCount)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Database error", "details": err.Error()})
 			return
@@ -269,34 +259,20 @@ func PostTagHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		var tagID int
-		err = db.QueryRow("SELECT id FROM tags WHERE name = $1", normalizedTagName).Scan(&tagID)
-		if err == sql.ErrNoRows {
-			err = db.QueryRow("INSERT INTO tags (name) VALUES ($1) RETURNING id", normalizedTagName).Scan(&tagID)
-			if err != nil {
-				c.JSON(500, gin.H{"error": "Error creating tag", "details": err.Error()})
-				return
-			}
-		} else if err != nil {
-			c.JSON(500, gin.H{"error": "Database error", "details": err.Error()})
-			return
-		}
-
-		_, err = db.Exec(
-			"INSERT INTO user_tags (user_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING",
-			userID, tagID,
-		)
+		_, err = db.ExecContext(ctx, addUserTagQuery, userID, normalizedTagName)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Error assigning tag to user", "details": err.Error()})
 			return
 		}
 
-		c.JSON(200, gin.H{"message": "Tag created and assigned successfully"})
+		c.JSON(200, MessageResponse{Message: "Tag created and assigned successfully"})
 	}
 }
 
 func GetUserTagsHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -308,23 +284,17 @@ func GetUserTagsHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		rows, err := db.Query("SELECT t.id, t.name FROM tags t JOIN user_tags ut ON t.id = ut.tag_id WHERE ut.user_id = $1", userID)
+		rows, err := db.QueryContext(ctx, "SELECT t.id, t.name FROM tags t JOIN user_tags ut ON t.id = ut.tag_id WHERE ut.user_id = $1", userID)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Error fetching user tags", "details": err.Error()})
 			return
 		}
 		defer rows.Close()
 
-		var tags []struct {
-			ID   int    `json:"id"`
-			Name string `json:"name"`
-		}
+		var tags []TagItem
 
 		for rows.Next() {
-			var tag struct {
-				ID   int    `json:"id"`
-				Name string `json:"name"`
-			}
+			var tag TagItem
 			if err := rows.Scan(&tag.ID, &tag.Name); err != nil {
 				c.JSON(500, gin.H{"error": "Error scanning user tag", "details": err.Error()})
 				return
@@ -332,12 +302,14 @@ func GetUserTagsHandler(db *sql.DB) gin.HandlerFunc {
 			tags = append(tags, tag)
 		}
 
-		c.JSON(200, gin.H{"tags": tags})
+		c.JSON(200, TagsResponse{Tags: tags})
 	}
 }
 
 func DeleteTagHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -357,7 +329,7 @@ func DeleteTagHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		var tagID int
-		err := db.QueryRow("SELECT id FROM tags WHERE name = $1", normalizedTagName).Scan(&tagID)
+		err := db.QueryRowContext(ctx, "SELECT id FROM tags WHERE name = canonical_tag_name($1)", normalizedTagName).Scan(&tagID)
 		if err == sql.ErrNoRows {
 			c.JSON(404, gin.H{"error": "Tag not found"})
 			return
@@ -367,7 +339,7 @@ func DeleteTagHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		var existsForUser bool
-		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM user_tags WHERE user_id = $1 AND tag_id = $2)", userID, tagID).Scan(&existsForUser)
+		err = db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM user_tags WHERE user_id = $1 AND tag_id = $2)", userID, tagID).Scan(&existsForUser)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Database error while checking user tag existence", "details": err.Error()})
 			return
@@ -377,18 +349,20 @@ func DeleteTagHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		_, err = db.Exec("DELETE FROM user_tags WHERE user_id = $1 AND tag_id = $2", userID, tagID)
+		_, err = db.ExecContext(ctx, "DELETE FROM user_tags WHERE user_id = $1 AND tag_id = $2", userID, tagID)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Error deleting user tag association", "details": err.Error()})
 			return
 		}
 
-		c.JSON(200, gin.H{"message": "User tag deleted successfully"})
+		c.JSON(200, MessageResponse{Message: "User tag deleted successfully"})
 	}
 }
 
 func UpdateLocationHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -400,11 +374,7 @@ func UpdateLocationHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		var request struct {
-			Latitude  float64  `json:"latitude" binding:"required"`
-			Longitude float64  `json:"longitude" binding:"required"`
-			Accuracy  *float64 `json:"accuracy"`
-		}
+		var request UpdateLocationRequest
 
 		if err := c.ShouldBindJSON(&request); err != nil {
 			c.JSON(400, gin.H{"error": "Invalid request", "details": err.Error()})
@@ -421,24 +391,24 @@ func UpdateLocationHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		query := `
-			INSERT INTO user_locations (user_id, lat, lon, accuracy_m, updated_at) 
-			VALUES ($1, $2, $3, $4, NOW())
+			INSERT INTO user_locations (user_id, lat, lon, accuracy_m, source, updated_at) 
+			VALUES ($1, $2, $3, $4, 'device', NOW())
 			ON CONFLICT (user_id) 
-			DO UPDATE SET lat = $2, lon = $3, accuracy_m = $4, updated_at = NOW()
+			DO UPDATE SET lat = $2, lon = $3, accuracy_m = $4, source = 'device', updated_at = NOW()
 		`
-		_, err := db.Exec(query, userID, request.Latitude, request.Longitude, request.Accuracy)
+		_, err := db.ExecContext(ctx, query, userID, request.Latitude, request.Longitude, request.Accuracy)
 		if err != nil {
 			log.Printf("Error updating location: %v", err)
 			c.JSON(500, gin.H{"error": "Error updating location", "details": err.Error()})
 			return
 		}
 
-		c.JSON(200, gin.H{
-			"message": "Location updated successfully",
-			"location": gin.H{
-				"latitude":  request.Latitude,
-				"longitude": request.Longitude,
-				"accuracy":  request.Accuracy,
+		c.JSON(200, UpdateLocationResponse{
+			Message: "Location updated successfully",
+			Location: LocationPayload{
+				Latitude:  request.Latitude,
+				Longitude: request.Longitude,
+				Accuracy:  request.Accuracy,
 			},
 		})
 	}
@@ -446,6 +416,19 @@ func UpdateLocationHandler(db *sql.DB) gin.HandlerFunc {
 
 func GetUserLocationHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userIDVal, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+		userID, ok := userIDVal.(int)
+		if !ok {
+			c.JSON(500, gin.H{"error": "Invalid user ID"})
+			return
+		}
+
 		userIDStr := c.Param("userId")
 		targetUserID, err := strconv.Atoi(userIDStr)
 		if err != nil {
@@ -453,17 +436,13 @@ func GetUserLocationHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		var location struct {
-			Latitude  float64  `json:"latitude"`
-			Longitude float64  `json:"longitude"`
-			Accuracy  *float64 `json:"accuracy"`
-			UpdatedAt string   `json:"updated_at"`
-		}
+		var location UserLocation
+		var publicLat, publicLon float64
 
-		err = db.QueryRow(
-			"SELECT lat, lon, accuracy_m, updated_at FROM user_locations WHERE user_id = $1",
+		err = db.QueryRowContext(ctx, 
+			"SELECT lat, lon, accuracy_m, public_lat, public_lon, source, updated_at FROM user_locations WHERE user_id = $1",
 			targetUserID,
-		).Scan(&location.Latitude, &location.Longitude, &location.Accuracy, &location.UpdatedAt)
+		).Scan(&location.Latitude, &location.Longitude, &location.Accuracy, &publicLat, &publicLon, &location.Source, &location.UpdatedAt)
 
 		if err == sql.ErrNoRows {
 			c.JSON(404, gin.H{"error": "Location not found for this user"})
@@ -473,12 +452,25 @@ func GetUserLocationHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(200, gin.H{"location": location})
+		// Only the owner gets their exact location; others get the
+		// published one, without its accuracy, source or time of the last
+		// update, which tells when they moved or were last active.
+		if targetUserID != userID {
+			location.Latitude = publicLat
+			location.Longitude = publicLon
+			location.Accuracy = nil
+			location.Source = nil
+			location.UpdatedAt = nil
+		}
+
+		c.JSON(200, UserLocationResponse{Location: location})
 	}
 }
 
 func GetNearbyUsersHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -491,7 +483,7 @@ func GetNearbyUsersHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		var myLat, myLon float64
-		err := db.QueryRow(
+		err := db.QueryRowContext(ctx, 
 			"SELECT lat, lon FROM user_locations WHERE user_id = $1",
 			userID,
 		).Scan(&myLat, &myLon)
@@ -518,28 +510,23 @@ func GetNearbyUsersHandler(db *sql.DB) gin.HandlerFunc {
 			}
 		}
 
-		rows, err := db.Query(
-			"SELECT user_id, avatar_url, bio, lat, lon, accuracy_m, updated_at, distance_km FROM nearby_users($1, $2, $3, $4)",
-			myLat, myLon, radiusKm, limit,
-		)
+		within, args := spatialIndex.Within("l", geo.Point{Lat: myLat, Lon: myLon}, radiusKm, []interface{}{myLat, myLon, userID, limit})
+		rows, err := db.QueryContext(ctx, `
+			SELECT u.id, u.avatar_url, u.bio, l.public_lat, l.public_lon,
+			       haversine_km($1, $2, l.public_lat, l.public_lon) AS distance_km
+			FROM users u
+			JOIN user_locations l ON l.user_id = u.id
+			WHERE u.id <> $3 AND `+within+`
+			ORDER BY distance_km
+			LIMIT $4
+		`, args...)
 		if err != nil {
-			log.Printf("Error calling nearby_users: %v", err)
+			log.Printf("Error finding nearby users: %v", err)
 			c.JSON(500, gin.H{"error": "Error finding nearby users", "details": err.Error()})
 			return
 		}
 		defer rows.Close()
 
-		type NearbyUser struct {
-			UserID     int      `json:"user_id"`
-			AvatarURL  *string  `json:"avatar_url"`
-			Bio        *string  `json:"bio"`
-			Latitude   float64  `json:"latitude"`
-			Longitude  float64  `json:"longitude"`
-			Accuracy   *float64 `json:"accuracy"`
-			UpdatedAt  string   `json:"updated_at"`
-			DistanceKm float64  `json:"distance_km"`
-		}
-
 		var nearbyUsers []NearbyUser
 		for rows.Next() {
 			var user NearbyUser
@@ -549,26 +536,23 @@ func GetNearbyUsersHandler(db *sql.DB) gin.HandlerFunc {
 				&user.Bio,
 				&user.Latitude,
 				&user.Longitude,
-				&user.Accuracy,
-				&user.UpdatedAt,
 				&user.DistanceKm,
 			)
 			if err != nil {
 				log.Printf("Error scanning nearby user: %v", err)
 				continue
 			}
-			if user.UserID != userID {
-				nearbyUsers = append(nearbyUsers, user)
-			}
+			user.DistanceKm = geo.RoundDistanceKm(user.DistanceKm)
+			nearbyUsers = append(nearbyUsers, user)
 		}
 
-		c.JSON(200, gin.H{
-			"nearby_users": nearbyUsers,
-			"count":        len(nearbyUsers),
-			"radius_km":    radiusKm,
-			"your_location": gin.H{
-				"latitude":  myLat,
-				"longitude": myLon,
+		c.JSON(200, NearbyUsersResponse{
+			NearbyUsers: nearbyUsers,
+			Count:       len(nearbyUsers),
+			RadiusKm:    radiusKm,
+			YourLocation: Coordinates{
+				Latitude:  myLat,
+				Longitude: myLon,
 			},
 		})
 	}
@@ -576,6 +560,8 @@ func GetNearbyUsersHandler(db *sql.DB) gin.HandlerFunc {
 
 func RequestPasswordResetHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		email := strings.TrimSpace(c.PostForm("email"))
 
 		if email == "" {
@@ -590,9 +576,9 @@ func RequestPasswordResetHandler(db *sql.DB) gin.HandlerFunc {
 
 		var userID int
 		var username string
-		err := db.QueryRow("SELECT id, username FROM users WHERE email = $1", email).Scan(&userID, &username)
+		err := db.QueryRowContext(ctx, "SELECT id, username FROM users WHERE email = $1", email).Scan(&userID, &username)
 		if err == sql.ErrNoRows {
-			c.JSON(200, gin.H{"message": "If this email exists, a password reset link has been sent"})
+			c.JSON(200, MessageResponse{Message: "If this email exists, a password reset link has been sent"})
 			return
 		} else if err != nil {
 			log.Printf("Error checking user: %v", err)
@@ -603,7 +589,7 @@ func RequestPasswordResetHandler(db *sql.DB) gin.HandlerFunc {
 		resetToken := utils.GenerateToken()
 		expiresAt := time.Now().Add(1 * time.Hour)
 
-		_, err = db.Exec(
+		_, err = db.ExecContext(ctx, 
 			"UPDATE users SET reset_token = $1, reset_token_expires_at = $2 WHERE id = $3",
 			resetToken, expiresAt, userID,
 		)
@@ -620,12 +606,16 @@ func RequestPasswordResetHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(200, gin.H{"message": "If this email exists, a password reset link has been sent"})
+		c.JSON(200, MessageResponse{Message: "If this email exists, a password reset link has been sent"})
 	}
 }
 
 func ResetPasswordHandler(db *sql.DB) gin.HandlerFunc {
+	st := store.New(db)
+
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		token := c.PostForm("token")
 		newPassword := c.PostForm("password")
 
@@ -641,7 +631,7 @@ func ResetPasswordHandler(db *sql.DB) gin.HandlerFunc {
 
 		var userID int
 		var expiresAt time.Time
-		err := db.QueryRow(
+		err := db.QueryRowContext(ctx, 
 			"SELECT id, reset_token_expires_at FROM users WHERE reset_token = $1",
 			token,
 		).Scan(&userID, &expiresAt)
@@ -666,22 +656,21 @@ func ResetPasswordHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		_, err = db.Exec(
-			"UPDATE users SET password_hash = $1, reset_token = NULL, reset_token_expires_at = NULL WHERE id = $2",
-			hashedPassword, userID,
-		)
+		err = st.SetPasswordHash(ctx, userID, hashedPassword)
 		if err != nil {
 			log.Printf("Error updating password: %v", err)
 			c.JSON(500, gin.H{"error": "Error updating password"})
 			return
 		}
 
-		c.JSON(200, gin.H{"message": "Password reset successfully"})
+		c.JSON(200, MessageResponse{Message: "Password reset successfully"})
 	}
 }
 
 func RecordProfileViewHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDInterface, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -697,11 +686,11 @@ func RecordProfileViewHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		if viewerID == viewedID {
-			c.JSON(200, gin.H{"message": "Cannot view own profile"})
+			c.JSON(200, MessageResponse{Message: "Cannot view own profile"})
 			return
 		}
 
-		result, err := db.Exec(
+		result, err := db.ExecContext(ctx, 
 			`INSERT INTO profile_views (viewer_id, viewed_id) 
 			VALUES ($1, $2) 
 			ON CONFLICT (viewer_id, viewed_id) DO NOTHING`,
@@ -716,16 +705,18 @@ func RecordProfileViewHandler(db *sql.DB) gin.HandlerFunc {
 		rowsAffected, _ := result.RowsAffected()
 		if rowsAffected > 0 {
 			var viewerName string
-			db.QueryRow("SELECT first_name FROM users WHERE id = $1", viewerID).Scan(&viewerName)
+			db.QueryRowContext(ctx, "SELECT first_name FROM users WHERE id = $1", viewerID).Scan(&viewerName)
 			CreateAndPushNotification(db, viewedID, "visit", viewerID, viewerName+" viewed your profile")
 		}
 
-		c.JSON(200, gin.H{"message": "View recorded successfully"})
+		c.JSON(200, MessageResponse{Message: "View recorded successfully"})
 	}
 }
 
 func ToggleProfileLikeHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		likerIDVal, userExists := c.Get("userID")
 		if !userExists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -750,7 +741,7 @@ func ToggleProfileLikeHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		var hasProfilePicture bool
-		err = db.QueryRow(
+		err = db.QueryRowContext(ctx, 
 			"SELECT EXISTS(SELECT 1 FROM user_images WHERE user_id = $1 AND is_profile_picture = TRUE)",
 			likerID,
 		).Scan(&hasProfilePicture)
@@ -765,10 +756,10 @@ func ToggleProfileLikeHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		var likerName string
-		db.QueryRow("SELECT first_name FROM users WHERE id = $1", likerID).Scan(&likerName)
+		db.QueryRowContext(ctx, "SELECT first_name FROM users WHERE id = $1", likerID).Scan(&likerName)
 
 		var likeExists bool
-		err = db.QueryRow(
+		err = db.QueryRowContext(ctx, 
 			"SELECT EXISTS(SELECT 1 FROM profile_likes WHERE liker_id = $1 AND liked_id = $2)",
 			likerID, likedID,
 		).Scan(&likeExists)
@@ -780,12 +771,12 @@ func ToggleProfileLikeHandler(db *sql.DB) gin.HandlerFunc {
 
 		if likeExists {
 			var wasConnected bool
-			db.QueryRow(
+			db.QueryRowContext(ctx, 
 				"SELECT EXISTS(SELECT 1 FROM profile_likes WHERE liker_id = $1 AND liked_id = $2)",
 				likedID, likerID,
 			).Scan(&wasConnected)
 
-			_, err = db.Exec(
+			_, err = db.ExecContext(ctx, 
 				"DELETE FROM profile_likes WHERE liker_id = $1 AND liked_id = $2",
 				likerID, likedID,
 			)
@@ -799,9 +790,9 @@ func ToggleProfileLikeHandler(db *sql.DB) gin.HandlerFunc {
 				CreateAndPushNotification(db, likedID, "unlike", likerID, likerName+" unliked you")
 			}
 
-			c.JSON(200, gin.H{"message": "Like removed", "liked": false})
+			c.JSON(200, LikeToggleResponse{Message: "Like removed", Liked: false})
 		} else {
-			_, err = db.Exec(
+			_, err = db.ExecContext(ctx, 
 				"INSERT INTO profile_likes (liker_id, liked_id) VALUES ($1, $2)",
 				likerID, likedID,
 			)
@@ -810,29 +801,35 @@ func ToggleProfileLikeHandler(db *sql.DB) gin.HandlerFunc {
 				c.JSON(500, gin.H{"error": "Error adding like"})
 				return
 			}
+			recordExperimentOutcome(ctx, db, likerID, likedID, store.OutcomeLike)
 
 			var isMatch bool
-			db.QueryRow(
+			db.QueryRowContext(ctx, 
 				"SELECT EXISTS(SELECT 1 FROM profile_likes WHERE liker_id = $1 AND liked_id = $2)",
 				likedID, likerID,
 			).Scan(&isMatch)
 
 			if isMatch {
 				var likedName string
-				db.QueryRow("SELECT first_name FROM users WHERE id = $1", likedID).Scan(&likedName)
+				db.QueryRowContext(ctx, "SELECT first_name FROM users WHERE id = $1", likedID).Scan(&likedName)
 				CreateAndPushNotification(db, likedID, "match", likerID, "You matched with "+likerName+"!")
 				CreateAndPushNotification(db, likerID, "match", likedID, "You matched with "+likedName+"!")
+				// The match counts for whichever side was suggested the other.
+				recordExperimentOutcome(ctx, db, likerID, likedID, store.OutcomeMatch)
+				recordExperimentOutcome(ctx, db, likedID, likerID, store.OutcomeMatch)
 			} else {
 				CreateAndPushNotification(db, likedID, "like", likerID, likerName+" liked your profile")
 			}
 
-			c.JSON(200, gin.H{"message": "Like added", "liked": true})
+			c.JSON(200, LikeToggleResponse{Message: "Like added", Liked: true})
 		}
 	}
 }
 
 func GetProfileStatsHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDStr := c.Param("userId")
 		userID, err := strconv.Atoi(userIDStr)
 		if err != nil {
@@ -843,34 +840,36 @@ func GetProfileStatsHandler(db *sql.DB) gin.HandlerFunc {
 		var viewsCount, likesCount int
 		var fameRating float64
 
-		err = db.QueryRow("SELECT COUNT(*) FROM profile_views WHERE viewed_id = $1", userID).Scan(&viewsCount)
+		err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM profile_views WHERE viewed_id = $1", userID).Scan(&viewsCount)
 		if err != nil {
 			log.Printf("Error counting views: %v", err)
 			viewsCount = 0
 		}
 
-		err = db.QueryRow("SELECT COUNT(*) FROM profile_likes WHERE liked_id = $1", userID).Scan(&likesCount)
+		err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM profile_likes WHERE liked_id = $1", userID).Scan(&likesCount)
 		if err != nil {
 			log.Printf("Error counting likes: %v", err)
 			likesCount = 0
 		}
 
-		err = db.QueryRow("SELECT COALESCE(fame_rating, 0) FROM users WHERE id = $1", userID).Scan(&fameRating)
+		err = db.QueryRowContext(ctx, "SELECT COALESCE(fame_rating, 0) FROM users WHERE id = $1", userID).Scan(&fameRating)
 		if err != nil {
 			log.Printf("Error getting fame rating: %v", err)
 			fameRating = 0
 		}
 
-		c.JSON(200, gin.H{
-			"views":       viewsCount,
-			"likes":       likesCount,
-			"fame_rating": fameRating,
+		c.JSON(200, ProfileStatsResponse{
+			Views:      viewsCount,
+			Likes:      likesCount,
+			FameRating: fameRating,
 		})
 	}
 }
 
 func CheckLikeStatusHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDVal, userExists := c.Get("userID")
 		if !userExists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -890,7 +889,7 @@ func CheckLikeStatusHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		var liked bool
-		err = db.QueryRow(
+		err = db.QueryRowContext(ctx, 
 			"SELECT EXISTS(SELECT 1 FROM profile_likes WHERE liker_id = $1 AND liked_id = $2)",
 			likerID, likedID,
 		).Scan(&liked)
@@ -900,12 +899,14 @@ func CheckLikeStatusHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(200, gin.H{"liked": liked})
+		c.JSON(200, LikeStatusResponse{Liked: liked})
 	}
 }
 
 func GetProfileViewersHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDStr := c.Param("userId")
 		userID, err := strconv.Atoi(userIDStr)
 		if err != nil {
@@ -913,21 +914,29 @@ func GetProfileViewersHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		rows, err := db.Query(`
+		page, err := parsePageParams(c, "viewers")
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		rows, err := db.QueryContext(ctx, `
 			SELECT DISTINCT u.id, u.username, u.first_name, u.last_name, u.email, 
-			       u.gender, u.orientation, u.birthday, u.bio, u.fame_rating,
-			       COALESCE(ul.lat, 0) as latitude, COALESCE(ul.lon, 0) as longitude,
+			       ` + genderColumn + `, ` + interestedInColumn + `, u.birthday, u.bio, u.fame_rating,
+			       COALESCE(ul.public_lat, 0) as latitude, COALESCE(ul.public_lon, 0) as longitude,
 			       COALESCE(string_agg(t.name, ','), '') as tags,
-			       MAX(pv.viewed_at) as last_viewed_at
+			       pv.viewed_at as last_viewed_at
 			FROM users u
 			JOIN profile_views pv ON u.id = pv.viewer_id
 			LEFT JOIN user_locations ul ON u.id = ul.user_id
 			LEFT JOIN user_tags ut ON u.id = ut.user_id
 			LEFT JOIN tags t ON ut.tag_id = t.id
 			WHERE pv.viewed_id = $1
-			GROUP BY u.id, ul.lat, ul.lon
-			ORDER BY last_viewed_at DESC
-		`, userID)
+			  AND ($2::timestamp IS NULL OR (pv.viewed_at, u.id) < ($2::timestamp, $3))
+			GROUP BY u.id, ul.public_lat, ul.public_lon, pv.viewed_at
+			ORDER BY pv.viewed_at DESC, u.id DESC
+			LIMIT $4
+		`, userID, page.cursorTime(), page.cursorID(), page.Limit+1)
 
 		if err != nil {
 			log.Printf("Error querying viewers: %v", err)
@@ -936,68 +945,51 @@ func GetProfileViewersHandler(db *sql.DB) gin.HandlerFunc {
 		}
 		defer rows.Close()
 
-		var viewers []map[string]interface{}
+		var viewers []ProfileActivityUser
+		var nextCursor *string
+		var lastActivityAt time.Time
 		for rows.Next() {
-			var id int
-			var username, firstName, lastName, email string
-			var gender, orientation, bio, tags sql.NullString
+			if len(viewers) == page.Limit {
+				nextCursor = timeCursor("viewers", lastActivityAt, viewers[len(viewers)-1].ID)
+				break
+			}
+
+			var viewer ProfileActivityUser
+			var gender, bio, tags sql.NullString
+			var interestedIn pq.StringArray
 			var birthday sql.NullTime
 			var fameRating sql.NullFloat64
 			var latitude, longitude sql.NullFloat64
 			var lastViewedAt sql.NullTime
 
-			err := rows.Scan(&id, &username, &firstName, &lastName, &email,
-				&gender, &orientation, &birthday, &bio, &fameRating,
+			err := rows.Scan(&viewer.ID, &viewer.Username, &viewer.FirstName, &viewer.LastName, &viewer.Email,
+				&gender, &interestedIn, &birthday, &bio, &fameRating,
 				&latitude, &longitude, &tags, &lastViewedAt)
 			if err != nil {
 				log.Printf("Error scanning viewer row: %v", err)
 				continue
 			}
 
-			viewer := map[string]interface{}{
-				"id":          id,
-				"username":    username,
-				"first_name":  firstName,
-				"last_name":   lastName,
-				"email":       email,
-				"gender":      gender.String,
-				"orientation": orientation.String,
-				"birthday":    birthday.Time,
-				"bio":         bio.String,
-				"fame_rating": fameRating.Float64,
-				"latitude":    latitude.Float64,
-				"longitude":   longitude.Float64,
+			viewer.Gender = gender.String
+			viewer.InterestedIn = stringsOrEmpty(interestedIn)
+			if orientation := legacyOrientation(interestedIn); orientation != nil {
+				viewer.Orientation = *orientation
 			}
+			viewer.Birthday = birthday.Time
+			viewer.Bio = bio.String
+			viewer.FameRating = fameRating.Float64
+			viewer.Latitude = latitude.Float64
+			viewer.Longitude = longitude.Float64
 
 			if tags.String != "" {
-				viewer["tags"] = strings.Split(tags.String, ",")
+				viewer.Tags = strings.Split(tags.String, ",")
 			} else {
-				viewer["tags"] = []string{}
-			}
-			viewer["interests"] = viewer["tags"]
-
-			imageRows, err := db.Query(`
-SELECT path FROM user_images 
-WHERE user_id = $1 
-ORDER BY is_profile_picture DESC, id ASC
-`, id)
-			if err == nil {
-				defer imageRows.Close()
-				var images []string
-				for imageRows.Next() {
-					var path string
-					if err := imageRows.Scan(&path); err == nil {
-						images = append(images, path)
-					}
-				}
-				viewer["images"] = images
-
-			} else {
-				viewer["images"] = []string{}
-				log.Printf("ERROR: Failed to fetch images for viewer ID %d: %v", id, err)
+				viewer.Tags = []string{}
 			}
+			viewer.Interests = viewer.Tags
 
 			viewers = append(viewers, viewer)
+			lastActivityAt = lastViewedAt.Time
 		}
 
 		if err = rows.Err(); err != nil {
@@ -1006,12 +998,28 @@ ORDER BY is_profile_picture DESC, id ASC
 			return
 		}
 
-		c.JSON(200, gin.H{"viewers": viewers})
+		ids := make([]int, len(viewers))
+		for i := range viewers {
+			ids[i] = viewers[i].ID
+		}
+		images, err := loadUserImages(ctx, db, ids)
+		if err != nil {
+			log.Printf("Error fetching viewer images: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		for i := range viewers {
+			viewers[i].Images = stringsOrEmpty(images[viewers[i].ID])
+		}
+
+		c.JSON(200, ProfileViewersResponse{Viewers: viewers, NextCursor: nextCursor})
 	}
 }
 
 func GetProfileLikersHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDStr := c.Param("userId")
 		userID, err := strconv.Atoi(userIDStr)
 		if err != nil {
@@ -1019,21 +1027,29 @@ func GetProfileLikersHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		rows, err := db.Query(`
+		page, err := parsePageParams(c, "likers")
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		rows, err := db.QueryContext(ctx, `
 			SELECT DISTINCT u.id, u.username, u.first_name, u.last_name, u.email, 
-			       u.gender, u.orientation, u.birthday, u.bio, u.fame_rating,
-			       COALESCE(ul.lat, 0) as latitude, COALESCE(ul.lon, 0) as longitude,
+			       ` + genderColumn + `, ` + interestedInColumn + `, u.birthday, u.bio, u.fame_rating,
+			       COALESCE(ul.public_lat, 0) as latitude, COALESCE(ul.public_lon, 0) as longitude,
 			       COALESCE(string_agg(t.name, ','), '') as tags,
-			       MAX(pl.liked_at) as last_liked_at
+			       pl.liked_at as last_liked_at
 			FROM users u
 			JOIN profile_likes pl ON u.id = pl.liker_id
 			LEFT JOIN user_locations ul ON u.id = ul.user_id
 			LEFT JOIN user_tags ut ON u.id = ut.user_id
 			LEFT JOIN tags t ON ut.tag_id = t.id
 			WHERE pl.liked_id = $1
-			GROUP BY u.id, ul.lat, ul.lon
-			ORDER BY last_liked_at DESC
-		`, userID)
+			  AND ($2::timestamp IS NULL OR (pl.liked_at, u.id) < ($2::timestamp, $3))
+			GROUP BY u.id, ul.public_lat, ul.public_lon, pl.liked_at
+			ORDER BY pl.liked_at DESC, u.id DESC
+			LIMIT $4
+		`, userID, page.cursorTime(), page.cursorID(), page.Limit+1)
 
 		if err != nil {
 			log.Printf("Error querying likers: %v", err)
@@ -1042,66 +1058,51 @@ func GetProfileLikersHandler(db *sql.DB) gin.HandlerFunc {
 		}
 		defer rows.Close()
 
-		var likers []map[string]interface{}
+		var likers []ProfileActivityUser
+		var nextCursor *string
+		var lastActivityAt time.Time
 		for rows.Next() {
-			var id int
-			var username, firstName, lastName, email string
-			var gender, orientation, bio, tags sql.NullString
-			var lastLikedAt sql.NullTime
+			if len(likers) == page.Limit {
+				nextCursor = timeCursor("likers", lastActivityAt, likers[len(likers)-1].ID)
+				break
+			}
+
+			var liker ProfileActivityUser
+			var gender, bio, tags sql.NullString
+			var interestedIn pq.StringArray
 			var birthday sql.NullTime
 			var fameRating sql.NullFloat64
 			var latitude, longitude sql.NullFloat64
+			var lastLikedAt sql.NullTime
 
-			err := rows.Scan(&id, &username, &firstName, &lastName, &email,
-				&gender, &orientation, &birthday, &bio, &fameRating,
+			err := rows.Scan(&liker.ID, &liker.Username, &liker.FirstName, &liker.LastName, &liker.Email,
+				&gender, &interestedIn, &birthday, &bio, &fameRating,
 				&latitude, &longitude, &tags, &lastLikedAt)
 			if err != nil {
 				log.Printf("Error scanning liker row: %v", err)
 				continue
 			}
 
-			liker := map[string]interface{}{
-				"id":          id,
-				"username":    username,
-				"first_name":  firstName,
-				"last_name":   lastName,
-				"email":       email,
-				"gender":      gender.String,
-				"orientation": orientation.String,
-				"birthday":    birthday.Time,
-				"bio":         bio.String,
-				"fame_rating": fameRating.Float64,
-				"latitude":    latitude.Float64,
-				"longitude":   longitude.Float64,
+			liker.Gender = gender.String
+			liker.InterestedIn = stringsOrEmpty(interestedIn)
+			if orientation := legacyOrientation(interestedIn); orientation != nil {
+				liker.Orientation = *orientation
 			}
+			liker.Birthday = birthday.Time
+			liker.Bio = bio.String
+			liker.FameRating = fameRating.Float64
+			liker.Latitude = latitude.Float64
+			liker.Longitude = longitude.Float64
 
 			if tags.String != "" {
-				liker["tags"] = strings.Split(tags.String, ",")
-			} else {
-				liker["tags"] = []string{}
-			}
-			liker["interests"] = liker["tags"]
-
-			imageRows, err := db.Query(`
-SELECT path FROM user_images 
-WHERE user_id = $1 
-ORDER BY is_profile_picture DESC, id ASC
-`, id)
-			if err == nil {
-				defer imageRows.Close()
-				var images []string
-				for imageRows.Next() {
-					var path string
-					if err := imageRows.Scan(&path); err == nil {
-						images = append(images, path)
-					}
-				}
-				liker["images"] = images
+				liker.Tags = strings.Split(tags.String, ",")
 			} else {
-				liker["images"] = []string{}
+				liker.Tags = []string{}
 			}
+			liker.Interests = liker.Tags
 
 			likers = append(likers, liker)
+			lastActivityAt = lastLikedAt.Time
 		}
 
 		if err = rows.Err(); err != nil {
@@ -1110,12 +1111,28 @@ ORDER BY is_profile_picture DESC, id ASC
 			return
 		}
 
-		c.JSON(200, gin.H{"likers": likers})
+		ids := make([]int, len(likers))
+		for i := range likers {
+			ids[i] = likers[i].ID
+		}
+		images, err := loadUserImages(ctx, db, ids)
+		if err != nil {
+			log.Printf("Error fetching liker images: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		for i := range likers {
+			likers[i].Images = stringsOrEmpty(images[likers[i].ID])
+		}
+
+		c.JSON(200, ProfileLikersResponse{Likers: likers, NextCursor: nextCursor})
 	}
 }
 
 func GetCurrentUserHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		sessionToken, err := c.Cookie("session_token")
 		if err != nil {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -1124,18 +1141,21 @@ func GetCurrentUserHandler(db *sql.DB) gin.HandlerFunc {
 
 		var userID int
 		var username, email, firstName, lastName string
-		var gender, orientation, bio, avatarURL sql.NullString
+		var gender, bio, avatarURL sql.NullString
+		var interestedIn pq.StringArray
 		var birthday, lastSeen sql.NullTime
 		var fameRating sql.NullFloat64
+		var searchable bool
+		var bioLanguage string
 
-		err = db.QueryRow(`
-			SELECT id, username, email, first_name, last_name, gender, orientation, 
-			       birthday, bio, avatar_url, fame_rating, last_seen
-			FROM users 
-			WHERE session_token = $1
+		err = db.QueryRowContext(ctx, `
+			SELECT u.id, u.username, u.email, u.first_name, u.last_name, ` + genderColumn + `, ` + interestedInColumn + `,
+			       u.birthday, u.bio, u.avatar_url, u.fame_rating, u.last_seen, u.searchable, u.bio_language
+			FROM users u
+			WHERE u.session_token = $1
 		`, sessionToken).Scan(
 			&userID, &username, &email, &firstName, &lastName,
-			&gender, &orientation, &birthday, &bio, &avatarURL, &fameRating, &lastSeen,
+			&gender, &interestedIn, &birthday, &bio, &avatarURL, &fameRating, &lastSeen, &searchable, &bioLanguage,
 		)
 
 		if err != nil {
@@ -1148,41 +1168,24 @@ func GetCurrentUserHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		response := gin.H{
-			"id":         userID,
-			"username":   username,
-			"email":      email,
-			"first_name": firstName,
-			"last_name":  lastName,
-			"is_online":  true,
+		response := UserResponse{
+			ID:        userID,
+			Username:  username,
+			Email:     email,
+			FirstName: firstName,
+			LastName:  lastName,
+			IsOnline:  boolPtr(true),
 		}
+		response.Searchable = &searchable
+		response.BioLanguage = &bioLanguage
 		if lastSeen.Valid {
-			response["last_seen"] = lastSeen.Time.Format(time.RFC3339)
+			response.LastSeen = stringPtr(lastSeen.Time.Format(time.RFC3339))
 		}
 
-		if gender.Valid {
-			response["gender"] = gender.String
-		}
-		if orientation.Valid {
-			response["orientation"] = orientation.String
-		}
-		if birthday.Valid {
-			response["birthday"] = birthday.Time.Format("2006-01-02")
-		}
-		if bio.Valid {
-			response["bio"] = bio.String
-		}
-		if avatarURL.Valid {
-			response["avatar_url"] = avatarURL.String
-		}
-		if fameRating.Valid {
-			response["fame_rating"] = fameRating.Float64
-		} else {
-			response["fame_rating"] = 0.0
-		}
+		applyProfileFields(&response, gender, interestedIn, birthday, bio, avatarURL, fameRating)
 
 		tags := []string{}
-		tagRows, err := db.Query(`
+		tagRows, err := db.QueryContext(ctx, `
 			SELECT t.name 
 			FROM tags t 
 			JOIN user_tags ut ON t.id = ut.tag_id 
@@ -1197,19 +1200,21 @@ func GetCurrentUserHandler(db *sql.DB) gin.HandlerFunc {
 				}
 			}
 		}
-		response["tags"] = tags
+		response.Tags = tags
 
 		var lat, lon sql.NullFloat64
-		err = db.QueryRow(`
-			SELECT lat, lon 
+		var source string
+		err = db.QueryRowContext(ctx, `
+			SELECT lat, lon, source 
 			FROM user_locations 
 			WHERE user_id = $1
-		`, userID).Scan(&lat, &lon)
+		`, userID).Scan(&lat, &lon, &source)
 
 		if err == nil && lat.Valid && lon.Valid {
-			response["location"] = gin.H{
-				"lat": lat.Float64,
-				"lon": lon.Float64,
+			response.Location = &LatLon{
+				Lat:    lat.Float64,
+				Lon:    lon.Float64,
+				Source: source,
 			}
 		}
 
@@ -1217,14 +1222,58 @@ func GetCurrentUserHandler(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
+func applyProfileFields(user *UserResponse, gender sql.NullString, interestedIn []string, birthday sql.NullTime, bio, avatarURL sql.NullString, fameRating sql.NullFloat64) {
+	if gender.Valid {
+		user.Gender = stringPtr(gender.String)
+	}
+	user.InterestedIn = stringsOrEmpty(interestedIn)
+	user.Orientation = legacyOrientation(interestedIn)
+	if birthday.Valid {
+		user.Birthday = stringPtr(birthday.Time.Format("2006-01-02"))
+	}
+	if bio.Valid {
+		user.Bio = stringPtr(bio.String)
+	}
+	if avatarURL.Valid {
+		user.AvatarURL = stringPtr(avatarURL.String)
+	}
+	if fameRating.Valid {
+		user.FameRating = fameRating.Float64
+	}
+}
+
+// applyPresence marks a user online when they hold a websocket connection or
+// were seen in the last five minutes.
+func applyPresence(user *UserResponse, lastSeen sql.NullTime) {
+	isOnline := IsUserOnline(user.ID)
+	if lastSeen.Valid {
+		user.LastSeen = stringPtr(lastSeen.Time.Format(time.RFC3339))
+		if !isOnline {
+			isOnline = time.Since(lastSeen.Time) < 5*time.Minute
+		}
+	}
+	user.IsOnline = boolPtr(isOnline)
+}
+
 func GetAllUsersHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		rows, err := db.Query(`
-			SELECT id, username, first_name, last_name, email, gender, orientation, 
-			       birthday, bio, avatar_url, fame_rating, last_seen
-			FROM users
-			WHERE verified = true
-		`)
+		ctx := c.Request.Context()
+
+		page, err := parsePageParams(c, "users")
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		rows, err := db.QueryContext(ctx, `
+			SELECT u.id, u.username, u.first_name, u.last_name, u.email, ` + genderColumn + `, ` + interestedInColumn + `,
+			       u.birthday, u.bio, u.avatar_url, u.fame_rating, u.last_seen, ul.public_lat, ul.public_lon
+			FROM users u
+			LEFT JOIN user_locations ul ON u.id = ul.user_id
+			WHERE u.verified = true AND u.banned_at IS NULL AND u.id > $1
+			ORDER BY u.id ASC
+			LIMIT $2
+		`, page.cursorID(), page.Limit+1)
 		if err != nil {
 			log.Printf("Error fetching users: %v", err)
 			c.JSON(500, gin.H{"error": "Database error"})
@@ -1232,91 +1281,70 @@ func GetAllUsersHandler(db *sql.DB) gin.HandlerFunc {
 		}
 		defer rows.Close()
 
-		users := []gin.H{}
+		users := []UserResponse{}
+		var nextCursor *string
 		for rows.Next() {
+			if len(users) == page.Limit {
+				nextCursor = idCursor("users", users[len(users)-1].ID)
+				break
+			}
+
 			var userID int
 			var username, email, firstName, lastName string
-			var gender, orientation, bio, avatarURL sql.NullString
+			var gender, bio, avatarURL sql.NullString
+			var interestedIn pq.StringArray
 			var birthday, lastSeen sql.NullTime
-			var fameRating sql.NullFloat64
+			var fameRating, lat, lon sql.NullFloat64
 
 			err := rows.Scan(
 				&userID, &username, &firstName, &lastName, &email,
-				&gender, &orientation, &birthday, &bio, &avatarURL, &fameRating, &lastSeen,
+				&gender, &interestedIn, &birthday, &bio, &avatarURL, &fameRating, &lastSeen, &lat, &lon,
 			)
 			if err != nil {
 				log.Printf("Error scanning user: %v", err)
 				continue
 			}
 
-			user := gin.H{
-				"id":         userID,
-				"username":   username,
-				"email":      email,
-				"first_name": firstName,
-				"last_name":  lastName,
+			user := UserResponse{
+				ID:        userID,
+				Username:  username,
+				Email:     email,
+				FirstName: firstName,
+				LastName:  lastName,
 			}
 
-			if gender.Valid {
-				user["gender"] = gender.String
-			}
-			if orientation.Valid {
-				user["orientation"] = orientation.String
-			}
-			if birthday.Valid {
-				user["birthday"] = birthday.Time.Format("2006-01-02")
-			}
-			if bio.Valid {
-				user["bio"] = bio.String
-			}
-			if avatarURL.Valid {
-				user["avatar_url"] = avatarURL.String
-			}
-			if fameRating.Valid {
-				user["fame_rating"] = fameRating.Float64
-			} else {
-				user["fame_rating"] = 0.0
-			}
+			applyProfileFields(&user, gender, interestedIn, birthday, bio, avatarURL, fameRating)
 
-			tags := []string{}
-			tagRows, err := db.Query(`
-				SELECT t.name 
-				FROM tags t 
-				JOIN user_tags ut ON t.id = ut.tag_id 
-				WHERE ut.user_id = $1
-			`, userID)
-			if err == nil {
-				defer tagRows.Close()
-				for tagRows.Next() {
-					var tagName string
-					if err := tagRows.Scan(&tagName); err == nil {
-						tags = append(tags, tagName)
-					}
-				}
-			}
-			user["tags"] = tags
-
-			var lat, lon sql.NullFloat64
-			err = db.QueryRow(`
-				SELECT lat, lon 
-				FROM user_locations 
-				WHERE user_id = $1
-			`, userID).Scan(&lat, &lon)
-
-			if err == nil && lat.Valid && lon.Valid {
-				user["latitude"] = lat.Float64
-				user["longitude"] = lon.Float64
+			if lat.Valid && lon.Valid {
+				user.Latitude = float64Ptr(lat.Float64)
+				user.Longitude = float64Ptr(lon.Float64)
 			}
 
 			users = append(users, user)
 		}
 
-		c.JSON(200, gin.H{"users": users})
+		ids := make([]int, len(users))
+		for i := range users {
+			ids[i] = users[i].ID
+		}
+		tagsByUser, err := loadUserTags(ctx, db, ids)
+		if err != nil {
+			log.Printf("Error fetching user tags: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		for i := range users {
+			users[i].Tags = stringsOrEmpty(tagsByUser[users[i].ID])
+		}
+
+		c.JSON(200, UsersResponse{Users: users, NextCursor: nextCursor})
 	}
 }
 
 func GetUserByIdHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDStr := c.Param("userId")
 		userID, err := strconv.Atoi(userIDStr)
 		if err != nil {
@@ -1325,18 +1353,19 @@ func GetUserByIdHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		var username, email, firstName, lastName string
-		var gender, orientation, bio, avatarURL sql.NullString
+		var gender, bio, avatarURL sql.NullString
+		var interestedIn pq.StringArray
 		var birthday, lastSeen sql.NullTime
 		var fameRating sql.NullFloat64
 
-		err = db.QueryRow(`
-			SELECT username, email, first_name, last_name, gender, orientation, 
-			       birthday, bio, avatar_url, fame_rating, last_seen
-			FROM users
-			WHERE id = $1 AND verified = true
+		err = db.QueryRowContext(ctx, `
+			SELECT u.username, u.email, u.first_name, u.last_name, ` + genderColumn + `, ` + interestedInColumn + `,
+			       u.birthday, u.bio, u.avatar_url, u.fame_rating, u.last_seen
+			FROM users u
+			WHERE u.id = $1 AND u.verified = true
 		`, userID).Scan(
 			&username, &email, &firstName, &lastName,
-			&gender, &orientation, &birthday, &bio, &avatarURL, &fameRating, &lastSeen,
+			&gender, &interestedIn, &birthday, &bio, &avatarURL, &fameRating, &lastSeen,
 		)
 
 		if err == sql.ErrNoRows {
@@ -1348,44 +1377,18 @@ func GetUserByIdHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		user := gin.H{
-			"id":         userID,
-			"username":   username,
-			"email":      email,
-			"first_name": firstName,
-			"last_name":  lastName,
-			"is_online":  IsUserOnline(userID),
-		}
-		if lastSeen.Valid {
-			user["last_seen"] = lastSeen.Time.Format(time.RFC3339)
-			if nextIsOnline, ok := user["is_online"].(bool); ok && !nextIsOnline {
-				user["is_online"] = time.Since(lastSeen.Time) < 5*time.Minute
-			}
-		}
-
-		if gender.Valid {
-			user["gender"] = gender.String
-		}
-		if orientation.Valid {
-			user["orientation"] = orientation.String
-		}
-		if birthday.Valid {
-			user["birthday"] = birthday.Time.Format("2006-01-02")
-		}
-		if bio.Valid {
-			user["bio"] = bio.String
-		}
-		if avatarURL.Valid {
-			user["avatar_url"] = avatarURL.String
-		}
-		if fameRating.Valid {
-			user["fame_rating"] = fameRating.Float64
-		} else {
-			user["fame_rating"] = 0.0
+		user := UserResponse{
+			ID:        userID,
+			Username:  username,
+			Email:     email,
+			FirstName: firstName,
+			LastName:  lastName,
 		}
+		applyPresence(&user, lastSeen)
+		applyProfileFields(&user, gender, interestedIn, birthday, bio, avatarURL, fameRating)
 
 		tags := []string{}
-		tagRows, err := db.Query(`
+		tagRows, err := db.QueryContext(ctx, `
 			SELECT t.name 
 			FROM tags t 
 			JOIN user_tags ut ON t.id = ut.tag_id 
@@ -1400,55 +1403,57 @@ func GetUserByIdHandler(db *sql.DB) gin.HandlerFunc {
 				}
 			}
 		}
-		user["tags"] = tags
+		user.Tags = tags
 
-		var lat, lon sql.NullFloat64
-		err = db.QueryRow(`
-			SELECT lat, lon 
+		var lat, lon, publicLat, publicLon sql.NullFloat64
+		err = db.QueryRowContext(ctx, `
+			SELECT lat, lon, public_lat, public_lon 
 			FROM user_locations 
 			WHERE user_id = $1
-		`, userID).Scan(&lat, &lon)
+		`, userID).Scan(&lat, &lon, &publicLat, &publicLon)
+
+		// Only the owner sees their exact location.
+		if currentUserID, _ := c.Get("userID"); currentUserID != userID {
+			lat, lon = publicLat, publicLon
+		}
 
 		if err == nil && lat.Valid && lon.Valid {
-			user["latitude"] = lat.Float64
-			user["longitude"] = lon.Float64
-
-			currentUserIDVal, exists := c.Get("userID")
-			if exists {
-				currentUserID := currentUserIDVal.(int)
-				if currentUserID != userID {
-					var currentLat, currentLon sql.NullFloat64
-					err := db.QueryRow("SELECT lat, lon FROM user_locations WHERE user_id = $1", currentUserID).Scan(&currentLat, &currentLon)
-
-					currentUserTags := []string{}
-					tagRows, err := db.Query(`
-						SELECT t.name FROM tags t 
-						JOIN user_tags ut ON t.id = ut.tag_id 
-						WHERE ut.user_id = $1
-					`, currentUserID)
-					if err == nil {
-						defer tagRows.Close()
-						for tagRows.Next() {
-							var tagName string
-							if err := tagRows.Scan(&tagName); err == nil {
-								currentUserTags = append(currentUserTags, tagName)
-							}
+			user.Latitude = float64Ptr(lat.Float64)
+			user.Longitude = float64Ptr(lon.Float64)
+		}
+
+		currentUserIDVal, exists := c.Get("userID")
+		if exists {
+			currentUserID := currentUserIDVal.(int)
+			if currentUserID != userID {
+				var currentLat, currentLon sql.NullFloat64
+				db.QueryRowContext(ctx, "SELECT lat, lon FROM user_locations WHERE user_id = $1", currentUserID).Scan(&currentLat, &currentLon)
+
+				currentUserTags := []string{}
+				tagRows, err := db.QueryContext(ctx, `
+					SELECT t.name FROM tags t
+					JOIN user_tags ut ON t.id = ut.tag_id
+					WHERE ut.user_id = $1
+				`, currentUserID)
+				if err == nil {
+					defer tagRows.Close()
+					for tagRows.Next() {
+						var tagName string
+						if err := tagRows.Scan(&tagName); err == nil {
+							currentUserTags = append(currentUserTags, tagName)
 						}
 					}
+				}
 
-					if currentLat.Valid && currentLon.Valid {
-						user["match_score"] = calculateMatchScore(
-							currentLat.Float64, currentLon.Float64,
-							lat.Float64, lon.Float64,
-							currentUserTags, tags,
-							user["fame_rating"].(float64),
-						)
-					}
+				viewer, err := newViewerScoreProfile(ctx, db, currentUserID, currentLat, currentLon, currentUserTags)
+				if err != nil {
+					log.Printf("Error fetching recommendations: %v", err)
 				}
+				applyMatchScore(&user, matchScorer, viewer, newScoreProfile(userID, lat, lon, tags, user.FameRating))
 			}
 		}
 
-		imageRows, err := db.Query(`
+		imageRows, err := db.QueryContext(ctx, `
 			SELECT path FROM user_images 
 			WHERE user_id = $1 
 			ORDER BY is_profile_picture DESC, id ASC
@@ -1462,10 +1467,10 @@ func GetUserByIdHandler(db *sql.DB) gin.HandlerFunc {
 					images = append(images, path)
 				}
 			}
-			user["images"] = images
+			user.Images = images
 
 		} else {
-			user["images"] = []string{}
+			user.Images = []string{}
 			log.Printf("ERROR: Failed to fetch images for user ID %d: %v", userID, err)
 		}
 
@@ -1475,6 +1480,8 @@ func GetUserByIdHandler(db *sql.DB) gin.HandlerFunc {
 
 func UpdateProfileHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -1486,36 +1493,55 @@ func UpdateProfileHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		var requestData struct {
-			Gender      *string `json:"gender"`
-			Orientation *string `json:"orientation"`
-			Bio         *string `json:"bio"`
-			FirstName   *string `json:"first_name"`
-			LastName    *string `json:"last_name"`
-			Birthday    *string `json:"birthday"`
-		}
+		var requestData UpdateProfileRequest
 
 		if err := c.BindJSON(&requestData); err != nil {
 			c.JSON(400, gin.H{"error": "Invalid request data"})
 			return
 		}
 
-		if requestData.Gender != nil {
-			trimmedGender := strings.TrimSpace(*requestData.Gender)
-			if trimmedGender != "Man" && trimmedGender != "Woman" {
-				c.JSON(400, gin.H{"error": "Gender must be either Man or Woman"})
+		// orientation is the legacy way of setting interested_in.
+		if requestData.Orientation != nil {
+			if requestData.InterestedIn != nil {
+				c.JSON(400, gin.H{"error": "Set either orientation or interested_in"})
 				return
 			}
-			requestData.Gender = &trimmedGender
+			interestedIn, ok := legacyOrientations[strings.TrimSpace(*requestData.Orientation)]
+			if !ok {
+				c.JSON(400, gin.H{"error": "Orientation must be one of: likes men, likes women, likes men and women"})
+				return
+			}
+			requestData.InterestedIn = &interestedIn
 		}
 
-		if requestData.Orientation != nil {
-			trimmedOrientation := strings.TrimSpace(*requestData.Orientation)
-			if trimmedOrientation != "likes men" && trimmedOrientation != "likes women" && trimmedOrientation != "likes men and women" {
-				c.JSON(400, gin.H{"error": "Orientation must be one of: likes men, likes women, likes men and women"})
+		var genderID *int
+		var interestedIn []int
+		if requestData.Gender != nil || requestData.InterestedIn != nil {
+			genderIDs, genderNames, err := loadGenders(ctx, db)
+			if err != nil {
+				log.Printf("Error fetching genders: %v", err)
+				c.JSON(500, gin.H{"error": "Database error"})
 				return
 			}
-			requestData.Orientation = &trimmedOrientation
+			if requestData.Gender != nil {
+				resolved, err := resolveGenders(genderIDs, genderNames, []string{*requestData.Gender})
+				if err != nil {
+					c.JSON(400, gin.H{"error": err.Error()})
+					return
+				}
+				genderID = &resolved[0]
+			}
+			if requestData.InterestedIn != nil {
+				if len(*requestData.InterestedIn) == 0 {
+					c.JSON(400, gin.H{"error": "interested_in must name at least one gender"})
+					return
+				}
+				interestedIn, err = resolveGenders(genderIDs, genderNames, *requestData.InterestedIn)
+				if err != nil {
+					c.JSON(400, gin.H{"error": "interested_in: " + err.Error()})
+					return
+				}
+			}
 		}
 
 		if requestData.Bio != nil {
@@ -1552,35 +1578,63 @@ func UpdateProfileHandler(db *sql.DB) gin.HandlerFunc {
 			}
 		}
 
-		if requestData.Gender == nil && requestData.Orientation == nil && requestData.Bio == nil &&
-			requestData.FirstName == nil && requestData.LastName == nil && requestData.Birthday == nil {
+		if requestData.BioLanguage != nil {
+			language := strings.ToLower(strings.TrimSpace(*requestData.BioLanguage))
+			if !bioLanguages[language] {
+				c.JSON(400, gin.H{"error": "bio_language must be one of: simple, english, french"})
+				return
+			}
+			requestData.BioLanguage = &language
+		}
+
+		if requestData.Gender == nil && requestData.InterestedIn == nil && requestData.Bio == nil &&
+			requestData.FirstName == nil && requestData.LastName == nil && requestData.Birthday == nil &&
+			requestData.Searchable == nil && requestData.BioLanguage == nil {
 			c.JSON(400, gin.H{"error": "No fields to update"})
 			return
 		}
 
-		_, err := db.Exec(`
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			log.Printf("Error starting transaction: %v", err)
+			c.JSON(500, gin.H{"error": "Error updating profile"})
+			return
+		}
+		defer tx.Rollback()
+
+		_, err = tx.ExecContext(ctx, `
 			UPDATE users
 			SET
-				gender = COALESCE($1, gender),
-				orientation = COALESCE($2, orientation),
-				bio = COALESCE($3, bio),
-				first_name = COALESCE($4, first_name),
-				last_name = COALESCE($5, last_name),
-				birthday = COALESCE($6, birthday)
-			WHERE id = $7
-		`, requestData.Gender, requestData.Orientation, requestData.Bio, requestData.FirstName, requestData.LastName, requestData.Birthday, userID)
+				gender_id = COALESCE($1, gender_id),
+				bio = COALESCE($2, bio),
+				first_name = COALESCE($3, first_name),
+				last_name = COALESCE($4, last_name),
+				birthday = COALESCE($5, birthday),
+				searchable = COALESCE($6, searchable),
+				bio_language = COALESCE($7, bio_language)
+			WHERE id = $8
+		`, genderID, requestData.Bio, requestData.FirstName, requestData.LastName, requestData.Birthday,
+			requestData.Searchable, requestData.BioLanguage, userID)
+		if err == nil && interestedIn != nil {
+			err = setInterestedIn(ctx, tx, userID, interestedIn)
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
 		if err != nil {
 			log.Printf("Error updating profile: %v", err)
 			c.JSON(500, gin.H{"error": "Error updating profile"})
 			return
 		}
 
-		c.JSON(200, gin.H{"message": "Profile updated successfully"})
+		c.JSON(200, MessageResponse{Message: "Profile updated successfully"})
 	}
 }
 
 func UpdateEmailHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -1592,9 +1646,7 @@ func UpdateEmailHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		var requestData struct {
-			Email string `json:"email"`
-		}
+		var requestData UpdateEmailRequest
 
 		if err := c.BindJSON(&requestData); err != nil {
 			c.JSON(400, gin.H{"error": "Invalid request data"})
@@ -1614,7 +1666,7 @@ func UpdateEmailHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		var emailExists bool
-		err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE email = $1 AND id != $2)", requestData.Email, userID).Scan(&emailExists)
+		err := db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE email = $1 AND id != $2)", requestData.Email, userID).Scan(&emailExists)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Database error"})
 			return
@@ -1624,19 +1676,21 @@ func UpdateEmailHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		_, err = db.Exec("UPDATE users SET email = $1 WHERE id = $2", requestData.Email, userID)
+		_, err = db.ExecContext(ctx, "UPDATE users SET email = $1 WHERE id = $2", requestData.Email, userID)
 		if err != nil {
 			log.Printf("Error updating email: %v", err)
 			c.JSON(500, gin.H{"error": "Error updating email"})
 			return
 		}
 
-		c.JSON(200, gin.H{"message": "Email updated successfully"})
+		c.JSON(200, MessageResponse{Message: "Email updated successfully"})
 	}
 }
 
 func UpdateTagsHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -1648,9 +1702,7 @@ func UpdateTagsHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		var requestData struct {
-			Tags []string `json:"tags"`
-		}
+		var requestData UpdateTagsRequest
 
 		if err := c.BindJSON(&requestData); err != nil {
 			c.JSON(400, gin.H{"error": "Invalid request data"})
@@ -1678,14 +1730,14 @@ func UpdateTagsHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		tx, err := db.Begin()
+		tx, err := db.BeginTx(ctx, nil)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Error starting transaction"})
 			return
 		}
 		defer tx.Rollback()
 
-		_, err = tx.Exec("DELETE FROM user_tags WHERE user_id = $1", userID)
+		_, err = tx.ExecContext(ctx, "DELETE FROM user_tags WHERE user_id = $1", userID)
 		if err != nil {
 			log.Printf("Error deleting old tags: %v", err)
 			c.JSON(500, gin.H{"error": "Error updating tags"})
@@ -1693,22 +1745,7 @@ func UpdateTagsHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		for _, tagName := range normalizedUniqueTags {
-			var tagID int
-			err := tx.QueryRow("SELECT id FROM tags WHERE name = $1", tagName).Scan(&tagID)
-			if err == sql.ErrNoRows {
-				err = tx.QueryRow("INSERT INTO tags (name) VALUES ($1) RETURNING id", tagName).Scan(&tagID)
-				if err != nil {
-					log.Printf("Error creating tag: %v", err)
-					c.JSON(500, gin.H{"error": "Error creating tag"})
-					return
-				}
-			} else if err != nil {
-				log.Printf("Error checking tag: %v", err)
-				c.JSON(500, gin.H{"error": "Error checking tag"})
-				return
-			}
-
-			_, err = tx.Exec("INSERT INTO user_tags (user_id, tag_id) VALUES ($1, $2)", userID, tagID)
+			_, err := tx.ExecContext(ctx, addUserTagQuery, userID, tagName)
 			if err != nil {
 				log.Printf("Error associating tag: %v", err)
 				c.JSON(500, gin.H{"error": "Error associating tag"})
@@ -1722,12 +1759,14 @@ func UpdateTagsHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(200, gin.H{"message": "Tags updated successfully"})
+		c.JSON(200, MessageResponse{Message: "Tags updated successfully"})
 	}
 }
 
 func GetSuggestionsHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -1739,216 +1778,101 @@ func GetSuggestionsHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		var currentUserOrientation string
-		err := db.QueryRow("SELECT COALESCE(orientation, 'likes men and women') FROM users WHERE id = $1", userID).Scan(&currentUserOrientation)
+		page, err := parsePageParams(c, "suggestions")
 		if err != nil {
-			log.Printf("Error fetching current user orientation: %v", err)
-			c.JSON(500, gin.H{"error": "Database error"})
+			c.JSON(400, gin.H{"error": err.Error()})
 			return
 		}
 
-		var currentUserGender string
-		err = db.QueryRow("SELECT COALESCE(gender, 'Woman') FROM users WHERE id = $1", userID).Scan(&currentUserGender)
+		currentUser, err := loadSuggestionViewer(ctx, db, userID)
 		if err != nil {
-			log.Printf("Error fetching current user gender: %v", err)
+			log.Printf("Error fetching current user: %v", err)
 			c.JSON(500, gin.H{"error": "Database error"})
 			return
 		}
+		currentUserLat, currentUserLon := currentUser.Lat, currentUser.Lon
 
-		currentUserTags := []string{}
-		currentTagRows, err := db.Query(`
-		SELECT t.name FROM tags t 
-		JOIN user_tags ut ON t.id = ut.tag_id 
-		WHERE ut.user_id = $1
-	`, userID)
-		if err == nil {
-			defer currentTagRows.Close()
-			for currentTagRows.Next() {
-				var tagName string
-				if currentTagRows.Scan(&tagName) == nil {
-					currentUserTags = append(currentUserTags, tagName)
-				}
-			}
-		}
-
-		var currentUserLat, currentUserLon sql.NullFloat64
-		err = db.QueryRow(`
-		SELECT lat, lon FROM user_locations WHERE user_id = $1
-	`, userID).Scan(&currentUserLat, &currentUserLon)
-
-		var targetGenders []string
-		lowerOrientation := strings.ToLower(strings.TrimSpace(currentUserOrientation))
-
-		if lowerOrientation == "likes men and women" || lowerOrientation == "likes both" || lowerOrientation == "both" {
-			targetGenders = []string{"Man", "Woman"}
-		} else if lowerOrientation == "likes men" || lowerOrientation == "men" {
-			targetGenders = []string{"Man"}
-		} else if lowerOrientation == "likes women" || lowerOrientation == "women" {
-			targetGenders = []string{"Woman"}
-		} else if strings.HasSuffix(lowerOrientation, "men and women") {
-			targetGenders = []string{"Man", "Woman"}
-		} else if strings.HasSuffix(lowerOrientation, "men") {
-			targetGenders = []string{"Man"}
-		} else if strings.HasSuffix(lowerOrientation, "women") {
-			targetGenders = []string{"Woman"}
-		} else {
-			targetGenders = []string{"Man", "Woman"}
+		viewer, err := newViewerScoreProfile(ctx, db, userID, currentUserLat, currentUserLon, currentUser.Tags)
+		if err != nil {
+			log.Printf("Error fetching recommendations: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
 		}
 
-		orientationClauses := []string{}
-		args := []interface{}{userID, pq.Array(targetGenders)}
-
-		for _, gender := range targetGenders {
-
-			if gender == "Man" {
-				if currentUserGender == "Woman" {
-					orientationClauses = append(orientationClauses,
-						"(u.gender = 'Man' AND (u.orientation = 'likes women' OR u.orientation = 'likes men and women'))")
-				} else if currentUserGender == "Man" {
-					orientationClauses = append(orientationClauses,
-						"(u.gender = 'Man' AND (u.orientation = 'likes men' OR u.orientation = 'likes men and women'))")
-				}
-			} else if gender == "Woman" {
-				if currentUserGender == "Woman" {
-					orientationClauses = append(orientationClauses,
-						"(u.gender = 'Woman' AND (u.orientation = 'likes women' OR u.orientation = 'likes men and women'))")
-				} else if currentUserGender == "Man" {
-					orientationClauses = append(orientationClauses,
-						"(u.gender = 'Woman' AND (u.orientation = 'likes men' OR u.orientation = 'likes men and women'))")
-				}
-			}
+		// Viewers in the running experiment are ranked with their variant's
+		// weights, and what they are shown is logged for its report.
+		scorer, assignment, err := suggestionScorer(ctx, db, userID)
+		if err != nil {
+			log.Printf("Error fetching running experiment: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
 		}
 
-		orientationFilter := strings.Join(orientationClauses, " OR ")
-
-		minAgeStr := c.DefaultQuery("minAge", "")
-		maxAgeStr := c.DefaultQuery("maxAge", "")
-		minFameStr := c.DefaultQuery("minFame", "")
-		maxFameStr := c.DefaultQuery("maxFame", "")
-		maxDistanceStr := c.DefaultQuery("maxDistance", "")
-		tagsStr := c.DefaultQuery("tags", "")
-
-		var additionalFilters []string
-
-		if tagsStr != "" {
-			const maxRequiredTags = 10
-
-			requiredTags := strings.Split(tagsStr, ",")
-			uniqueRequiredTags := make([]string, 0, len(requiredTags))
-			seenTags := make(map[string]struct{}, len(requiredTags))
-
-			for _, tag := range requiredTags {
-				trimmed := strings.TrimSpace(tag)
-				if trimmed == "" {
-					continue
-				}
-				trimmed = strings.ToLower(trimmed)
-				if _, exists := seenTags[trimmed]; exists {
-					continue
-				}
-
-				seenTags[trimmed] = struct{}{}
-				uniqueRequiredTags = append(uniqueRequiredTags, trimmed)
-
-				if len(uniqueRequiredTags) > maxRequiredTags {
-					c.JSON(400, gin.H{"error": fmt.Sprintf("Too many tags provided; maximum is %d", maxRequiredTags)})
-					return
-				}
+		// Later pages are read from the ranking snapshot taken for the first
+		// one; sort and filters only apply when it is taken.
+		if page.Cursor != nil {
+			ranked, err := loadSuggestionSnapshot(ctx, db, userID, page.Cursor.Snapshot)
+			if err == errSnapshotExpired {
+				c.JSON(400, gin.H{"error": err.Error(), "code": "snapshot_expired"})
+				return
 			}
-
-			for _, tag := range uniqueRequiredTags {
-				args = append(args, tag)
-				placeholder := "$" + strconv.Itoa(len(args))
-				additionalFilters = append(additionalFilters, fmt.Sprintf(`EXISTS (
-                                                SELECT 1 FROM user_tags ut
-                                                JOIN tags t ON ut.tag_id = t.id
-																			WHERE ut.user_id = u.id AND LOWER(t.name) = %s
-																		)`, placeholder))
+			if err != nil {
+				log.Printf("Error loading suggestion snapshot: %v", err)
+				c.JSON(500, gin.H{"error": "Database error"})
+				return
 			}
-		}
-
-		if minAgeStr != "" || maxAgeStr != "" {
-			minAge := 18
-			maxAge := 99
 
-			if minAgeStr != "" {
-				if v, err := strconv.Atoi(minAgeStr); err == nil {
-					minAge = v
-				}
+			start := min(page.Cursor.Offset, len(ranked))
+			end := min(start+page.Limit, len(ranked))
+			suggestions, err := loadSnapshotPage(ctx, db, userID, ranked[start:end], currentUserLat, currentUserLon)
+			if err == nil {
+				err = scoreSuggestions(ctx, db, suggestions, scorer, viewer)
 			}
-			if maxAgeStr != "" {
-				if v, err := strconv.Atoi(maxAgeStr); err == nil {
-					maxAge = v
-				}
+			if err != nil {
+				log.Printf("Error loading suggestions: %v", err)
+				c.JSON(500, gin.H{"error": "Database error"})
+				return
 			}
+			recordImpressions(ctx, db, assignment, userID, suggestions, start)
 
-			args = append(args, minAge, maxAge)
-			minAgePlaceholder := "$" + strconv.Itoa(len(args)-1)
-			maxAgePlaceholder := "$" + strconv.Itoa(len(args))
-			additionalFilters = append(additionalFilters,
-				"EXTRACT(YEAR FROM AGE(NOW(), u.birthday)) BETWEEN "+minAgePlaceholder+" AND "+maxAgePlaceholder)
-		}
-
-		if minFameStr != "" {
-			if v, err := strconv.ParseFloat(minFameStr, 64); err == nil {
-				args = append(args, v)
-				famePlaceholder := "$" + strconv.Itoa(len(args))
-				additionalFilters = append(additionalFilters, "u.fame_rating >= "+famePlaceholder)
+			var nextCursor *string
+			if end < len(ranked) {
+				nextCursor = snapshotCursor("suggestions", page.Cursor.Snapshot, end)
 			}
+			c.JSON(200, UsersResponse{Users: suggestionUsers(suggestions), NextCursor: nextCursor})
+			return
 		}
 
-		if maxFameStr != "" {
-			if v, err := strconv.ParseFloat(maxFameStr, 64); err == nil {
-				args = append(args, v)
-				famePlaceholder := "$" + strconv.Itoa(len(args))
-				additionalFilters = append(additionalFilters, "u.fame_rating <= "+famePlaceholder)
-			}
+		order, err := parseSuggestionSort(c)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
 		}
 
-		if maxDistanceStr != "" {
-			if maxDist, err := strconv.ParseFloat(maxDistanceStr, 64); err == nil && maxDist > 0 {
-				var userLat, userLon sql.NullFloat64
-				err = db.QueryRow(`
-					SELECT lat, lon FROM user_locations WHERE user_id = $1
-				`, userID).Scan(&userLat, &userLon)
-
-				if err == nil && userLat.Valid && userLon.Valid {
-					args = append(args, userLat.Float64, userLon.Float64, maxDist)
-					latPlaceholder := "$" + strconv.Itoa(len(args)-2)
-					lonPlaceholder := "$" + strconv.Itoa(len(args)-1)
-					distancePlaceholder := "$" + strconv.Itoa(len(args))
-					additionalFilters = append(additionalFilters, `
-						(6371 * 2 * ASIN(SQRT(
-							POWER(SIN(RADIANS((u_loc.lat - `+latPlaceholder+`) / 2)), 2) +
-							COS(RADIANS(`+latPlaceholder+`)) * COS(RADIANS(u_loc.lat)) *
-							POWER(SIN(RADIANS((u_loc.lon - `+lonPlaceholder+`) / 2)), 2)
-						)) <= `+distancePlaceholder+` OR u_loc.lat IS NULL)
-					`)
-				}
-			}
+		filters := suggestionFiltersFromQuery(c)
+		preferences, err := loadDiscoveryPreferences(ctx, db, userID)
+		if err != nil {
+			log.Printf("Error fetching discovery preferences: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
 		}
+		filters.applyPreferences(preferences)
 
-		additionalFilterClause := ""
-		if len(additionalFilters) > 0 {
-			additionalFilterClause = " AND " + strings.Join(additionalFilters, " AND ")
+		conditions, args, err := suggestionConditions(userID, currentUser, filters)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
 		}
 
 		query := `
-			SELECT u.id, u.username, u.first_name, u.last_name, u.email, u.gender, u.orientation, 
-			       u.birthday, u.bio, u.avatar_url, u.fame_rating, u.last_seen
+			SELECT` + suggestionColumns + `
 			FROM users u
 			LEFT JOIN user_locations u_loc ON u.id = u_loc.user_id
-			WHERE u.verified = true 
-			  AND u.id != $1
-			  AND u.gender = ANY($2)
-			  AND (` + orientationFilter + `)
-			  AND NOT EXISTS (
-			    SELECT 1 FROM blocks
-			    WHERE (blocker_id = $1 AND blocked_id = u.id)
-			       OR (blocker_id = u.id AND blocked_id = $1)
-			  )` + additionalFilterClause
-
-		rows, err := db.Query(query, args...)
+			WHERE ` + joinConditions(conditions)
+
+		// The whole candidate set is scored and sorted so that every page
+		// follows the same ranking.
+		rows, err := db.QueryContext(ctx, query, args...)
 		if err != nil {
 			log.Printf("Error querying suggestions: %v", err)
 			c.JSON(500, gin.H{"error": "Database error"})
@@ -1956,140 +1880,34 @@ func GetSuggestionsHandler(db *sql.DB) gin.HandlerFunc {
 		}
 		defer rows.Close()
 
-		users := []gin.H{}
-		for rows.Next() {
-			var userID int
-			var username, email, firstName, lastName string
-			var gender, orientation, bio, avatarURL sql.NullString
-			var birthday, lastSeen sql.NullTime
-			var fameRating sql.NullFloat64
-
-			err := rows.Scan(
-				&userID, &username, &firstName, &lastName, &email,
-				&gender, &orientation, &birthday, &bio, &avatarURL, &fameRating, &lastSeen,
-			)
-			if err != nil {
-				log.Printf("Error scanning user: %v", err)
-				continue
-			}
-
-			user := gin.H{
-				"id":         userID,
-				"username":   username,
-				"email":      email,
-				"first_name": firstName,
-				"last_name":  lastName,
-				"is_online":  IsUserOnline(userID),
-			}
-			if lastSeen.Valid {
-				user["last_seen"] = lastSeen.Time.Format(time.RFC3339)
-				if val, ok := user["is_online"].(bool); ok && !val {
-					user["is_online"] = time.Since(lastSeen.Time) < 5*time.Minute
-				}
-			}
-
-			if gender.Valid {
-				user["gender"] = gender.String
-			}
-			if orientation.Valid {
-				user["orientation"] = orientation.String
-			}
-			if birthday.Valid {
-				user["birthday"] = birthday.Time.Format("2006-01-02")
-			}
-			if bio.Valid {
-				user["bio"] = bio.String
-			}
-			if avatarURL.Valid {
-				user["avatar_url"] = avatarURL.String
-			}
-			if fameRating.Valid {
-				user["fame_rating"] = fameRating.Float64
-			} else {
-				user["fame_rating"] = 0.0
-			}
-
-			user["is_online"] = IsUserOnline(userID)
-			if lastSeen.Valid {
-				user["last_seen"] = lastSeen.Time.Format(time.RFC3339)
-				if !user["is_online"].(bool) {
-					user["is_online"] = time.Since(lastSeen.Time) < 5*time.Minute
-				}
-			}
-
-			tags := []string{}
-			tagRows, err := db.Query(`
-				SELECT t.name 
-				FROM tags t 
-				JOIN user_tags ut ON t.id = ut.tag_id 
-				WHERE ut.user_id = $1
-			`, userID)
-			if err == nil {
-				defer tagRows.Close()
-				for tagRows.Next() {
-					var tagName string
-					if err := tagRows.Scan(&tagName); err == nil {
-						tags = append(tags, tagName)
-					}
-				}
-			}
-			user["tags"] = tags
-
-			var lat, lon sql.NullFloat64
-			err = db.QueryRow(`
-				SELECT lat, lon 
-				FROM user_locations 
-				WHERE user_id = $1
-			`, userID).Scan(&lat, &lon)
-
-			if err == nil && lat.Valid && lon.Valid {
-				user["latitude"] = lat.Float64
-				user["longitude"] = lon.Float64
-
-				if currentUserLat.Valid && currentUserLon.Valid {
-					distance := haversineDistance(
-						currentUserLat.Float64, currentUserLon.Float64,
-						lat.Float64, lon.Float64,
-					)
-					user["distance_km"] = distance
-				} else {
-					user["distance_km"] = -1
-				}
-			} else {
-				user["distance_km"] = -1
-			}
-
-			commonCount := 0
-			for _, tag := range tags {
-				for _, currentTag := range currentUserTags {
-					if tag == currentTag {
-						commonCount++
-						break
-					}
-				}
-			}
+		suggestions, err := scanSuggestions(rows, currentUserLat, currentUserLon)
+		if err == nil {
+			err = scoreSuggestions(ctx, db, suggestions, scorer, viewer)
+		}
+		if err != nil {
+			log.Printf("Error loading suggestions: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		sortSuggestions(suggestions, order)
 
-			fScore := 0.0
-			if fameRating.Valid {
-				fScore = fameRating.Float64
+		var nextCursor *string
+		if len(suggestions) > page.Limit {
+			ranked := make([]int, len(suggestions))
+			for i := range suggestions {
+				ranked[i] = suggestions[i].user.ID
 			}
-
-			matchScore := 0.0
-			if lat.Valid && lon.Valid && currentUserLat.Valid && currentUserLon.Valid {
-				matchScore = calculateMatchScore(
-					currentUserLat.Float64, currentUserLon.Float64,
-					lat.Float64, lon.Float64,
-					currentUserTags, tags,
-					fScore,
-				)
+			token, err := saveSuggestionSnapshot(ctx, db, userID, order, ranked)
+			if err != nil {
+				log.Printf("Error saving suggestion snapshot: %v", err)
+				c.JSON(500, gin.H{"error": "Database error"})
+				return
 			}
-
-			user["match_score"] = matchScore
-			user["common_tags"] = commonCount
-
-			users = append(users, user)
+			nextCursor = snapshotCursor("suggestions", token, page.Limit)
+			suggestions = suggestions[:page.Limit]
 		}
+		recordImpressions(ctx, db, assignment, userID, suggestions, 0)
 
-		c.JSON(200, gin.H{"users": users})
+		c.JSON(200, UsersResponse{Users: suggestionUsers(suggestions), NextCursor: nextCursor})
 	}
 }
@@ -15,6 +15,8 @@ import (
 
 func UploadImageHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -23,7 +25,7 @@ func UploadImageHandler(db *sql.DB) gin.HandlerFunc {
 		userID := userIDVal.(int)
 
 		var count int
-		err := db.QueryRow("SELECT COUNT(*) FROM user_images WHERE user_id = $1", userID).Scan(&count)
+		err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM user_images WHERE user_id = $1", userID).Scan(&count)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Database error"})
 			return
@@ -62,22 +64,24 @@ func UploadImageHandler(db *sql.DB) gin.HandlerFunc {
 
 		isProfilePic := (count == 0)
 
-		_, err = db.Exec("INSERT INTO user_images (user_id, path, is_profile_picture) VALUES ($1, $2, $3)", userID, webPath, isProfilePic)
+		_, err = db.ExecContext(ctx, "INSERT INTO user_images (user_id, path, is_profile_picture) VALUES ($1, $2, $3)", userID, webPath, isProfilePic)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Database error"})
 			return
 		}
 
 		if isProfilePic {
-			db.Exec("UPDATE users SET avatar_url = $1 WHERE id = $2", webPath, userID)
+			db.ExecContext(ctx, "UPDATE users SET avatar_url = $1 WHERE id = $2", webPath, userID)
 		}
 
-		c.JSON(200, gin.H{"message": "Image uploaded", "path": webPath, "is_profile_picture": isProfilePic})
+		c.JSON(200, UploadImageResponse{Message: "Image uploaded", Path: webPath, IsProfilePicture: isProfilePic})
 	}
 }
 
 func DeleteImageHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -94,7 +98,7 @@ func DeleteImageHandler(db *sql.DB) gin.HandlerFunc {
 
 		var path string
 		var isProfilePic bool
-		err = db.QueryRow("SELECT path, is_profile_picture FROM user_images WHERE id = $1 AND user_id = $2", imageID, userID).Scan(&path, &isProfilePic)
+		err = db.QueryRowContext(ctx, "SELECT path, is_profile_picture FROM user_images WHERE id = $1 AND user_id = $2", imageID, userID).Scan(&path, &isProfilePic)
 		if err == sql.ErrNoRows {
 			c.JSON(404, gin.H{"error": "Image not found"})
 			return
@@ -103,7 +107,7 @@ func DeleteImageHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		_, err = db.Exec("DELETE FROM user_images WHERE id = $1", imageID)
+		_, err = db.ExecContext(ctx, "DELETE FROM user_images WHERE id = $1", imageID)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Error deleting from database"})
 			return
@@ -112,22 +116,24 @@ func DeleteImageHandler(db *sql.DB) gin.HandlerFunc {
 		localPath := "." + path
 		os.Remove(localPath)
 		if isProfilePic {
-			db.Exec("UPDATE users SET avatar_url = NULL WHERE id = $1", userID)
+			db.ExecContext(ctx, "UPDATE users SET avatar_url = NULL WHERE id = $1", userID)
 			var newPicID int
 			var newPicPath string
-			err = db.QueryRow("SELECT id, path FROM user_images WHERE user_id = $1 LIMIT 1", userID).Scan(&newPicID, &newPicPath)
+			err = db.QueryRowContext(ctx, "SELECT id, path FROM user_images WHERE user_id = $1 LIMIT 1", userID).Scan(&newPicID, &newPicPath)
 			if err == nil {
-				db.Exec("UPDATE user_images SET is_profile_picture = TRUE WHERE id = $1", newPicID)
-				db.Exec("UPDATE users SET avatar_url = $1 WHERE id = $2", newPicPath, userID)
+				db.ExecContext(ctx, "UPDATE user_images SET is_profile_picture = TRUE WHERE id = $1", newPicID)
+				db.ExecContext(ctx, "UPDATE users SET avatar_url = $1 WHERE id = $2", newPicPath, userID)
 			}
 		}
 
-		c.JSON(200, gin.H{"message": "Image deleted"})
+		c.JSON(200, MessageResponse{Message: "Image deleted"})
 	}
 }
 
 func GetUserImagesHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		targetUserIDStr := c.Param("userId")
 		targetUserID, err := strconv.Atoi(targetUserIDStr)
 		if err != nil {
@@ -135,35 +141,29 @@ func GetUserImagesHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		rows, err := db.Query("SELECT id, path, is_profile_picture, created_at FROM user_images WHERE user_id = $1 ORDER BY created_at DESC", targetUserID)
+		rows, err := db.QueryContext(ctx, "SELECT id, path, is_profile_picture, created_at FROM user_images WHERE user_id = $1 ORDER BY created_at DESC", targetUserID)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Database error"})
 			return
 		}
 		defer rows.Close()
 
-		images := []gin.H{}
+		images := []UserImage{}
 		for rows.Next() {
-			var id int
-			var path string
-			var isProfilePic bool
-			var createdAt string
-			if err := rows.Scan(&id, &path, &isProfilePic, &createdAt); err == nil {
-				images = append(images, gin.H{
-					"id":                 id,
-					"path":               path,
-					"is_profile_picture": isProfilePic,
-					"created_at":         createdAt,
-				})
+			var image UserImage
+			if err := rows.Scan(&image.ID, &image.Path, &image.IsProfilePicture, &image.CreatedAt); err == nil {
+				images = append(images, image)
 			}
 		}
 
-		c.JSON(200, gin.H{"images": images})
+		c.JSON(200, UserImagesResponse{Images: images})
 	}
 }
 
 func SetProfilePictureHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -179,33 +179,33 @@ func SetProfilePictureHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		var path string
-		err = db.QueryRow("SELECT path FROM user_images WHERE id = $1 AND user_id = $2", imageID, userID).Scan(&path)
+		err = db.QueryRowContext(ctx, "SELECT path FROM user_images WHERE id = $1 AND user_id = $2", imageID, userID).Scan(&path)
 		if err != nil {
 			c.JSON(404, gin.H{"error": "Image not found"})
 			return
 		}
 
-		tx, err := db.Begin()
+		tx, err := db.BeginTx(ctx, nil)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Database error"})
 			return
 		}
 
-		_, err = tx.Exec("UPDATE user_images SET is_profile_picture = FALSE WHERE user_id = $1", userID)
+		_, err = tx.ExecContext(ctx, "UPDATE user_images SET is_profile_picture = FALSE WHERE user_id = $1", userID)
 		if err != nil {
 			tx.Rollback()
 			c.JSON(500, gin.H{"error": "Error updating images"})
 			return
 		}
 
-		_, err = tx.Exec("UPDATE user_images SET is_profile_picture = TRUE WHERE id = $1", imageID)
+		_, err = tx.ExecContext(ctx, "UPDATE user_images SET is_profile_picture = TRUE WHERE id = $1", imageID)
 		if err != nil {
 			tx.Rollback()
 			c.JSON(500, gin.H{"error": "Error setting profile picture"})
 			return
 		}
 
-		_, err = tx.Exec("UPDATE users SET avatar_url = $1 WHERE id = $2", path, userID)
+		_, err = tx.ExecContext(ctx, "UPDATE users SET avatar_url = $1 WHERE id = $2", path, userID)
 		if err != nil {
 			tx.Rollback()
 			c.JSON(500, gin.H{"error": "Error updating user avatar"})
@@ -213,6 +213,6 @@ func SetProfilePictureHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		tx.Commit()
-		c.JSON(200, gin.H{"message": "Profile picture updated"})
+		c.JSON(200, MessageResponse{Message: "Profile picture updated"})
 	}
 }
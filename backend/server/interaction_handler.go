@@ -4,12 +4,15 @@ import (
 	"database/sql"
 	"log"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 func BlockUserHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		blockerIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -29,7 +32,7 @@ func BlockUserHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		_, err = db.Exec(`
+		_, err = db.ExecContext(ctx, `
 			INSERT INTO blocks (blocker_id, blocked_id) VALUES ($1, $2)
 			ON CONFLICT DO NOTHING
 		`, blockerID, blockedID)
@@ -40,14 +43,16 @@ func BlockUserHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		db.Exec("DELETE FROM profile_likes WHERE (liker_id = $1 AND liked_id = $2) OR (liker_id = $2 AND liked_id = $1)", blockerID, blockedID)
+		db.ExecContext(ctx, "DELETE FROM profile_likes WHERE (liker_id = $1 AND liked_id = $2) OR (liker_id = $2 AND liked_id = $1)", blockerID, blockedID)
 
-		c.JSON(200, gin.H{"message": "User blocked"})
+		c.JSON(200, MessageResponse{Message: "User blocked"})
 	}
 }
 
 func ReportUserHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		reporterIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -62,27 +67,27 @@ func ReportUserHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		var request struct {
-			Reason string `json:"reason" binding:"required"`
-		}
+		var request ReportUserRequest
 		if err := c.BindJSON(&request); err != nil {
 			c.JSON(400, gin.H{"error": "Reason is required"})
 			return
 		}
 
-		_, err = db.Exec("INSERT INTO reports (reporter_id, reported_id, reason) VALUES ($1, $2, $3)", reporterID, reportedID, request.Reason)
+		_, err = db.ExecContext(ctx, "INSERT INTO reports (reporter_id, reported_id, reason) VALUES ($1, $2, $3)", reporterID, reportedID, request.Reason)
 		if err != nil {
 			log.Printf("Error reporting user: %v", err)
 			c.JSON(500, gin.H{"error": "Database error"})
 			return
 		}
 
-		c.JSON(200, gin.H{"message": "User reported"})
+		c.JSON(200, MessageResponse{Message: "User reported"})
 	}
 }
 
 func GetBlockedUsersHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -90,13 +95,21 @@ func GetBlockedUsersHandler(db *sql.DB) gin.HandlerFunc {
 		}
 		userID := userIDVal.(int)
 
-		rows, err := db.Query(`
-			SELECT u.id, u.username, u.first_name, u.last_name, u.avatar_url
+		page, err := parsePageParams(c, "blocked")
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		rows, err := db.QueryContext(ctx, `
+			SELECT u.id, u.username, u.first_name, u.last_name, u.avatar_url, b.created_at
 			FROM users u
 			JOIN blocks b ON b.blocked_id = u.id
 			WHERE b.blocker_id = $1
-			ORDER BY b.created_at DESC
-		`, userID)
+			  AND ($2::timestamp IS NULL OR (b.created_at, u.id) < ($2::timestamp, $3))
+			ORDER BY b.created_at DESC, u.id DESC
+			LIMIT $4
+		`, userID, page.cursorTime(), page.cursorID(), page.Limit+1)
 		if err != nil {
 			log.Printf("Error fetching blocked users: %v", err)
 			c.JSON(500, gin.H{"error": "Database error"})
@@ -104,31 +117,35 @@ func GetBlockedUsersHandler(db *sql.DB) gin.HandlerFunc {
 		}
 		defer rows.Close()
 
-		blockedUsers := []gin.H{}
+		blockedUsers := []UserSummary{}
+		var nextCursor *string
+		var lastBlockedAt time.Time
 		for rows.Next() {
-			var id int
-			var username, firstName, lastName string
+			if len(blockedUsers) == page.Limit {
+				nextCursor = timeCursor("blocked", lastBlockedAt, blockedUsers[len(blockedUsers)-1].ID)
+				break
+			}
+
+			var user UserSummary
 			var avatarURL sql.NullString
-			if err := rows.Scan(&id, &username, &firstName, &lastName, &avatarURL); err == nil {
-				user := gin.H{
-					"id":         id,
-					"username":   username,
-					"first_name": firstName,
-					"last_name":  lastName,
-				}
+			var blockedAt time.Time
+			if err := rows.Scan(&user.ID, &user.Username, &user.FirstName, &user.LastName, &avatarURL, &blockedAt); err == nil {
 				if avatarURL.Valid {
-					user["avatar_url"] = avatarURL.String
+					user.AvatarURL = &avatarURL.String
 				}
 				blockedUsers = append(blockedUsers, user)
+				lastBlockedAt = blockedAt
 			}
 		}
 
-		c.JSON(200, gin.H{"blocked_users": blockedUsers})
+		c.JSON(200, BlockedUsersResponse{BlockedUsers: blockedUsers, NextCursor: nextCursor})
 	}
 }
 
 func UnblockUserHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -143,7 +160,7 @@ func UnblockUserHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		result, err := db.Exec("DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2", userID, blockedID)
+		result, err := db.ExecContext(ctx, "DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2", userID, blockedID)
 		if err != nil {
 			log.Printf("Error unblocking user: %v", err)
 			c.JSON(500, gin.H{"error": "Database error"})
@@ -156,6 +173,6 @@ func UnblockUserHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(200, gin.H{"message": "User unblocked"})
+		c.JSON(200, MessageResponse{Message: "User unblocked"})
 	}
 }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"time"
+
+	"matcha/store"
+)
+
+// Intervals of the periodic jobs. Setting RECOMMENDATIONS_INTERVAL,
+// FAME_INTERVAL, SAVED_SEARCH_INTERVAL, TAG_GC_INTERVAL or
+// DAILY_PICKS_INTERVAL to 0 disables that job, for instance when
+// matcha-admin runs it from cron.
+var (
+	// recommendationsInterval is how often the collaborative-filtering
+	// recommendations are rebuilt from likes.
+	recommendationsInterval = envInterval("RECOMMENDATIONS_INTERVAL", 6*time.Hour)
+	// fameInterval is how often fame ratings are recomputed.
+	fameInterval = envInterval("FAME_INTERVAL", time.Hour)
+	// savedSearchInterval is how often saved searches with alerts are run.
+	savedSearchInterval = envInterval("SAVED_SEARCH_INTERVAL", time.Hour)
+	// tagGCInterval is how often tags no user has are deleted.
+	tagGCInterval = envInterval("TAG_GC_INTERVAL", 24*time.Hour)
+	// dailyPicksInterval is how often users whose daily picks expired get
+	// new ones.
+	dailyPicksInterval = envInterval("DAILY_PICKS_INTERVAL", time.Hour)
+)
+
+// jobTimeout bounds one run of a job.
+const jobTimeout = 10 * time.Minute
+
+func envInterval(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval < 0 {
+		log.Printf("Invalid %s %q, keeping %s", name, value, fallback)
+		return fallback
+	}
+	return interval
+}
+
+// StartRecommendationJob rebuilds recommendations at startup, then every
+// recommendationsInterval. Servers sharing a database skip a run another one
+// is doing.
+func StartRecommendationJob(db *sql.DB) {
+	startPeriodicJob("recommendations", recommendationsInterval, store.New(db).RecomputeRecommendations)
+}
+
+// StartFameJob recomputes fame ratings at startup, then every fameInterval,
+// with the same locking as recommendations.
+func StartFameJob(db *sql.DB) {
+	startPeriodicJob("fame ratings", fameInterval, store.New(db).RecomputeFameRatings)
+}
+
+// StartSavedSearchJob notifies users of new profiles matching their saved
+// searches, checking every savedSearchInterval.
+func StartSavedSearchJob(db *sql.DB) {
+	startPeriodicJob("saved search alerts", savedSearchInterval, func(ctx context.Context) (int, error) {
+		return checkSavedSearchAlerts(ctx, db)
+	})
+}
+
+// StartTagGCJob deletes the tags no user has, every tagGCInterval.
+func StartTagGCJob(db *sql.DB) {
+	startPeriodicJob("tag garbage collection", tagGCInterval, store.New(db).DeleteUnusedTags)
+}
+
+// StartDailyPicksJob computes the daily picks of active users, checking for
+// expired picks every dailyPicksInterval.
+func StartDailyPicksJob(db *sql.DB) {
+	startPeriodicJob("daily picks", dailyPicksInterval, func(ctx context.Context) (int, error) {
+		return computeDailyPicks(ctx, db)
+	})
+}
+
+// startPeriodicJob runs job now and then every interval in the background.
+// job returns the number of items it processed.
+func startPeriodicJob(name string, interval time.Duration, job func(context.Context) (int, error)) {
+	if interval == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			runJob(name, job)
+			<-ticker.C
+		}
+	}()
+}
+
+func runJob(name string, job func(context.Context) (int, error)) {
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	defer cancel()
+
+	start := time.Now()
+	processed, err := job(ctx)
+	if err != nil {
+		log.Printf("Error running %s job: %v", name, err)
+		return
+	}
+	log.Printf("Ran %s job on %d items in %s", name, processed, time.Since(start).Round(time.Millisecond))
+}
@@ -2,12 +2,15 @@ package main
 
 import (
 	"database/sql"
+	"log"
 
 	"github.com/gin-gonic/gin"
 )
 
 func AuthMiddleware(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		cookie, err := c.Cookie("session_token")
 		if err != nil {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -16,7 +19,7 @@ func AuthMiddleware(db *sql.DB) gin.HandlerFunc {
 		}
 
 		var userID int
-		err = db.QueryRow("SELECT id FROM users WHERE session_token = $1", cookie).Scan(&userID)
+		err = db.QueryRowContext(ctx, "SELECT id FROM users WHERE session_token = $1 AND banned_at IS NULL", cookie).Scan(&userID)
 		if err != nil {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
 			c.Abort()
@@ -26,9 +29,34 @@ func AuthMiddleware(db *sql.DB) gin.HandlerFunc {
 		c.Set("userID", userID)
 
 		go func() {
-			_, _ = db.Exec("UPDATE users SET last_seen = CURRENT_TIMESTAMP WHERE id = $1", userID)
+			ctx, cancel := backgroundQueryContext()
+			defer cancel()
+			_, _ = db.ExecContext(ctx, "UPDATE users SET last_seen = CURRENT_TIMESTAMP WHERE id = $1", userID)
 		}()
 
 		c.Next()
 	}
 }
+
+// AdminMiddleware restricts a group to admins. It runs after AuthMiddleware.
+func AdminMiddleware(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var isAdmin bool
+		err := db.QueryRowContext(ctx, "SELECT is_admin FROM users WHERE id = $1", c.GetInt("userID")).Scan(&isAdmin)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("Error checking admin: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			c.Abort()
+			return
+		}
+		if !isAdmin {
+			c.JSON(403, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -5,13 +5,20 @@ import (
 	"encoding/json"
 	"log"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// CreateAndPushNotification runs with its own deadline rather than the
+// request context: the action that triggered it has already been saved, so
+// the notification must not be dropped if the client disconnects.
 func CreateAndPushNotification(db *sql.DB, userID int, notifType string, sourceID int, message string) {
+	ctx, cancel := backgroundQueryContext()
+	defer cancel()
+
 	var notifID int
-	err := db.QueryRow(`
+	err := db.QueryRowContext(ctx, `
 		INSERT INTO notifications (user_id, type, source_id, message, is_read, created_at)
 		VALUES ($1, $2, $3, $4, FALSE, NOW())
 		RETURNING id
@@ -22,7 +29,31 @@ func CreateAndPushNotification(db *sql.DB, userID int, notifType string, sourceI
 		return
 	}
 
-	notification := map[string]interface{}{
+	SendRenderedToUser(userID, func(version int) []byte {
+		jsonData, err := json.Marshal(notificationPush(version, notifID, notifType, sourceID, message))
+		if err != nil {
+			log.Printf("Error marshaling notification: %v", err)
+			return nil
+		}
+		return jsonData
+	})
+}
+
+// notificationPush builds the websocket payload for a new notification. v1
+// clients receive the historical camelCase keys; v2 uses snake_case like the
+// rest of the API.
+func notificationPush(version, notifID int, notifType string, sourceID int, message string) map[string]interface{} {
+	if version >= apiVersion2 {
+		return map[string]interface{}{
+			"type":       "notification",
+			"id":         notifID,
+			"notif_type": notifType,
+			"source_id":  sourceID,
+			"message":    message,
+			"is_read":    false,
+		}
+	}
+	return map[string]interface{}{
 		"type":      "notification",
 		"id":        notifID,
 		"notifType": notifType,
@@ -30,15 +61,27 @@ func CreateAndPushNotification(db *sql.DB, userID int, notifType string, sourceI
 		"message":   message,
 		"isRead":    false,
 	}
+}
 
-	jsonData, err := json.Marshal(notification)
-	if err == nil {
-		SendToUser(userID, jsonData)
+func notificationsV2(notifs []Notification) []NotificationV2 {
+	converted := make([]NotificationV2, len(notifs))
+	for i, n := range notifs {
+		converted[i] = NotificationV2{
+			ID:        n.ID,
+			NotifType: n.Type,
+			SourceID:  n.SourceID,
+			Message:   n.Message,
+			IsRead:    n.IsRead,
+			CreatedAt: n.CreatedAt,
+		}
 	}
+	return converted
 }
 
 func GetNotificationsHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -46,13 +89,20 @@ func GetNotificationsHandler(db *sql.DB) gin.HandlerFunc {
 		}
 		userID := userIDVal.(int)
 
-		rows, err := db.Query(`
+		page, err := parsePageParams(c, "notifications")
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		rows, err := db.QueryContext(ctx, `
 			SELECT id, type, source_id, message, is_read, created_at
 			FROM notifications
 			WHERE user_id = $1
-			ORDER BY created_at DESC
-			LIMIT 50
-		`, userID)
+			  AND ($2::timestamp IS NULL OR (created_at, id) < ($2::timestamp, $3))
+			ORDER BY created_at DESC, id DESC
+			LIMIT $4
+		`, userID, page.cursorTime(), page.cursorID(), page.Limit+1)
 		if err != nil {
 			log.Printf("Error fetching notifications: %v", err)
 			c.JSON(500, gin.H{"error": "Database error"})
@@ -60,29 +110,36 @@ func GetNotificationsHandler(db *sql.DB) gin.HandlerFunc {
 		}
 		defer rows.Close()
 
-		type Notification struct {
-			ID        int     `json:"id"`
-			Type      string  `json:"type"`
-			SourceID  *int    `json:"source_id"`
-			Message   *string `json:"message"`
-			IsRead    bool    `json:"is_read"`
-			CreatedAt string  `json:"created_at"`
-		}
-
 		var notifs []Notification
+		var nextCursor *string
+		var lastCreatedAt time.Time
 		for rows.Next() {
+			if len(notifs) == page.Limit {
+				nextCursor = timeCursor("notifications", lastCreatedAt, notifs[len(notifs)-1].ID)
+				break
+			}
+
 			var n Notification
-			if err := rows.Scan(&n.ID, &n.Type, &n.SourceID, &n.Message, &n.IsRead, &n.CreatedAt); err == nil {
+			var createdAt time.Time
+			if err := rows.Scan(&n.ID, &n.Type, &n.SourceID, &n.Message, &n.IsRead, &createdAt); err == nil {
+				n.CreatedAt = createdAt.Format(time.RFC3339Nano)
 				notifs = append(notifs, n)
+				lastCreatedAt = createdAt
 			}
 		}
 
-		c.JSON(200, gin.H{"notifications": notifs})
+		if apiVersion(c) >= apiVersion2 {
+			c.JSON(200, NotificationsResponseV2{Notifications: notificationsV2(notifs), NextCursor: nextCursor})
+			return
+		}
+		c.JSON(200, NotificationsResponse{Notifications: notifs, NextCursor: nextCursor})
 	}
 }
 
 func MarkNotificationReadHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		userIDVal, exists := c.Get("userID")
 		if !exists {
 			c.JSON(401, gin.H{"error": "Unauthorized"})
@@ -97,7 +154,7 @@ func MarkNotificationReadHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		result, err := db.Exec("UPDATE notifications SET is_read = TRUE WHERE id = $1 AND user_id = $2", notifID, userID)
+		result, err := db.ExecContext(ctx, "UPDATE notifications SET is_read = TRUE WHERE id = $1 AND user_id = $2", notifID, userID)
 		if err != nil {
 			c.JSON(500, gin.H{"error": "Database error"})
 			return
@@ -109,6 +166,6 @@ func MarkNotificationReadHandler(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(200, gin.H{"message": "Marked as read"})
+		c.JSON(200, MessageResponse{Message: "Marked as read"})
 	}
 }
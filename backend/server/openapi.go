@@ -0,0 +1,476 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiOperation documents one route registered in RegisterRoutes. The OpenAPI
+// document is generated from this table and from the request/response types,
+// so adding a route means adding an entry here as well.
+type apiOperation struct {
+	Method    string
+	Path      string
+	Summary   string
+	Tag       string
+	Public    bool
+	Query     []apiParam
+	JSONBody  interface{}
+	FormBody  interface{}
+	Multipart interface{}
+	Response  interface{}
+	// ResponseV2 replaces Response in /v2 when the response shape changed.
+	ResponseV2 interface{}
+	// RateLimited operations may answer 429 with a Retry-After header.
+	RateLimited bool
+	// Timeout overrides defaultQueryTimeout for slow operations.
+	Timeout time.Duration
+}
+
+type apiParam struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+}
+
+// pageQuery documents the parameters accepted by every cursor-paginated list.
+var pageQuery = []apiParam{
+	{Name: "limit", Type: "integer", Description: "Page size (default 50, max 100)"},
+	{Name: "cursor", Type: "string", Description: "next_cursor of the previous page"},
+}
+
+// suggestionFilterQuery documents the filters of suggestions, which default
+// to the saved discovery preferences.
+var suggestionFilterQuery = []apiParam{
+	{Name: "minAge", Type: "integer"},
+	{Name: "maxAge", Type: "integer"},
+	{Name: "minFame", Type: "number"},
+	{Name: "maxFame", Type: "number"},
+	{Name: "maxDistance", Type: "number", Description: "Maximum distance in kilometers"},
+	{Name: "tags", Type: "string", Description: "Comma separated list of required tags"},
+}
+
+var apiOperations = []apiOperation{
+	{Method: "GET", Path: "/ws", Summary: "Open the realtime websocket", Tag: "realtime", Public: true,
+		Query: []apiParam{{Name: "token", Type: "string", Description: "Session token when cookies are unavailable"}}},
+
+	{Method: "POST", Path: "/auth/register", Summary: "Create an account", Tag: "auth", Public: true, RateLimited: true, FormBody: RegisterForm{}, Response: MessageResponse{}},
+	{Method: "POST", Path: "/auth/login", Summary: "Log in and receive a session cookie", Tag: "auth", Public: true, RateLimited: true, FormBody: LoginForm{}, Response: MessageResponse{}},
+	{Method: "GET", Path: "/auth/verify", Summary: "Verify an email address", Tag: "auth", Public: true,
+		Query: []apiParam{{Name: "token", Type: "string", Required: true}}, Response: MessageResponse{}},
+	{Method: "POST", Path: "/auth/request-reset", Summary: "Send a password reset email", Tag: "auth", Public: true, RateLimited: true, FormBody: RequestPasswordResetForm{}, Response: MessageResponse{}},
+	{Method: "POST", Path: "/auth/reset-password", Summary: "Reset a password with a reset token", Tag: "auth", Public: true, FormBody: ResetPasswordForm{}, Response: MessageResponse{}},
+
+	{Method: "GET", Path: "/genders", Summary: "Genders users can pick for gender and interested_in", Tag: "users", Public: true, Response: GendersResponse{}},
+
+	{Method: "POST", Path: "/logout", Summary: "Invalidate the current session", Tag: "auth", Response: MessageResponse{}},
+
+	{Method: "GET", Path: "/me", Summary: "Current user profile", Tag: "users", Response: UserResponse{}},
+	{Method: "GET", Path: "/me/preferences", Summary: "Current user's discovery preferences", Tag: "users", Response: DiscoveryPreferences{}},
+	{Method: "PUT", Path: "/me/preferences", Summary: "Replace the current user's discovery preferences", Tag: "users", JSONBody: DiscoveryPreferences{}, Response: DiscoveryPreferences{}},
+	{Method: "GET", Path: "/me/searches", Summary: "Current user's saved searches", Tag: "users", Response: SavedSearchesResponse{}},
+	{Method: "POST", Path: "/me/searches", Summary: "Save a suggestions filter under a name", Tag: "users", JSONBody: SavedSearchRequest{}, Response: SavedSearch{}},
+	{Method: "DELETE", Path: "/me/searches/:searchId", Summary: "Delete a saved search", Tag: "users", Response: MessageResponse{}},
+	{Method: "PUT", Path: "/me/searches/:searchId/alerts", Summary: "Turn new-match alerts of a saved search on or off", Tag: "users", JSONBody: SavedSearchAlertsRequest{}, Response: SavedSearch{}},
+	{Method: "GET", Path: "/users", Summary: "List verified users", Tag: "users", Timeout: 10 * time.Second, Query: pageQuery, Response: UsersResponse{}},
+	{Method: "GET", Path: "/suggestions", Summary: "Suggested profiles for the current user", Tag: "users",
+		Query: append(append([]apiParam{}, suggestionFilterQuery...),
+			apiParam{Name: "sort", Type: "string", Description: "match (default), distance, age, fame or common_tags"},
+			apiParam{Name: "order", Type: "string", Description: "asc or desc (default depends on sort)"},
+			pageQuery[0],
+			apiParam{Name: "cursor", Type: "string", Description: "next_cursor of the previous page; sort and filters are those of the first page"},
+		),
+		Timeout:  15 * time.Second,
+		Response: UsersResponse{}},
+	{Method: "GET", Path: "/suggestions/:userId/explain", Summary: "Why a profile is suggested and how its match score adds up", Tag: "users",
+		Query:    suggestionFilterQuery,
+		Response: SuggestionExplanationResponse{}},
+	{Method: "GET", Path: "/deck", Summary: "Next profiles the current user has not liked, passed or blocked", Tag: "users",
+		Query:    []apiParam{{Name: "limit", Type: "integer", Description: "Number of profiles (default 10, max 50)"}},
+		Timeout:  15 * time.Second,
+		Response: DeckResponse{}},
+	{Method: "GET", Path: "/picks", Summary: "Today's curated high-compatibility profiles", Tag: "users", Response: PicksResponse{}},
+	{Method: "GET", Path: "/search", Summary: "Full-text search of profiles by name, tags and bio", Tag: "users",
+		Query: append([]apiParam{
+			{Name: "q", Type: "string", Required: true, Description: "Words to search; quotes, or and - work as in web search engines"},
+		}, pageQuery...),
+		Timeout:  10 * time.Second,
+		Response: SearchResponse{}},
+	{Method: "GET", Path: "/user/:userId", Summary: "Public profile of a user", Tag: "users", Response: UserResponse{}},
+
+	{Method: "GET", Path: "/tags", Summary: "Tags of the current user", Tag: "tags", Response: TagsResponse{}},
+	{Method: "POST", Path: "/tags", Summary: "Add a tag to the current user", Tag: "tags",
+		Query: []apiParam{{Name: "tag", Type: "string", Required: true}}, Response: MessageResponse{}},
+	{Method: "DELETE", Path: "/tags", Summary: "Remove a tag from the current user", Tag: "tags",
+		Query: []apiParam{{Name: "tag", Type: "string", Required: true}}, Response: MessageResponse{}},
+	{Method: "GET", Path: "/tags/search", Summary: "Tags starting with or similar to a query, most used first", Tag: "tags",
+		Query: []apiParam{
+			{Name: "q", Type: "string", Required: true},
+			{Name: "limit", Type: "integer", Description: "Number of tags (default 10, max 50)"},
+		},
+		Response: TagCountsResponse{}},
+	{Method: "GET", Path: "/tags/popular", Summary: "Most used tags, optionally around the current user", Tag: "tags",
+		Query: []apiParam{
+			{Name: "radius", Type: "number", Description: "Only count users within this many kilometers"},
+			{Name: "limit", Type: "integer", Description: "Number of tags (default 10, max 50)"},
+		},
+		Response: TagCountsResponse{}},
+
+	{Method: "POST", Path: "/location", Summary: "Update the current user's location", Tag: "location", JSONBody: UpdateLocationRequest{}, Response: UpdateLocationResponse{}},
+	{Method: "GET", Path: "/location/:userId", Summary: "Location of a user", Tag: "location", Response: UserLocationResponse{}},
+	{Method: "GET", Path: "/nearby", Summary: "Users around the current user", Tag: "location",
+		Query: []apiParam{
+			{Name: "radius", Type: "number", Description: "Search radius in kilometers"},
+			{Name: "limit", Type: "integer"},
+		},
+		Timeout:  10 * time.Second,
+		Response: NearbyUsersResponse{}},
+
+	{Method: "POST", Path: "/profile/:userId/view", Summary: "Record a profile view", Tag: "profile", RateLimited: true, Response: MessageResponse{}},
+	{Method: "POST", Path: "/profile/:userId/like", Summary: "Like or unlike a profile", Tag: "profile", RateLimited: true, Response: LikeToggleResponse{}},
+	{Method: "POST", Path: "/profile/:userId/pass", Summary: "Pass on a profile", Tag: "profile", RateLimited: true, Response: MessageResponse{}},
+	{Method: "DELETE", Path: "/profile/passes/last", Summary: "Undo the current user's last pass", Tag: "profile", Response: UndoPassResponse{}},
+	{Method: "GET", Path: "/profile/:userId/stats", Summary: "View, like and fame statistics", Tag: "profile", Response: ProfileStatsResponse{}},
+	{Method: "GET", Path: "/profile/:userId/fame", Summary: "How a profile's fame rating is computed", Tag: "profile", Response: FameExplanationResponse{}},
+	{Method: "GET", Path: "/profile/:userId/like-status", Summary: "Whether the current user likes a profile", Tag: "profile", Response: LikeStatusResponse{}},
+	{Method: "GET", Path: "/profile/:userId/viewers", Summary: "Users who viewed a profile", Tag: "profile", Query: pageQuery, Response: ProfileViewersResponse{}},
+	{Method: "GET", Path: "/profile/:userId/likers", Summary: "Users who liked a profile", Tag: "profile", Query: pageQuery, Response: ProfileLikersResponse{}},
+
+	{Method: "PUT", Path: "/profile/update", Summary: "Update profile fields", Tag: "profile", JSONBody: UpdateProfileRequest{}, Response: MessageResponse{}},
+	{Method: "PUT", Path: "/profile/email", Summary: "Change email address", Tag: "profile", RateLimited: true, JSONBody: UpdateEmailRequest{}, Response: MessageResponse{}},
+	{Method: "PUT", Path: "/profile/tags", Summary: "Replace the current user's tags", Tag: "tags", JSONBody: UpdateTagsRequest{}, Response: MessageResponse{}},
+
+	{Method: "POST", Path: "/chat/message", Summary: "Send a chat message to a match", Tag: "chat", RateLimited: true, JSONBody: SendMessageRequest{}, Response: SendMessageResponse{}},
+	{Method: "GET", Path: "/chat/history/:userId", Summary: "Messages exchanged with a match", Tag: "chat", Query: pageQuery, Response: ChatHistoryResponse{}},
+	{Method: "GET", Path: "/chat/conversations", Summary: "Matches the current user can chat with", Tag: "chat", Query: pageQuery, Response: ConversationsResponse{}},
+
+	{Method: "GET", Path: "/notifications", Summary: "Latest notifications", Tag: "notifications", Query: pageQuery, Response: NotificationsResponse{}, ResponseV2: NotificationsResponseV2{}},
+	{Method: "POST", Path: "/notifications/:id/read", Summary: "Mark a notification as read", Tag: "notifications", Response: MessageResponse{}},
+
+	{Method: "POST", Path: "/user/:userId/block", Summary: "Block a user", Tag: "moderation", RateLimited: true, Response: MessageResponse{}},
+	{Method: "DELETE", Path: "/user/:userId/block", Summary: "Unblock a user", Tag: "moderation", Response: MessageResponse{}},
+	{Method: "GET", Path: "/user/blocked", Summary: "Users blocked by the current user", Tag: "moderation", Query: pageQuery, Response: BlockedUsersResponse{}},
+	{Method: "POST", Path: "/user/:userId/report", Summary: "Report a user", Tag: "moderation", RateLimited: true, JSONBody: ReportUserRequest{}, Response: MessageResponse{}},
+
+	{Method: "POST", Path: "/profile/image", Summary: "Upload a profile image", Tag: "images", RateLimited: true, Multipart: UploadImageForm{}, Response: UploadImageResponse{}},
+	{Method: "DELETE", Path: "/profile/image/:imageId", Summary: "Delete a profile image", Tag: "images", Response: MessageResponse{}},
+	{Method: "GET", Path: "/user/:userId/images", Summary: "Images of a user", Tag: "images", Response: UserImagesResponse{}},
+	{Method: "POST", Path: "/profile/image/:imageId/set-profile", Summary: "Use an image as profile picture", Tag: "images", Response: MessageResponse{}},
+
+	{Method: "GET", Path: "/admin/tags/aliases", Summary: "Tag aliases and the tags they stand for (admins)", Tag: "admin", Response: TagAliasesResponse{}},
+	{Method: "POST", Path: "/admin/tags/merge", Summary: "Make a tag an alias of another, moving its users (admins)", Tag: "admin", JSONBody: MergeTagRequest{}, Response: MergeTagResponse{}},
+	{Method: "DELETE", Path: "/admin/tags/aliases/:alias", Summary: "Delete a tag alias (admins)", Tag: "admin", Response: MessageResponse{}},
+	{Method: "GET", Path: "/admin/experiments", Summary: "Ranking experiments, latest first (admins)", Tag: "admin", Response: ExperimentsResponse{}},
+	{Method: "POST", Path: "/admin/experiments", Summary: "Start a ranking experiment (admins)", Tag: "admin", JSONBody: StartExperimentRequest{}, Response: Experiment{}},
+	{Method: "POST", Path: "/admin/experiments/:experimentId/end", Summary: "End the running experiment (admins)", Tag: "admin", Response: MessageResponse{}},
+	{Method: "GET", Path: "/admin/experiments/:experimentId/report", Summary: "Impressions, outcomes and conversion per variant (admins)", Tag: "admin", Response: ExperimentReportResponse{}},
+
+	{Method: "GET", Path: "/openapi.json", Summary: "This document", Tag: "meta", Public: true},
+}
+
+var (
+	openAPIMutex sync.Mutex
+	openAPIDocs  = map[int]map[string]interface{}{}
+)
+
+// OpenAPIDocument returns the generated OpenAPI 3 document for an API version.
+func OpenAPIDocument(version int) map[string]interface{} {
+	openAPIMutex.Lock()
+	defer openAPIMutex.Unlock()
+
+	doc, ok := openAPIDocs[version]
+	if !ok {
+		doc = buildOpenAPIDocument(apiOperations, version)
+		openAPIDocs[version] = doc
+	}
+	return doc
+}
+
+func OpenAPIHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(200, OpenAPIDocument(apiVersion(c)))
+	}
+}
+
+// findAPIOperation looks up the documented operation for a gin route,
+// ignoring its version prefix.
+func findAPIOperation(method, fullPath string) *apiOperation {
+	path := apiRoutePath(fullPath)
+	for i := range apiOperations {
+		if apiOperations[i].Method == method && apiOperations[i].Path == path {
+			return &apiOperations[i]
+		}
+	}
+	return nil
+}
+
+// checkOpenAPICoverage logs every registered route that has no entry in
+// apiOperations, so the document cannot silently fall behind the router.
+func checkOpenAPICoverage(router *gin.Engine) {
+	for _, route := range router.Routes() {
+		if route.Method == "HEAD" || strings.HasPrefix(route.Path, "/uploads") {
+			continue
+		}
+		if findAPIOperation(route.Method, route.Path) == nil {
+			log.Printf("OpenAPI: route %s %s is not documented", route.Method, route.Path)
+		}
+	}
+}
+
+func buildOpenAPIDocument(operations []apiOperation, version int) map[string]interface{} {
+	schemas := map[string]interface{}{}
+	paths := map[string]interface{}{}
+
+	schemaRef(reflect.TypeOf(ErrorResponse{}), schemas, "json")
+
+	for _, op := range operations {
+		path := openAPIPath(op.Path)
+		item, ok := paths[path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[path] = item
+		}
+
+		operation := map[string]interface{}{
+			"summary":     op.Summary,
+			"operationId": operationID(op),
+			"tags":        []string{op.Tag},
+		}
+		if op.Public {
+			operation["security"] = []interface{}{}
+		}
+
+		params := []interface{}{}
+		for _, name := range pathParams(op.Path) {
+			params = append(params, map[string]interface{}{
+				"name":     name,
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "integer"},
+			})
+		}
+		for _, q := range op.Query {
+			param := map[string]interface{}{
+				"name":     q.Name,
+				"in":       "query",
+				"required": q.Required,
+				"schema":   map[string]interface{}{"type": q.Type},
+			}
+			if q.Description != "" {
+				param["description"] = q.Description
+			}
+			params = append(params, param)
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+
+		switch {
+		case op.JSONBody != nil:
+			operation["requestBody"] = requestBody("application/json", schemaRef(reflect.TypeOf(op.JSONBody), schemas, "json"))
+		case op.FormBody != nil:
+			operation["requestBody"] = requestBody("application/x-www-form-urlencoded", schemaRef(reflect.TypeOf(op.FormBody), schemas, "form"))
+		case op.Multipart != nil:
+			operation["requestBody"] = requestBody("multipart/form-data", schemaRef(reflect.TypeOf(op.Multipart), schemas, "form"))
+		}
+
+		responses := map[string]interface{}{
+			"default": jsonResponse("Error", map[string]interface{}{"$ref": "#/components/schemas/ErrorResponse"}),
+		}
+		response := op.Response
+		if version >= apiVersion2 && op.ResponseV2 != nil {
+			response = op.ResponseV2
+		}
+		if response != nil {
+			responses["200"] = jsonResponse("Success", schemaRef(reflect.TypeOf(response), schemas, "json"))
+		} else {
+			responses["200"] = map[string]interface{}{"description": "Success"}
+		}
+		if op.RateLimited {
+			tooMany := jsonResponse("Too many requests", map[string]interface{}{"$ref": "#/components/schemas/ErrorResponse"})
+			tooMany["headers"] = map[string]interface{}{
+				"Retry-After": map[string]interface{}{
+					"description": "Seconds until the next request is allowed",
+					"schema":      map[string]interface{}{"type": "integer"},
+				},
+			}
+			responses["429"] = tooMany
+		}
+		operation["responses"] = responses
+
+		item[strings.ToLower(op.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Matcha API",
+			"version": fmt.Sprintf("%d.0.0", version),
+		},
+		"servers": []interface{}{map[string]interface{}{"url": apiPrefix(version)}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"session": map[string]interface{}{
+					"type": "apiKey",
+					"in":   "cookie",
+					"name": "session_token",
+				},
+			},
+		},
+		"security": []interface{}{map[string]interface{}{"session": []string{}}},
+	}
+}
+
+func requestBody(contentType string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"required": true,
+		"content": map[string]interface{}{
+			contentType: map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+func jsonResponse(description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+func openAPIPath(ginPath string) string {
+	segments := strings.Split(ginPath, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func pathParams(ginPath string) []string {
+	var names []string
+	for _, segment := range strings.Split(ginPath, "/") {
+		if strings.HasPrefix(segment, ":") {
+			names = append(names, segment[1:])
+		}
+	}
+	return names
+}
+
+func operationID(op apiOperation) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(op.Method))
+	for _, segment := range strings.FieldsFunc(op.Path, func(r rune) bool { return r == '/' || r == '-' || r == '.' }) {
+		segment = strings.TrimPrefix(segment, ":")
+		b.WriteString(strings.ToUpper(segment[:1]) + segment[1:])
+	}
+	return b.String()
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaRef returns the schema for t, registering named structs under
+// components/schemas and referencing them. tagKey selects which struct tag
+// ("json" or "form") provides property names.
+func schemaRef(t reflect.Type, schemas map[string]interface{}, tagKey string) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct:
+		name := t.Name()
+		if name == "" {
+			return structSchema(t, schemas, tagKey)
+		}
+		if _, exists := schemas[name]; !exists {
+			schemas[name] = map[string]interface{}{}
+			schemas[name] = structSchema(t, schemas, tagKey)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaRef(t.Elem(), schemas, tagKey)}
+	case t.Kind() == reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaRef(t.Elem(), schemas, tagKey)}
+	case t.Kind() == reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case t.Kind() == reflect.String:
+		return map[string]interface{}{"type": "string"}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func structSchema(t reflect.Type, schemas map[string]interface{}, tagKey string) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get(tagKey)
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = field.Name
+		}
+		omitEmpty := strings.Contains(opts, "omitempty")
+
+		prop := schemaRef(field.Type, schemas, tagKey)
+		if format := field.Tag.Get("format"); format != "" {
+			prop = map[string]interface{}{"type": "string", "format": format}
+		}
+
+		// Pointers and slices encode as null unless omitempty drops them.
+		kind := field.Type.Kind()
+		if !omitEmpty && (kind == reflect.Ptr || kind == reflect.Slice || kind == reflect.Map) {
+			if _, isRef := prop["$ref"]; isRef {
+				prop = map[string]interface{}{"allOf": []interface{}{prop}, "nullable": true}
+			} else {
+				prop["nullable"] = true
+			}
+		}
+		properties[name] = prop
+
+		switch tagKey {
+		case "json":
+			if strings.Contains(field.Tag.Get("binding"), "required") || (!omitEmpty && kind != reflect.Ptr) {
+				required = append(required, name)
+			}
+		default:
+			if strings.Contains(field.Tag.Get("binding"), "required") {
+				required = append(required, name)
+			}
+		}
+	}
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"matcha/store"
+	"matcha/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOpenAPIRoutesDocumented checks that every route has an entry in
+// apiOperations and every entry a route.
+func TestOpenAPIRoutesDocumented(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	limiter := newMemoryRateLimitStore()
+	registerAPI(router.Group(apiPrefix(apiVersion1)), nil, limiter)
+	registerAPI(router.Group(apiPrefix(apiVersion2)), nil, limiter)
+
+	routed := map[string]int{}
+	for _, route := range router.Routes() {
+		op := findAPIOperation(route.Method, route.Path)
+		if assert.NotNil(t, op, "route %s %s is not documented", route.Method, route.Path) {
+			routed[op.Method+" "+op.Path]++
+		}
+	}
+	for _, op := range apiOperations {
+		assert.Equal(t, 2, routed[op.Method+" "+op.Path], "operation %s %s is not routed in both versions", op.Method, op.Path)
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	doc := OpenAPIDocument(apiVersion1)
+	logout := documentedOperation(doc, findAPIOperation("POST", "/logout"))
+
+	assert.Empty(t, responseProblems(doc, logout, 200, "application/json; charset=utf-8", []byte(`{"message":"Logged out"}`)))
+	assert.Equal(t, []string{"response.message: expected string"}, responseProblems(doc, logout, 200, "application/json", []byte(`{"message":1}`)))
+	assert.Equal(t, []string{"response.message: is required"}, responseProblems(doc, logout, 200, "application/json", []byte(`{}`)))
+	assert.Empty(t, responseProblems(doc, logout, 401, "application/json", []byte(`{"error":"Unauthorized"}`)))
+	assert.Equal(t, []string{"response.error: is required"}, responseProblems(doc, logout, 401, "application/json", []byte(`{"message":"Unauthorized"}`)))
+	assert.NotEmpty(t, responseProblems(doc, logout, 200, "text/plain", []byte("Logged out")))
+
+	preferences := documentedOperation(doc, findAPIOperation("PUT", "/me/preferences"))
+	assert.Contains(t, requestProblems(doc, preferences, []byte(`{"min_age":"18","required_tags":[],"dealbreakers":null}`)), "body.min_age: expected integer")
+	assert.Empty(t, requestProblems(doc, preferences, []byte(`{"min_age":18,"required_tags":[],"dealbreakers":[]}`)))
+}
+
+// TestOpenAPIContract calls every documented operation on a migrated and
+// seeded database and fails on any request or response that does not match
+// OpenAPIDocument. It needs a disposable PostgreSQL database: the public
+// schema of TEST_DB_STRING is dropped and recreated for each API version.
+func TestOpenAPIContract(t *testing.T) {
+	dsn := os.Getenv("TEST_DB_STRING")
+	if dsn == "" {
+		t.Skip("TEST_DB_STRING is not set")
+	}
+	gin.SetMode(gin.TestMode)
+	t.Setenv("GMAIL_PASS", "")
+
+	migrations := readMigrations(t)
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	// Uploads are written under the working directory.
+	t.Chdir(t.TempDir())
+
+	for _, version := range []int{apiVersion1, apiVersion2} {
+		t.Run(apiPrefix(version), func(t *testing.T) {
+			resetDatabase(t, db, migrations)
+			runContractScenario(t, db, version)
+		})
+	}
+}
+
+// readMigrations returns the Up section of every migration, in order.
+func readMigrations(t *testing.T) []string {
+	files, err := filepath.Glob(filepath.Join("..", "migrations", "*.sql"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+	sort.Strings(files)
+
+	var ups []string
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+		up, _, _ := strings.Cut(string(content), "-- +goose Down")
+		_, up, found := strings.Cut(up, "-- +goose Up")
+		require.True(t, found, "%s has no Up section", file)
+		ups = append(ups, up)
+	}
+	return ups
+}
+
+func resetDatabase(t *testing.T, db *sql.DB, migrations []string) {
+	_, err := db.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public;")
+	require.NoError(t, err)
+	for _, up := range migrations {
+		_, err := db.Exec(up)
+		require.NoError(t, err)
+	}
+	DetectSpatialIndex(db)
+}
+
+var startTestHub sync.Once
+
+type contractUsers struct {
+	alice, bob, carol int
+}
+
+// seedContractUsers creates three verified users with locations, tags and
+// profile pictures. Alice is an admin whom Bob already likes and Carol
+// already viewed; each user's session token is their username.
+func seedContractUsers(t *testing.T, db *sql.DB) contractUsers {
+	ctx := context.Background()
+	s := store.New(db)
+	hash, err := utils.HashPassword("Matcha2026seed")
+	require.NoError(t, err)
+
+	create := func(username, gender, interestedIn string, lat, lon float64, tags []string, picture bool) int {
+		id, err := s.CreateUser(ctx, store.NewUser{
+			Username:     username,
+			Email:        username + "@example.com",
+			PasswordHash: hash,
+			FirstName:    strings.ToUpper(username[:1]) + username[1:],
+			LastName:     "Martin",
+			Gender:       gender,
+			InterestedIn: []string{interestedIn},
+			Birthday:     time.Date(1995, time.April, 12, 0, 0, 0, 0, time.UTC),
+			Bio:          "Loves music and long walks",
+			Verified:     true,
+		})
+		require.NoError(t, err)
+		require.NoError(t, s.SetLocation(ctx, id, lat, lon))
+		require.NoError(t, s.AddUserTags(ctx, id, tags))
+		if picture {
+			require.NoError(t, s.AddImage(ctx, id, "/uploads/"+username+".png", true))
+		}
+		_, err = db.ExecContext(ctx, "UPDATE users SET session_token = $1 WHERE id = $2", username, id)
+		require.NoError(t, err)
+		return id
+	}
+
+	users := contractUsers{
+		alice: create("alice", "Woman", "Man", 48.8566, 2.3522, []string{"music", "travel"}, true),
+		bob:   create("bob", "Man", "Woman", 48.8600, 2.3400, []string{"music", "cooking"}, true),
+		carol: create("carol", "Man", "Woman", 45.7640, 4.8357, []string{"travel"}, false),
+	}
+	require.NoError(t, s.SetAdmin(ctx, users.alice, true))
+	require.NoError(t, s.AddLike(ctx, users.bob, users.alice, time.Now()))
+	require.NoError(t, s.RecordView(ctx, users.carol, users.alice, time.Now()))
+
+	_, err = s.RecomputeFameRatings(ctx)
+	require.NoError(t, err)
+	_, err = s.RecomputeRecommendations(ctx)
+	require.NoError(t, err)
+	_, err = computeDailyPicks(ctx, db)
+	require.NoError(t, err)
+	return users
+}
+
+func runContractScenario(t *testing.T, db *sql.DB, version int) {
+	startTestHub.Do(func() { StartHub(db) })
+	users := seedContractUsers(t, db)
+
+	router := gin.New()
+	registerAPI(router.Group(apiPrefix(version), apiMiddleware(version)...), db, newMemoryRateLimitStore())
+	api := &contractClient{
+		t:       t,
+		db:      db,
+		router:  router,
+		version: version,
+		doc:     OpenAPIDocument(version),
+		called:  map[*apiOperation]bool{},
+	}
+
+	// Public routes, and an account going through signup and password reset.
+	api.call("", "GET", "/openapi.json", nil)
+	api.call("", "GET", "/genders", nil)
+	api.call("", "POST", "/auth/register", formBody(url.Values{
+		"username":   {"erin"},
+		"password":   {"Matcha2026erin"},
+		"email":      {"erin@example.com"},
+		"last_name":  {"Martin"},
+		"first_name": {"Erin"},
+		"birthday":   {"1995-04-12"},
+	}))
+	api.call("", "GET", "/auth/verify?token="+api.column("verification_token", "erin"), nil)
+	api.call("", "POST", "/auth/request-reset", formBody(url.Values{"email": {"erin@example.com"}}))
+	api.call("", "POST", "/auth/reset-password", formBody(url.Values{
+		"token":    {api.column("reset_token", "erin")},
+		"password": {"Matcha2026reset"},
+	}))
+	login := api.call("", "POST", "/auth/login", formBody(url.Values{"username": {"erin"}, "password": {"Matcha2026reset"}}))
+	var erin string
+	for _, cookie := range login.Result().Cookies() {
+		if cookie.Name == "session_token" {
+			erin = cookie.Value
+		}
+	}
+	require.NotEmpty(t, erin, "login set no session cookie")
+	api.dialWebSocket(erin)
+
+	// Alice sets up her search, then browses.
+	api.call("alice", "GET", "/me", nil)
+	api.call("alice", "PUT", "/me/preferences", jsonBody(map[string]interface{}{
+		"min_age": 18, "max_age": 60, "max_distance_km": 500,
+		"required_tags": []string{}, "dealbreakers": []string{"age"},
+	}))
+	api.call("alice", "GET", "/me/preferences", nil)
+	var search SavedSearch
+	api.decode(api.call("alice", "POST", "/me/searches", jsonBody(map[string]interface{}{
+		"name": "Nearby", "max_distance_km": 50, "tags": []string{"music"}, "alerts": true,
+	})), &search)
+	api.call("alice", "GET", "/me/searches", nil)
+	api.call("alice", "PUT", "/me/searches/:searchId/alerts", jsonBody(map[string]interface{}{"alerts": false}), search.ID)
+	api.call("alice", "DELETE", "/me/searches/:searchId", nil, search.ID)
+
+	var experiment Experiment
+	api.decode(api.call("alice", "POST", "/admin/experiments", jsonBody(map[string]interface{}{
+		"name":        "distance-heavy",
+		"description": "Weigh distance twice as much",
+		"variants": []map[string]interface{}{
+			{"name": "control", "weight": 1},
+			{"name": "distance", "weight": 1, "distance_weight": 2},
+		},
+	})), &experiment)
+	api.call("alice", "GET", "/admin/experiments", nil)
+
+	api.call("alice", "GET", "/users", nil)
+	api.call("alice", "GET", "/suggestions", nil)
+	api.call("alice", "GET", "/suggestions/:userId/explain", nil, users.bob)
+	api.call("alice", "GET", "/deck", nil)
+	api.call("alice", "GET", "/picks", nil)
+	api.call("alice", "GET", "/search?q=music", nil)
+	api.call("alice", "GET", "/user/:userId", nil, users.bob)
+
+	api.call("alice", "GET", "/tags", nil)
+	api.call("alice", "POST", "/tags?tag=hiking", nil)
+	api.call("alice", "DELETE", "/tags?tag=hiking", nil)
+	api.call("alice", "GET", "/tags/search?q=mu", nil)
+	api.call("alice", "GET", "/tags/popular?radius=100", nil)
+
+	api.call("alice", "POST", "/location", jsonBody(map[string]interface{}{"latitude": 48.853, "longitude": 2.349, "accuracy": 50}))
+	api.call("alice", "GET", "/location/:userId", nil, users.alice)
+	api.call("alice", "GET", "/location/:userId", nil, users.bob)
+	api.call("alice", "GET", "/nearby?radius=50", nil)
+
+	// Interactions: Alice likes Bob back, passes on Carol and changes her mind.
+	api.call("alice", "POST", "/profile/:userId/view", nil, users.bob)
+	api.call("alice", "POST", "/profile/:userId/like", nil, users.bob)
+	api.call("alice", "POST", "/profile/:userId/pass", nil, users.carol)
+	api.call("alice", "DELETE", "/profile/passes/last", nil)
+	api.call("alice", "GET", "/profile/:userId/stats", nil, users.alice)
+	api.call("alice", "GET", "/profile/:userId/fame", nil, users.alice)
+	api.call("alice", "GET", "/profile/:userId/like-status", nil, users.bob)
+	api.call("alice", "GET", "/profile/:userId/viewers", nil, users.alice)
+	api.call("alice", "GET", "/profile/:userId/likers", nil, users.alice)
+
+	api.call("alice", "PUT", "/profile/update", jsonBody(map[string]interface{}{"bio": "Jazz and trains", "interested_in": []string{"Man", "Woman"}}))
+	api.call("alice", "PUT", "/profile/email", jsonBody(map[string]interface{}{"email": "alice.martin@example.com"}))
+	api.call("alice", "PUT", "/profile/tags", jsonBody(map[string]interface{}{"tags": []string{"music", "travel", "jazz"}}))
+
+	api.call("alice", "POST", "/chat/message", jsonBody(map[string]interface{}{"receiver_id": users.bob, "content": "Hi Bob!"}))
+	api.call("bob", "GET", "/chat/history/:userId", nil, users.alice)
+	api.call("bob", "GET", "/chat/conversations", nil)
+
+	api.call("bob", "GET", "/notifications", nil)
+	var notificationID int
+	require.NoError(t, db.QueryRow("SELECT id FROM notifications WHERE user_id = $1 ORDER BY id LIMIT 1", users.bob).Scan(&notificationID))
+	api.call("bob", "POST", "/notifications/:id/read", nil, notificationID)
+
+	api.call("alice", "POST", "/user/:userId/block", nil, users.carol)
+	api.call("alice", "GET", "/user/blocked", nil)
+	api.call("alice", "DELETE", "/user/:userId/block", nil, users.carol)
+	api.call("alice", "POST", "/user/:userId/report", jsonBody(map[string]interface{}{"reason": "Fake profile"}), users.carol)
+
+	var upload UploadImageResponse
+	api.decode(api.call("alice", "POST", "/profile/image", imageBody(t)), &upload)
+	var imageID int
+	require.NoError(t, db.QueryRow("SELECT id FROM user_images WHERE path = $1", upload.Path).Scan(&imageID))
+	api.call("alice", "GET", "/user/:userId/images", nil, users.alice)
+	api.call("alice", "POST", "/profile/image/:imageId/set-profile", nil, imageID)
+	api.call("alice", "DELETE", "/profile/image/:imageId", nil, imageID)
+
+	api.call("alice", "POST", "/admin/tags/merge", jsonBody(map[string]interface{}{"source": "travel", "target": "travelling"}))
+	api.call("alice", "GET", "/admin/tags/aliases", nil)
+	api.call("alice", "DELETE", "/admin/tags/aliases/:alias", nil, "travel")
+	api.call("alice", "GET", "/admin/experiments/:experimentId/report", nil, experiment.ID)
+	api.call("alice", "POST", "/admin/experiments/:experimentId/end", nil, experiment.ID)
+
+	api.call(erin, "POST", "/logout", nil)
+
+	for i := range apiOperations {
+		op := &apiOperations[i]
+		assert.True(t, api.called[op], "%s %s is not covered by the contract test", op.Method, op.Path)
+	}
+}
+
+// contractBody is a request body and its content type.
+type contractBody struct {
+	contentType string
+	data        []byte
+}
+
+func jsonBody(value interface{}) *contractBody {
+	data, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+	return &contractBody{contentType: "application/json", data: data}
+}
+
+func formBody(values url.Values) *contractBody {
+	return &contractBody{contentType: "application/x-www-form-urlencoded", data: []byte(values.Encode())}
+}
+
+// imageBody is a multipart upload of a one pixel PNG.
+func imageBody(t *testing.T) *contractBody {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	part, err := writer.CreateFormFile("image", "pixel.png")
+	require.NoError(t, err)
+	require.NoError(t, png.Encode(part, image.NewRGBA(image.Rect(0, 0, 1, 1))))
+	require.NoError(t, writer.Close())
+	return &contractBody{contentType: writer.FormDataContentType(), data: buf.Bytes()}
+}
+
+// contractClient calls the API of one version and checks each exchange
+// against its OpenAPI document.
+type contractClient struct {
+	t       *testing.T
+	db      *sql.DB
+	router  http.Handler
+	version int
+	doc     map[string]interface{}
+	called  map[*apiOperation]bool
+}
+
+// call sends a request to the operation documented as method and path, with
+// the path parameters replaced by params in order, as the user with the given
+// session token (none when empty). It fails the test unless both bodies match
+// the document and the handler answers 200.
+func (api *contractClient) call(session, method, path string, body *contractBody, params ...interface{}) *httptest.ResponseRecorder {
+	t := api.t
+	t.Helper()
+
+	route, query, _ := strings.Cut(path, "?")
+	op := findAPIOperation(method, route)
+	require.NotNil(t, op, "%s %s is not documented", method, route)
+	api.called[op] = true
+	operation := documentedOperation(api.doc, op)
+
+	segments := strings.Split(route, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			require.NotEmpty(t, params, "%s %s: missing path parameter %s", method, route, segment)
+			segments[i] = url.PathEscape(fmt.Sprint(params[0]))
+			params = params[1:]
+		}
+	}
+	target := apiPrefix(api.version) + strings.Join(segments, "/")
+	values, err := url.ParseQuery(query)
+	require.NoError(t, err)
+	for name := range values {
+		documented := false
+		for _, param := range op.Query {
+			documented = documented || param.Name == name
+		}
+		assert.True(t, documented, "%s %s: query parameter %s is not documented", method, route, name)
+	}
+	if query != "" {
+		target += "?" + query
+	}
+
+	var reader *bytes.Reader
+	if body != nil {
+		mediaType, _, _ := strings.Cut(body.contentType, ";")
+		content, _ := operation["requestBody"].(map[string]interface{})["content"].(map[string]interface{})
+		assert.Contains(t, content, mediaType, "%s %s: %s body is not documented", method, route, mediaType)
+		if mediaType == "application/json" {
+			assert.Empty(t, requestProblems(api.doc, operation, body.data), "%s %s: request", method, route)
+		}
+		reader = bytes.NewReader(body.data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req := httptest.NewRequest(method, target, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", body.contentType)
+	}
+	if session != "" {
+		req.AddCookie(&http.Cookie{Name: "session_token", Value: session})
+	}
+	rec := httptest.NewRecorder()
+	api.router.ServeHTTP(rec, req)
+
+	require.Equal(t, 200, rec.Code, "%s %s: %s", method, target, rec.Body.String())
+	assert.Empty(t, responseProblems(api.doc, operation, rec.Code, rec.Header().Get("Content-Type"), rec.Body.Bytes()), "%s %s: %s", method, target, rec.Body.String())
+	return rec
+}
+
+func (api *contractClient) decode(rec *httptest.ResponseRecorder, out interface{}) {
+	api.t.Helper()
+	require.NoError(api.t, json.Unmarshal(rec.Body.Bytes(), out))
+}
+
+// column reads a token column of a user, for the links sent by email.
+func (api *contractClient) column(name, username string) string {
+	api.t.Helper()
+	var value string
+	err := api.db.QueryRow("SELECT "+name+" FROM users WHERE username = $1", username).Scan(&value)
+	require.NoError(api.t, err)
+	return value
+}
+
+// dialWebSocket opens the realtime websocket, which needs a real connection
+// to upgrade.
+func (api *contractClient) dialWebSocket(session string) {
+	t := api.t
+	t.Helper()
+	api.called[findAPIOperation("GET", "/ws")] = true
+
+	server := httptest.NewServer(api.router)
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + apiPrefix(api.version) + "/ws?token=" + url.QueryEscape(session)
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 100
+)
+
+var errInvalidCursor = errors.New("Invalid cursor")
+
+// pageCursor is the keyset position after the last item of a page. Lists are
+// ordered by (Time, ID), (Rank, ID) or by ID alone, so rows inserted while a
+// client pages through never shift or duplicate later pages. Kind ties a
+// cursor to the list that issued it.
+//
+// Handlers fetch Limit+1 rows: reaching the extra row means another page
+// exists, and the cursor is built from the last row that was returned.
+//
+// Lists ranked in Go rather than by a key (suggestions) store the ranking as
+// a snapshot instead, and their cursor is the snapshot token and the offset
+// of the next page in it.
+type pageCursor struct {
+	Kind     string     `json:"k"`
+	Time     *time.Time `json:"t,omitempty"`
+	Rank     *float64   `json:"r,omitempty"`
+	ID       int        `json:"id"`
+	Snapshot string     `json:"s,omitempty"`
+	Offset   int        `json:"o,omitempty"`
+}
+
+type pageParams struct {
+	Limit  int
+	Cursor *pageCursor
+}
+
+// cursorTime returns the cursor timestamp, or nil for the first page.
+func (p pageParams) cursorTime() interface{} {
+	if p.Cursor == nil || p.Cursor.Time == nil {
+		return nil
+	}
+	return *p.Cursor.Time
+}
+
+// cursorRank returns the cursor rank, or nil for the first page.
+func (p pageParams) cursorRank() interface{} {
+	if p.Cursor == nil || p.Cursor.Rank == nil {
+		return nil
+	}
+	return *p.Cursor.Rank
+}
+
+// cursorID returns the cursor ID, or 0 for the first page.
+func (p pageParams) cursorID() int {
+	if p.Cursor == nil {
+		return 0
+	}
+	return p.Cursor.ID
+}
+
+// parsePageParams reads the limit and cursor query parameters. The limit is
+// clamped to maxPageLimit; a cursor issued by another list is rejected.
+func parsePageParams(c *gin.Context, kind string) (pageParams, error) {
+	params := pageParams{Limit: defaultPageLimit}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return params, errors.New("limit must be a positive integer")
+		}
+		if limit > maxPageLimit {
+			limit = maxPageLimit
+		}
+		params.Limit = limit
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(raw)
+		if err != nil {
+			return params, errInvalidCursor
+		}
+		var cursor pageCursor
+		if err := json.Unmarshal(decoded, &cursor); err != nil || cursor.Kind != kind {
+			return params, errInvalidCursor
+		}
+		params.Cursor = &cursor
+	}
+
+	return params, nil
+}
+
+func encodeCursor(cursor pageCursor) *string {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return nil
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return &encoded
+}
+
+// idCursor returns the cursor for a list ordered by ID.
+func idCursor(kind string, lastID int) *string {
+	return encodeCursor(pageCursor{Kind: kind, ID: lastID})
+}
+
+// rankCursor returns the cursor for a list ordered by (rank, ID).
+func rankCursor(kind string, lastRank float64, lastID int) *string {
+	return encodeCursor(pageCursor{Kind: kind, Rank: &lastRank, ID: lastID})
+}
+
+// timeCursor returns the cursor for a list ordered by (timestamp, ID).
+func timeCursor(kind string, lastTime time.Time, lastID int) *string {
+	return encodeCursor(pageCursor{Kind: kind, Time: &lastTime, ID: lastID})
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const (
+	// dailyPicksCount is the size of a batch of daily picks.
+	dailyPicksCount = 5
+	// minPickScore is the match score a profile needs to be picked.
+	minPickScore = 50.0
+	// dailyPicksTTL is how long a batch of picks stays up.
+	dailyPicksTTL = 24 * time.Hour
+	// pickRepeatWindow is how long a picked profile is not picked again for
+	// the same user. Older picks are deleted.
+	pickRepeatWindow = 7 * 24 * time.Hour
+	// pickActiveWindow is how recently users must have been seen to get
+	// picks.
+	pickActiveWindow = 7 * 24 * time.Hour
+	// dailyPicksBatch is how many users a job run claims at a time.
+	dailyPicksBatch = 100
+)
+
+// GetPicksHandler returns the current user's daily picks, best match first.
+// Picks banned or blocked since they were computed are left out. Users and
+// expires_at are empty until the daily picks job has run for the user.
+func GetPicksHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userIDVal, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+		userID := userIDVal.(int)
+
+		rows, err := db.QueryContext(ctx, `
+			SELECT pick_id, expires_at FROM daily_picks
+			WHERE user_id = $1 AND expires_at > NOW()
+			  AND created_at = (
+				SELECT MAX(created_at) FROM daily_picks WHERE user_id = $1 AND expires_at > NOW()
+			  )
+			ORDER BY position
+		`, userID)
+		if err != nil {
+			log.Printf("Error fetching daily picks: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		var ids []int
+		var expiresAt *time.Time
+		for rows.Next() {
+			var id int
+			var expiry time.Time
+			if err := rows.Scan(&id, &expiry); err != nil {
+				rows.Close()
+				log.Printf("Error scanning daily pick: %v", err)
+				c.JSON(500, gin.H{"error": "Database error"})
+				return
+			}
+			ids = append(ids, id)
+			expiresAt = &expiry
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			log.Printf("Error fetching daily picks: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		picks := []suggestion{}
+		if len(ids) > 0 {
+			viewer, err := loadSuggestionViewer(ctx, db, userID)
+			if err == nil {
+				picks, err = loadSnapshotPage(ctx, db, userID, ids, viewer.Lat, viewer.Lon)
+			}
+			if err == nil {
+				var profile scoreProfile
+				profile, err = newViewerScoreProfile(ctx, db, userID, viewer.Lat, viewer.Lon, viewer.Tags)
+				if err == nil {
+					err = scoreSuggestions(ctx, db, picks, matchScorer, profile)
+				}
+			}
+			if err != nil {
+				log.Printf("Error loading daily picks: %v", err)
+				c.JSON(500, gin.H{"error": "Database error"})
+				return
+			}
+		}
+
+		c.JSON(200, PicksResponse{Users: suggestionUsers(picks), ExpiresAt: expiresAt})
+	}
+}
+
+// pickProfiles returns the daily picks of userID: the best matches of their
+// deck scoring at least minPickScore, leaving out profiles picked for them
+// within pickRepeatWindow.
+func pickProfiles(ctx context.Context, db *sql.DB, userID int) ([]suggestion, error) {
+	viewer, err := loadSuggestionViewer(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+	preferences, err := loadDiscoveryPreferences(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+	var filters discoveryFilters
+	filters.applyPreferences(preferences)
+
+	conditions, args, err := suggestionConditions(userID, viewer, filters)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, pickRepeatWindow.Seconds())
+	conditions = append(conditions, notLikedCondition, sqlCondition{Name: "not_picked_recently", SQL: `NOT EXISTS (
+		SELECT 1 FROM daily_picks p
+		WHERE p.user_id = $1 AND p.pick_id = u.id
+		  AND p.created_at > NOW() - $` + strconv.Itoa(len(args)) + ` * INTERVAL '1 second'
+	)`})
+
+	candidates, err := rankByMatch(ctx, db, userID, viewer, conditions, args)
+	if err != nil {
+		return nil, err
+	}
+	picks := []suggestion{}
+	for _, candidate := range candidates {
+		if len(picks) == dailyPicksCount || *candidate.user.MatchScore < minPickScore {
+			break
+		}
+		picks = append(picks, candidate)
+	}
+	return picks, nil
+}
+
+// computeDailyPicks gives a new batch of picks to the active users whose
+// picks expired, and notifies those who got any. Users are claimed with SKIP
+// LOCKED, so servers sharing the database split them. It returns the number
+// of users processed.
+func computeDailyPicks(ctx context.Context, db *sql.DB) (int, error) {
+	_, err := db.ExecContext(ctx, "DELETE FROM daily_picks WHERE created_at < NOW() - $1 * INTERVAL '1 second'",
+		pickRepeatWindow.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for {
+		rows, err := db.QueryContext(ctx, `
+			UPDATE users SET picks_expire_at = NOW() + $1 * INTERVAL '1 second'
+			WHERE id IN (
+				SELECT id FROM users
+				WHERE verified AND banned_at IS NULL
+				  AND last_seen > NOW() - $2 * INTERVAL '1 second'
+				  AND (picks_expire_at IS NULL OR picks_expire_at <= NOW())
+				ORDER BY picks_expire_at NULLS FIRST, id
+				LIMIT $3
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, picks_expire_at
+		`, dailyPicksTTL.Seconds(), pickActiveWindow.Seconds(), dailyPicksBatch)
+		if err != nil {
+			return processed, err
+		}
+
+		type claimedUser struct {
+			id        int
+			expiresAt time.Time
+		}
+		var claimed []claimedUser
+		for rows.Next() {
+			var user claimedUser
+			if err := rows.Scan(&user.id, &user.expiresAt); err != nil {
+				rows.Close()
+				return processed, err
+			}
+			claimed = append(claimed, user)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return processed, err
+		}
+		if len(claimed) == 0 {
+			return processed, nil
+		}
+
+		for _, user := range claimed {
+			picks, err := pickProfiles(ctx, db, user.id)
+			if err == nil && len(picks) > 0 {
+				err = storeDailyPicks(ctx, db, user.id, picks, user.expiresAt)
+			}
+			if err != nil {
+				log.Printf("Error computing daily picks of user %d: %v", user.id, err)
+				continue
+			}
+			processed++
+			if len(picks) == 0 {
+				continue
+			}
+
+			message := "Your daily pick is ready"
+			if len(picks) > 1 {
+				message = fmt.Sprintf("Your %d daily picks are ready", len(picks))
+			}
+			CreateAndPushNotification(db, user.id, "daily_picks", picks[0].user.ID, message)
+		}
+	}
+}
+
+func storeDailyPicks(ctx context.Context, db *sql.DB, userID int, picks []suggestion, expiresAt time.Time) error {
+	ids := make([]int, len(picks))
+	scores := make([]float64, len(picks))
+	for i, pick := range picks {
+		ids[i] = pick.user.ID
+		scores[i] = *pick.user.MatchScore
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO daily_picks (user_id, pick_id, position, match_score, expires_at)
+		SELECT $1, p.pick_id, p.position, p.match_score, $4
+		FROM UNNEST($2::int[], $3::float8[]) WITH ORDINALITY AS p(pick_id, match_score, position)
+	`, userID, pq.Array(ids), pq.Array(scores), expiresAt)
+	return err
+}
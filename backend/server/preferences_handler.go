@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// Preferences that can be made dealbreakers.
+var dealbreakerFields = []string{"age", "distance", "fame", "tags"}
+
+// dealbreakerFilter is the SQL condition on users u (joined with
+// user_locations u_loc) hiding the profiles whose dealbreakers the viewer,
+// bound to $1, does not meet. A viewer without a birthday or location is not
+// hidden by the age or distance dealbreakers. Distances are measured to the
+// profile's published location, like the ones the viewer is shown.
+const dealbreakerFilter = `NOT EXISTS (
+	SELECT 1 FROM user_preferences p
+	JOIN users me ON me.id = $1
+	LEFT JOIN user_locations me_loc ON me_loc.user_id = me.id
+	WHERE p.user_id = u.id AND (
+		('age' = ANY(p.dealbreakers) AND me.birthday IS NOT NULL AND (
+			EXTRACT(YEAR FROM AGE(NOW(), me.birthday)) < COALESCE(p.min_age, 0) OR
+			EXTRACT(YEAR FROM AGE(NOW(), me.birthday)) > COALESCE(p.max_age, 200)))
+		OR ('fame' = ANY(p.dealbreakers) AND (
+			COALESCE(me.fame_rating, 0) < COALESCE(p.min_fame, 0) OR
+			COALESCE(me.fame_rating, 0) > COALESCE(p.max_fame, 100)))
+		OR ('distance' = ANY(p.dealbreakers) AND p.max_distance_km IS NOT NULL
+			AND me_loc.lat IS NOT NULL AND u_loc.lat IS NOT NULL
+			AND haversine_km(me_loc.lat, me_loc.lon, u_loc.public_lat, u_loc.public_lon) > p.max_distance_km)
+		OR ('tags' = ANY(p.dealbreakers) AND NOT p.required_tags <@ ARRAY(
+			SELECT t.name FROM user_tags ut JOIN tags t ON t.id = ut.tag_id WHERE ut.user_id = me.id))
+	)
+)`
+
+// loadDiscoveryPreferences returns the saved preferences of a user, empty
+// when none were saved.
+func loadDiscoveryPreferences(ctx context.Context, db *sql.DB, userID int) (DiscoveryPreferences, error) {
+	preferences := DiscoveryPreferences{RequiredTags: []string{}, Dealbreakers: []string{}}
+
+	var minAge, maxAge sql.NullInt64
+	var maxDistance, minFame, maxFame sql.NullFloat64
+	var requiredTags, dealbreakers pq.StringArray
+	err := db.QueryRowContext(ctx, `
+		SELECT min_age, max_age, max_distance_km, min_fame, max_fame, required_tags, dealbreakers
+		FROM user_preferences WHERE user_id = $1
+	`, userID).Scan(&minAge, &maxAge, &maxDistance, &minFame, &maxFame, &requiredTags, &dealbreakers)
+	if err == sql.ErrNoRows {
+		return preferences, nil
+	}
+	if err != nil {
+		return preferences, err
+	}
+
+	if minAge.Valid {
+		preferences.MinAge = intPtr(int(minAge.Int64))
+	}
+	if maxAge.Valid {
+		preferences.MaxAge = intPtr(int(maxAge.Int64))
+	}
+	if maxDistance.Valid {
+		preferences.MaxDistanceKm = float64Ptr(maxDistance.Float64)
+	}
+	if minFame.Valid {
+		preferences.MinFame = float64Ptr(minFame.Float64)
+	}
+	if maxFame.Valid {
+		preferences.MaxFame = float64Ptr(maxFame.Float64)
+	}
+	preferences.RequiredTags = stringsOrEmpty(requiredTags)
+	preferences.Dealbreakers = stringsOrEmpty(dealbreakers)
+	return preferences, nil
+}
+
+func GetPreferencesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userIDVal, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+		userID := userIDVal.(int)
+
+		preferences, err := loadDiscoveryPreferences(ctx, db, userID)
+		if err != nil {
+			log.Printf("Error fetching discovery preferences: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		c.JSON(200, preferences)
+	}
+}
+
+// UpdatePreferencesHandler replaces the current user's discovery
+// preferences. Omitted or null fields do not filter.
+func UpdatePreferencesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userIDVal, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+		userID := userIDVal.(int)
+
+		var request DiscoveryPreferences
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		preferences, err := validatePreferences(request)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		preferences.RequiredTags, err = canonicalTagNames(ctx, db, preferences.RequiredTags)
+		if err != nil {
+			log.Printf("Error resolving tag aliases: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		_, err = db.ExecContext(ctx, `
+			INSERT INTO user_preferences (user_id, min_age, max_age, max_distance_km, min_fame, max_fame, required_tags, dealbreakers, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, CURRENT_TIMESTAMP)
+			ON CONFLICT (user_id) DO UPDATE SET
+				min_age = EXCLUDED.min_age,
+				max_age = EXCLUDED.max_age,
+				max_distance_km = EXCLUDED.max_distance_km,
+				min_fame = EXCLUDED.min_fame,
+				max_fame = EXCLUDED.max_fame,
+				required_tags = EXCLUDED.required_tags,
+				dealbreakers = EXCLUDED.dealbreakers,
+				updated_at = EXCLUDED.updated_at
+		`, userID, preferences.MinAge, preferences.MaxAge, preferences.MaxDistanceKm,
+			preferences.MinFame, preferences.MaxFame,
+			pq.Array(preferences.RequiredTags), pq.Array(preferences.Dealbreakers))
+		if err != nil {
+			log.Printf("Error saving discovery preferences: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		c.JSON(200, preferences)
+	}
+}
+
+// validatePreferences checks the ranges and normalizes tags and
+// dealbreakers. Every dealbreaker must have its preference set.
+func validatePreferences(p DiscoveryPreferences) (DiscoveryPreferences, error) {
+	if p.MinAge != nil && (*p.MinAge < 18 || *p.MinAge > 120) {
+		return p, fmt.Errorf("min_age must be between 18 and 120")
+	}
+	if p.MaxAge != nil && (*p.MaxAge < 18 || *p.MaxAge > 120) {
+		return p, fmt.Errorf("max_age must be between 18 and 120")
+	}
+	if p.MinAge != nil && p.MaxAge != nil && *p.MinAge > *p.MaxAge {
+		return p, fmt.Errorf("min_age must not be greater than max_age")
+	}
+	if p.MaxDistanceKm != nil && *p.MaxDistanceKm <= 0 {
+		return p, fmt.Errorf("max_distance_km must be positive")
+	}
+	if p.MinFame != nil && (*p.MinFame < 0 || *p.MinFame > 100) {
+		return p, fmt.Errorf("min_fame must be between 0 and 100")
+	}
+	if p.MaxFame != nil && (*p.MaxFame < 0 || *p.MaxFame > 100) {
+		return p, fmt.Errorf("max_fame must be between 0 and 100")
+	}
+	if p.MinFame != nil && p.MaxFame != nil && *p.MinFame > *p.MaxFame {
+		return p, fmt.Errorf("min_fame must not be greater than max_fame")
+	}
+
+	tags := []string{}
+	seenTags := make(map[string]bool)
+	for _, raw := range p.RequiredTags {
+		tag, err := normalizeTagName(raw)
+		if err != nil {
+			return p, err
+		}
+		if !seenTags[tag] {
+			seenTags[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > maxRequiredTags {
+		return p, fmt.Errorf("Too many tags provided; maximum is %d", maxRequiredTags)
+	}
+	p.RequiredTags = tags
+
+	isSet := map[string]bool{
+		"age":      p.MinAge != nil || p.MaxAge != nil,
+		"distance": p.MaxDistanceKm != nil,
+		"fame":     p.MinFame != nil || p.MaxFame != nil,
+		"tags":     len(p.RequiredTags) > 0,
+	}
+	dealbreakers := []string{}
+	seenDealbreakers := make(map[string]bool)
+	for _, field := range p.Dealbreakers {
+		set, known := isSet[field]
+		if !known {
+			return p, fmt.Errorf("dealbreakers must be among %v", dealbreakerFields)
+		}
+		if !set {
+			return p, fmt.Errorf("dealbreaker %s has no preference set", field)
+		}
+		if !seenDealbreakers[field] {
+			seenDealbreakers[field] = true
+			dealbreakers = append(dealbreakers, field)
+		}
+	}
+	p.Dealbreakers = dealbreakers
+
+	return p, nil
+}
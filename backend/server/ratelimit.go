@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitPolicy is a token bucket: a client may burst up to Burst requests,
+// then gets one more request every Per.
+type rateLimitPolicy struct {
+	Name  string
+	Burst int
+	Per   time.Duration
+}
+
+func (p rateLimitPolicy) ratePerSecond() float64 {
+	return 1 / p.Per.Seconds()
+}
+
+// Policies for the routes that spam other users (notifications, messages,
+// reports) or create accounts and emails.
+var (
+	registerRateLimit      = rateLimitPolicy{Name: "register", Burst: 5, Per: 10 * time.Minute}
+	loginRateLimit         = rateLimitPolicy{Name: "login", Burst: 10, Per: time.Minute}
+	passwordResetRateLimit = rateLimitPolicy{Name: "password_reset", Burst: 3, Per: 10 * time.Minute}
+	chatMessageRateLimit   = rateLimitPolicy{Name: "chat_message", Burst: 20, Per: 3 * time.Second}
+	profileLikeRateLimit   = rateLimitPolicy{Name: "profile_like", Burst: 30, Per: 2 * time.Second}
+	reportUserRateLimit    = rateLimitPolicy{Name: "report_user", Burst: 5, Per: 10 * time.Minute}
+	imageUploadRateLimit   = rateLimitPolicy{Name: "image_upload", Burst: 10, Per: time.Minute}
+	profileViewRateLimit   = rateLimitPolicy{Name: "profile_view", Burst: 60, Per: time.Second}
+	blockUserRateLimit     = rateLimitPolicy{Name: "block_user", Burst: 20, Per: 30 * time.Second}
+	emailChangeRateLimit   = rateLimitPolicy{Name: "email_change", Burst: 3, Per: 10 * time.Minute}
+)
+
+// RateLimitStore takes one token from the bucket identified by key. When the
+// bucket is empty it reports how long until the next token is available.
+type RateLimitStore interface {
+	Take(ctx context.Context, key string, policy rateLimitPolicy) (allowed bool, retryAfter time.Duration, err error)
+}
+
+// NewRateLimitStore picks the store from RATE_LIMIT_STORE. The in-memory store
+// is per process; deployments running several instances behind a load
+// balancer should use "postgres" so they share buckets.
+func NewRateLimitStore(db *sql.DB) RateLimitStore {
+	switch strings.ToLower(os.Getenv("RATE_LIMIT_STORE")) {
+	case "", "memory":
+		return newMemoryRateLimitStore()
+	case "postgres":
+		return newPostgresRateLimitStore(db)
+	default:
+		log.Printf("Unknown RATE_LIMIT_STORE %q, using memory", os.Getenv("RATE_LIMIT_STORE"))
+		return newMemoryRateLimitStore()
+	}
+}
+
+// trustedProxies lists the reverse proxies whose X-Forwarded-For header
+// c.ClientIP() believes, from TRUSTED_PROXIES (comma separated IPs or CIDRs).
+// There are none by default, or any client could pick the IP its rate limits
+// are keyed on by sending the header.
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// RateLimitMiddleware applies policy per authenticated user, or per client IP
+// on public routes. It must run after AuthMiddleware to key by user. Store
+// errors let the request through: a broken limiter should not take the API
+// down with it.
+func RateLimitMiddleware(store RateLimitStore, policy rateLimitPolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := policy.Name + ":ip:" + c.ClientIP()
+		if userIDVal, exists := c.Get("userID"); exists {
+			key = fmt.Sprintf("%s:user:%d", policy.Name, userIDVal.(int))
+		}
+
+		allowed, retryAfter, err := store.Take(c.Request.Context(), key, policy)
+		if err != nil {
+			log.Printf("Rate limiter error for %s: %v", key, err)
+			c.Next()
+			return
+		}
+		if !allowed {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			if seconds < 1 {
+				seconds = 1
+			}
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			c.JSON(429, gin.H{"error": "Too many requests"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+type memoryRateLimitStore struct {
+	mutex     sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func newMemoryRateLimitStore() *memoryRateLimitStore {
+	return &memoryRateLimitStore{buckets: make(map[string]*tokenBucket), lastSweep: time.Now()}
+}
+
+const rateLimitSweepInterval = time.Minute
+
+func (s *memoryRateLimitStore) Take(_ context.Context, key string, policy rateLimitPolicy) (bool, time.Duration, error) {
+	now := time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if now.Sub(s.lastSweep) > rateLimitSweepInterval {
+		s.sweep(now)
+	}
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: float64(policy.Burst), updatedAt: now}
+		s.buckets[key] = bucket
+	}
+
+	elapsed := now.Sub(bucket.updatedAt).Seconds()
+	bucket.tokens = math.Min(float64(policy.Burst), bucket.tokens+elapsed*policy.ratePerSecond())
+	bucket.updatedAt = now
+
+	if bucket.tokens < 1 {
+		missing := (1 - bucket.tokens) / policy.ratePerSecond()
+		return false, time.Duration(missing * float64(time.Second)), nil
+	}
+	bucket.tokens--
+	return true, 0, nil
+}
+
+// sweep drops buckets idle for an hour. Every policy refills completely within
+// that time, so a dropped bucket would have been full anyway.
+func (s *memoryRateLimitStore) sweep(now time.Time) {
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.updatedAt) > time.Hour {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+type postgresRateLimitStore struct {
+	db *sql.DB
+}
+
+func newPostgresRateLimitStore(db *sql.DB) *postgresRateLimitStore {
+	store := &postgresRateLimitStore{db: db}
+	go store.cleanup()
+	return store
+}
+
+// Take refills and decrements the bucket in a single upsert. An empty bucket
+// is left untouched, so the upsert returns no row and the request is denied.
+func (s *postgresRateLimitStore) Take(ctx context.Context, key string, policy rateLimitPolicy) (bool, time.Duration, error) {
+	var tokens float64
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO rate_limit_buckets AS b (key, tokens, updated_at)
+		VALUES ($1, $2::float8 - 1, NOW())
+		ON CONFLICT (key) DO UPDATE
+		SET tokens = LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at) * $3::float8) - 1,
+		    updated_at = NOW()
+		WHERE LEAST($2::float8, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at) * $3::float8) >= 1
+		RETURNING tokens
+	`, key, policy.Burst, policy.ratePerSecond()).Scan(&tokens)
+	if err == nil {
+		return true, 0, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, 0, err
+	}
+
+	var available float64
+	err = s.db.QueryRowContext(ctx, `
+		SELECT LEAST($2::float8, tokens + EXTRACT(EPOCH FROM NOW() - updated_at) * $3::float8)
+		FROM rate_limit_buckets
+		WHERE key = $1
+	`, key, policy.Burst, policy.ratePerSecond()).Scan(&available)
+	if err != nil {
+		return false, 0, err
+	}
+	missing := (1 - available) / policy.ratePerSecond()
+	return false, time.Duration(missing * float64(time.Second)), nil
+}
+
+func (s *postgresRateLimitStore) cleanup() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx, cancel := backgroundQueryContext()
+		_, err := s.db.ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE updated_at < NOW() - INTERVAL '1 day'")
+		cancel()
+		if err != nil {
+			log.Printf("Error cleaning rate limit buckets: %v", err)
+		}
+	}
+}
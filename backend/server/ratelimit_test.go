@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRateLimitIgnoresForwardedFor checks that a client cannot get a fresh
+// bucket by changing X-Forwarded-For when no proxy is trusted.
+func TestRateLimitIgnoresForwardedFor(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", "")
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	require.NoError(t, router.SetTrustedProxies(trustedProxies()))
+	policy := rateLimitPolicy{Name: "test", Burst: 1, Per: time.Hour}
+	router.POST("/login", RateLimitMiddleware(newMemoryRateLimitStore(), policy), func(c *gin.Context) {
+		c.JSON(200, gin.H{"message": "ok"})
+	})
+
+	codes := []int{}
+	for _, forwardedFor := range []string{"203.0.113.1", "203.0.113.2"} {
+		req := httptest.NewRequest("POST", "/login", nil)
+		req.RemoteAddr = "198.51.100.7:41000"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		codes = append(codes, rec.Code)
+	}
+	assert.Equal(t, []int{200, 429}, codes)
+}
+
+func TestTrustedProxies(t *testing.T) {
+	t.Setenv("TRUSTED_PROXIES", " 10.0.0.0/8, ,172.18.0.2 ")
+	assert.Equal(t, []string{"10.0.0.0/8", "172.18.0.2"}, trustedProxies())
+
+	t.Setenv("TRUSTED_PROXIES", "")
+	assert.Nil(t, trustedProxies())
+}
@@ -0,0 +1,104 @@
+package main
+
+// Request payloads accepted by the HTTP handlers. JSON bodies are bound with
+// gin; the form types document the urlencoded fields read with c.PostForm.
+
+type RegisterForm struct {
+	Username  string `form:"username" binding:"required"`
+	Password  string `form:"password" binding:"required"`
+	Email     string `form:"email" binding:"required"`
+	LastName  string `form:"last_name" binding:"required"`
+	FirstName string `form:"first_name" binding:"required"`
+	Birthday  string `form:"birthday" binding:"required"`
+}
+
+type LoginForm struct {
+	Username string `form:"username" binding:"required"`
+	Password string `form:"password" binding:"required"`
+}
+
+type RequestPasswordResetForm struct {
+	Email string `form:"email" binding:"required"`
+}
+
+type ResetPasswordForm struct {
+	Token    string `form:"token" binding:"required"`
+	Password string `form:"password" binding:"required"`
+}
+
+type UploadImageForm struct {
+	Image string `form:"image" binding:"required" format:"binary"`
+}
+
+type UpdateLocationRequest struct {
+	Latitude  float64  `json:"latitude" binding:"required"`
+	Longitude float64  `json:"longitude" binding:"required"`
+	Accuracy  *float64 `json:"accuracy"`
+}
+
+type UpdateProfileRequest struct {
+	// Gender and InterestedIn are names from GET /genders.
+	Gender       *string   `json:"gender"`
+	InterestedIn *[]string `json:"interested_in"`
+	// Orientation is the legacy form of InterestedIn: likes men, likes
+	// women or likes men and women.
+	Orientation *string `json:"orientation"`
+	Bio         *string `json:"bio"`
+	FirstName   *string `json:"first_name"`
+	LastName    *string `json:"last_name"`
+	Birthday    *string `json:"birthday"`
+	// Searchable is false to stay out of GET /search. BioLanguage is the
+	// language the bio is searched in: simple (no stemming), english or
+	// french.
+	Searchable  *bool   `json:"searchable"`
+	BioLanguage *string `json:"bio_language"`
+}
+
+type UpdateEmailRequest struct {
+	Email string `json:"email"`
+}
+
+type UpdateTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// MergeTagRequest makes Source an alias of Target.
+type MergeTagRequest struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+type SendMessageRequest struct {
+	ReceiverID int    `json:"receiver_id" binding:"required"`
+	Content    string `json:"content" binding:"required"`
+}
+
+type ReportUserRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// SavedSearchRequest is a named suggestions filter. Omitted or null criteria
+// do not filter.
+type SavedSearchRequest struct {
+	Name          string   `json:"name"`
+	MinAge        *int     `json:"min_age"`
+	MaxAge        *int     `json:"max_age"`
+	MaxDistanceKm *float64 `json:"max_distance_km"`
+	MinFame       *float64 `json:"min_fame"`
+	MaxFame       *float64 `json:"max_fame"`
+	Tags          []string `json:"tags"`
+	// Alerts sends a notification when new profiles match the search.
+	Alerts bool `json:"alerts"`
+}
+
+type SavedSearchAlertsRequest struct {
+	Alerts *bool `json:"alerts"`
+}
+
+// StartExperimentRequest starts a ranking experiment. Viewers are split
+// between Variants in proportion to their weights.
+type StartExperimentRequest struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Variants    []ExperimentVariant `json:"variants"`
+}
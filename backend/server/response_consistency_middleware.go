@@ -10,8 +10,10 @@ type consistencyResponseWriter struct {
 	gin.ResponseWriter
 }
 
+// WriteHeader maps server errors to 400, except 503 which tells clients the
+// request can be retried.
 func (w *consistencyResponseWriter) WriteHeader(code int) {
-	if code >= 500 && code <= 599 {
+	if code >= 500 && code <= 599 && code != http.StatusServiceUnavailable {
 		code = http.StatusBadRequest
 	}
 	w.ResponseWriter.WriteHeader(code)
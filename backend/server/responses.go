@@ -0,0 +1,483 @@
+package main
+
+import "time"
+
+// Response payloads returned by the HTTP handlers. These types are the single
+// source of truth for the OpenAPI document served at /openapi.json, so any
+// change to a JSON shape should happen here rather than in an ad-hoc gin.H.
+
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Details string `json:"details,omitempty"`
+	// Code is a stable machine-readable reason, set for errors clients are
+	// expected to handle specifically (e.g. "db_timeout").
+	Code string `json:"code,omitempty"`
+}
+
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+type TagItem struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type TagsResponse struct {
+	Tags []TagItem `json:"tags"`
+}
+
+// TagCount is a tag with the number of users who have it.
+type TagCount struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Users int    `json:"users"`
+}
+
+type TagCountsResponse struct {
+	Tags []TagCount `json:"tags"`
+}
+
+// TagAlias is an alternative name of a tag: adding or filtering on Alias
+// means Tag.
+type TagAlias struct {
+	Alias string `json:"alias"`
+	Tag   string `json:"tag"`
+}
+
+type TagAliasesResponse struct {
+	Aliases []TagAlias `json:"aliases"`
+}
+
+// MergeTagResponse reports a merge. Target is the tag Source now resolves
+// to, and MovedUsers counts the users who got it from Source.
+type MergeTagResponse struct {
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	MovedUsers int    `json:"moved_users"`
+}
+
+// Experiment is a ranking experiment. It scores suggestions until EndedAt is
+// set.
+type Experiment struct {
+	ID          int                 `json:"id"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	StartedAt   time.Time           `json:"started_at"`
+	EndedAt     *time.Time          `json:"ended_at"`
+	Variants    []ExperimentVariant `json:"variants"`
+}
+
+// ExperimentVariant gets Weight shares of the viewers. Null scoring weights
+// keep the server's MATCH_WEIGHT_* values.
+type ExperimentVariant struct {
+	Name                string   `json:"name"`
+	Weight              int      `json:"weight"`
+	DistanceWeight      *float64 `json:"distance_weight"`
+	TagsWeight          *float64 `json:"tags_weight"`
+	FameWeight          *float64 `json:"fame_weight"`
+	CollaborativeWeight *float64 `json:"collaborative_weight"`
+}
+
+type ExperimentsResponse struct {
+	Experiments []Experiment `json:"experiments"`
+}
+
+// VariantReport counts the profiles suggested to a variant's viewers and
+// what they did with them. Rates are per impression, 0 without impressions.
+type VariantReport struct {
+	Variant     string  `json:"variant"`
+	Weight      int     `json:"weight"`
+	Viewers     int     `json:"viewers"`
+	Impressions int     `json:"impressions"`
+	Likes       int     `json:"likes"`
+	Passes      int     `json:"passes"`
+	Matches     int     `json:"matches"`
+	Messages    int     `json:"messages"`
+	LikeRate    float64 `json:"like_rate"`
+	PassRate    float64 `json:"pass_rate"`
+	MatchRate   float64 `json:"match_rate"`
+	MessageRate float64 `json:"message_rate"`
+}
+
+type ExperimentReportResponse struct {
+	Experiment Experiment      `json:"experiment"`
+	Variants   []VariantReport `json:"variants"`
+}
+
+type LocationPayload struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Accuracy  *float64 `json:"accuracy"`
+}
+
+type UpdateLocationResponse struct {
+	Message  string          `json:"message"`
+	Location LocationPayload `json:"location"`
+}
+
+// UserLocation is exact for its owner. Other users get the fuzzed position
+// and null Accuracy, Source and UpdatedAt.
+type UserLocation struct {
+	Latitude  float64  `json:"latitude"`
+	Longitude float64  `json:"longitude"`
+	Accuracy  *float64 `json:"accuracy"`
+	Source    *string  `json:"source"`
+	UpdatedAt *string  `json:"updated_at"`
+}
+
+type UserLocationResponse struct {
+	Location UserLocation `json:"location"`
+}
+
+type NearbyUser struct {
+	UserID     int      `json:"user_id"`
+	AvatarURL  *string  `json:"avatar_url"`
+	Bio        *string  `json:"bio"`
+	Latitude   float64  `json:"latitude"`
+	Longitude  float64  `json:"longitude"`
+	Accuracy   *float64 `json:"accuracy"`
+	DistanceKm float64  `json:"distance_km"`
+}
+
+type Coordinates struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type NearbyUsersResponse struct {
+	NearbyUsers  []NearbyUser `json:"nearby_users"`
+	Count        int          `json:"count"`
+	RadiusKm     float64      `json:"radius_km"`
+	YourLocation Coordinates  `json:"your_location"`
+}
+
+type LikeToggleResponse struct {
+	Message string `json:"message"`
+	Liked   bool   `json:"liked"`
+}
+
+type LikeStatusResponse struct {
+	Liked bool `json:"liked"`
+}
+
+// DiscoveryPreferences are the default filters of suggestions and the deck,
+// read and written by /me/preferences. Null fields and empty lists do not
+// filter. Dealbreakers (age, distance, fame, tags) also hide the user from
+// people who do not meet those preferences.
+type DiscoveryPreferences struct {
+	MinAge        *int     `json:"min_age"`
+	MaxAge        *int     `json:"max_age"`
+	MaxDistanceKm *float64 `json:"max_distance_km"`
+	MinFame       *float64 `json:"min_fame"`
+	MaxFame       *float64 `json:"max_fame"`
+	RequiredTags  []string `json:"required_tags"`
+	Dealbreakers  []string `json:"dealbreakers"`
+}
+
+type UndoPassResponse struct {
+	Message string `json:"message"`
+	UserID  int    `json:"user_id"`
+}
+
+// SuggestionExplanationResponse explains why a profile is, or is not,
+// suggested to the current user and how its match score adds up.
+type SuggestionExplanationResponse struct {
+	UserID int `json:"user_id"`
+	// Suggested is true when the profile meets every suggestion condition.
+	Suggested      bool                      `json:"suggested"`
+	MatchScore     float64                   `json:"match_score"`
+	ScoreBreakdown map[string]ScoreComponent `json:"score_breakdown"`
+	// DistanceKm is null when either profile has no location.
+	DistanceKm       *float64 `json:"distance_km"`
+	SharedTags       []string `json:"shared_tags"`
+	FameRating       float64  `json:"fame_rating"`
+	FameContribution float64  `json:"fame_contribution"`
+	// Preferences are the filters in effect, from the query string or the
+	// saved preferences, and whether the profile meets each one.
+	Preferences []PreferenceMatch   `json:"preferences"`
+	Penalties   []SuggestionPenalty `json:"penalties"`
+}
+
+type PreferenceMatch struct {
+	Filter  string `json:"filter"`
+	Matches bool   `json:"matches"`
+}
+
+// SuggestionPenalty is something that lowers a profile's ranking: "excluded"
+// when it fails a suggestion condition (Subject names it), "missing_data"
+// when a score feature had no data to work with.
+type SuggestionPenalty struct {
+	Kind    string `json:"kind"`
+	Subject string `json:"subject"`
+}
+
+type SavedSearch struct {
+	ID            int       `json:"id"`
+	Name          string    `json:"name"`
+	MinAge        *int      `json:"min_age"`
+	MaxAge        *int      `json:"max_age"`
+	MaxDistanceKm *float64  `json:"max_distance_km"`
+	MinFame       *float64  `json:"min_fame"`
+	MaxFame       *float64  `json:"max_fame"`
+	Tags          []string  `json:"tags"`
+	Alerts        bool      `json:"alerts"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type SavedSearchesResponse struct {
+	Searches []SavedSearch `json:"searches"`
+}
+
+type GendersResponse struct {
+	Genders []string `json:"genders"`
+}
+
+// DeckResponse is the next batch of unseen profiles. Remaining counts the
+// candidates left after this batch.
+type DeckResponse struct {
+	Users     []UserResponse `json:"users"`
+	Remaining int            `json:"remaining"`
+}
+
+// PicksResponse is the current batch of daily picks. ExpiresAt is when the
+// next batch replaces it, null when there is no current batch.
+type PicksResponse struct {
+	Users     []UserResponse `json:"users"`
+	ExpiresAt *time.Time     `json:"expires_at"`
+}
+
+type ProfileStatsResponse struct {
+	Views      int     `json:"views"`
+	Likes      int     `json:"likes"`
+	FameRating float64 `json:"fame_rating"`
+}
+
+// FameExplanationResponse documents how a fame rating is computed and the
+// components of one user's latest rating.
+type FameExplanationResponse struct {
+	Formula    string         `json:"formula"`
+	Parameters FameParameters `json:"parameters"`
+	// Score is null until the fame job has rated the user.
+	Score   *FameComponents    `json:"score"`
+	History []FameHistoryEntry `json:"history"`
+}
+
+type FameParameters struct {
+	HalfLifeDays float64 `json:"half_life_days"`
+	PriorViews   float64 `json:"prior_views"`
+	PriorRate    float64 `json:"prior_rate"`
+	Damping      float64 `json:"damping"`
+}
+
+type FameComponents struct {
+	Rating        float64   `json:"rating"`
+	Desirability  float64   `json:"desirability"`
+	WeightedLikes float64   `json:"weighted_likes"`
+	DecayedViews  float64   `json:"decayed_views"`
+	Likes         int       `json:"likes"`
+	Views         int       `json:"views"`
+	ComputedAt    time.Time `json:"computed_at"`
+}
+
+type FameHistoryEntry struct {
+	Rating     float64   `json:"rating"`
+	ComputedAt time.Time `json:"computed_at"`
+}
+
+// ProfileActivityUser is a user who viewed or liked the current profile.
+type ProfileActivityUser struct {
+	ID        int    `json:"id"`
+	Username  string `json:"username"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Gender    string `json:"gender"`
+	// Orientation is the legacy form of InterestedIn, empty when no legacy
+	// orientation describes it.
+	Orientation  string    `json:"orientation"`
+	InterestedIn []string  `json:"interested_in"`
+	Birthday     time.Time `json:"birthday"`
+	Bio          string    `json:"bio"`
+	FameRating   float64   `json:"fame_rating"`
+	Latitude     float64   `json:"latitude"`
+	Longitude    float64   `json:"longitude"`
+	Tags         []string  `json:"tags"`
+	Interests    []string  `json:"interests"`
+	Images       []string  `json:"images"`
+}
+
+type ProfileViewersResponse struct {
+	Viewers    []ProfileActivityUser `json:"viewers"`
+	NextCursor *string               `json:"next_cursor"`
+}
+
+type ProfileLikersResponse struct {
+	Likers     []ProfileActivityUser `json:"likers"`
+	NextCursor *string               `json:"next_cursor"`
+}
+
+type LatLon struct {
+	Lat    float64 `json:"lat"`
+	Lon    float64 `json:"lon"`
+	Source string  `json:"source"`
+}
+
+// UserResponse is the public profile shape shared by /me, /users, /user/:id
+// and /suggestions. Fields that only some endpoints compute are optional.
+type UserResponse struct {
+	ID        int     `json:"id"`
+	Username  string  `json:"username"`
+	Email     string  `json:"email"`
+	FirstName string  `json:"first_name"`
+	LastName  string  `json:"last_name"`
+	IsOnline  *bool   `json:"is_online,omitempty"`
+	LastSeen  *string `json:"last_seen,omitempty"`
+	Gender    *string `json:"gender,omitempty"`
+	// Orientation is the legacy form of InterestedIn, set when InterestedIn
+	// is men, women or both.
+	Orientation  *string  `json:"orientation,omitempty"`
+	InterestedIn []string `json:"interested_in,omitempty"`
+	Birthday     *string  `json:"birthday,omitempty"`
+	Bio          *string  `json:"bio,omitempty"`
+	AvatarURL    *string  `json:"avatar_url,omitempty"`
+	FameRating   float64  `json:"fame_rating"`
+	Tags         []string `json:"tags"`
+	Location     *LatLon  `json:"location,omitempty"`
+	Latitude     *float64 `json:"latitude,omitempty"`
+	Longitude    *float64 `json:"longitude,omitempty"`
+	DistanceKm   *float64 `json:"distance_km,omitempty"`
+	MatchScore   *float64 `json:"match_score,omitempty"`
+	// ScoreBreakdown explains MatchScore feature by feature.
+	ScoreBreakdown map[string]ScoreComponent `json:"score_breakdown,omitempty"`
+	CommonTags     *int                      `json:"common_tags,omitempty"`
+	Images         []string                  `json:"images,omitempty"`
+	// Searchable and BioLanguage are the search settings, only in /me.
+	Searchable  *bool   `json:"searchable,omitempty"`
+	BioLanguage *string `json:"bio_language,omitempty"`
+}
+
+// Paginated lists carry the cursor of the next page in next_cursor, which is
+// null on the last page.
+type UsersResponse struct {
+	Users      []UserResponse `json:"users"`
+	NextCursor *string        `json:"next_cursor"`
+}
+
+// SearchResult is a profile matching a search. Snippet is an HTML excerpt of
+// the bio with the matches in <mark> tags, null without a bio.
+type SearchResult struct {
+	ID        int      `json:"id"`
+	Username  string   `json:"username"`
+	FirstName string   `json:"first_name"`
+	AvatarURL *string  `json:"avatar_url"`
+	Tags      []string `json:"tags"`
+	Snippet   *string  `json:"snippet"`
+	Rank      float64  `json:"rank"`
+}
+
+type SearchResponse struct {
+	Results    []SearchResult `json:"results"`
+	NextCursor *string        `json:"next_cursor"`
+}
+
+type ChatMessage struct {
+	ID         int     `json:"id"`
+	SenderID   int     `json:"sender_id"`
+	ReceiverID int     `json:"receiver_id"`
+	Content    string  `json:"content"`
+	CreatedAt  string  `json:"created_at"`
+	ReadAt     *string `json:"read_at,omitempty"`
+}
+
+type SendMessageResponse struct {
+	Message string `json:"message"`
+	ID      int    `json:"id"`
+}
+
+type ChatHistoryResponse struct {
+	Messages   []ChatMessage `json:"messages"`
+	NextCursor *string       `json:"next_cursor"`
+}
+
+// UserSummary is the compact user shape used in conversation and block lists.
+type UserSummary struct {
+	ID        int     `json:"id"`
+	Username  string  `json:"username"`
+	FirstName string  `json:"first_name"`
+	LastName  string  `json:"last_name"`
+	AvatarURL *string `json:"avatar_url,omitempty"`
+}
+
+type ConversationsResponse struct {
+	Conversations []UserSummary `json:"conversations"`
+	NextCursor    *string       `json:"next_cursor"`
+}
+
+type BlockedUsersResponse struct {
+	BlockedUsers []UserSummary `json:"blocked_users"`
+	NextCursor   *string       `json:"next_cursor"`
+}
+
+type Notification struct {
+	ID        int     `json:"id"`
+	Type      string  `json:"type"`
+	SourceID  *int    `json:"source_id"`
+	Message   *string `json:"message"`
+	IsRead    bool    `json:"is_read"`
+	CreatedAt string  `json:"created_at"`
+}
+
+type NotificationsResponse struct {
+	Notifications []Notification `json:"notifications"`
+	NextCursor    *string        `json:"next_cursor"`
+}
+
+// NotificationV2 is a notification as /v2 lists it, with the same keys as
+// the /v2 websocket push: the notification type is notif_type.
+type NotificationV2 struct {
+	ID        int     `json:"id"`
+	NotifType string  `json:"notif_type"`
+	SourceID  *int    `json:"source_id"`
+	Message   *string `json:"message"`
+	IsRead    bool    `json:"is_read"`
+	CreatedAt string  `json:"created_at"`
+}
+
+type NotificationsResponseV2 struct {
+	Notifications []NotificationV2 `json:"notifications"`
+	NextCursor    *string          `json:"next_cursor"`
+}
+
+type UploadImageResponse struct {
+	Message          string `json:"message"`
+	Path             string `json:"path"`
+	IsProfilePicture bool   `json:"is_profile_picture"`
+}
+
+type UserImage struct {
+	ID               int    `json:"id"`
+	Path             string `json:"path"`
+	IsProfilePicture bool   `json:"is_profile_picture"`
+	CreatedAt        string `json:"created_at"`
+}
+
+type UserImagesResponse struct {
+	Images []UserImage `json:"images"`
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
+
+func stringPtr(v string) *string {
+	return &v
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+func intPtr(v int) *int {
+	return &v
+}
@@ -7,17 +7,50 @@ import (
 )
 
 func RegisterRoutes(router *gin.Engine, db *sql.DB) {
+	DetectSpatialIndex(db)
+	OpenGeoIP()
 	StartHub(db)
+	StartRecommendationJob(db)
+	StartFameJob(db)
+	StartSavedSearchJob(db)
+	StartTagGCJob(db)
+	StartDailyPicksJob(db)
 
+	// One store for every version so /v1, /v2 and the aliases share buckets.
+	limiter := NewRateLimitStore(db)
+
+	registerAPI(router.Group(apiPrefix(apiVersion1), apiMiddleware(apiVersion1)...), db, limiter)
+	registerAPI(router.Group(apiPrefix(apiVersion2), apiMiddleware(apiVersion2)...), db, limiter)
+
+	// Unversioned aliases for clients that predate /v1.
+	legacy := append([]gin.HandlerFunc{DeprecatedAliasMiddleware()}, apiMiddleware(apiVersion1)...)
+	registerAPI(router.Group("/", legacy...), db, limiter)
+
+	checkOpenAPICoverage(router)
+}
+
+func apiMiddleware(version int) []gin.HandlerFunc {
+	handlers := []gin.HandlerFunc{APIVersionMiddleware(version), QueryTimeoutMiddleware()}
+	if OpenAPIValidationEnabled() {
+		handlers = append(handlers, OpenAPIValidationMiddleware())
+	}
+	return handlers
+}
+
+// registerAPI mounts every route on the given version group. Handlers that
+// change shape between versions branch on apiVersion(c).
+func registerAPI(router *gin.RouterGroup, db *sql.DB, limiter RateLimitStore) {
+	router.GET("/openapi.json", OpenAPIHandler())
 	router.GET("/ws", WebSocketHandler(db))
+	router.GET("/genders", ListGendersHandler(db))
 
 	auth := router.Group("/auth")
 	{
-		auth.POST("/register", RegisterHandler(db))
-		auth.POST("/login", LoginHandler(db))
+		auth.POST("/register", RateLimitMiddleware(limiter, registerRateLimit), RegisterHandler(db))
+		auth.POST("/login", RateLimitMiddleware(limiter, loginRateLimit), LoginHandler(db))
 		auth.GET("/verify", VerifyHandler(db))
 
-		auth.POST("/request-reset", RequestPasswordResetHandler(db))
+		auth.POST("/request-reset", RateLimitMiddleware(limiter, passwordResetRateLimit), RequestPasswordResetHandler(db))
 		auth.POST("/reset-password", ResetPasswordHandler(db))
 	}
 	protected := router.Group("/")
@@ -26,42 +59,71 @@ func RegisterRoutes(router *gin.Engine, db *sql.DB) {
 		protected.POST("/logout", LogoutHandler(db))
 
 		protected.GET("/me", GetCurrentUserHandler(db))
+		protected.GET("/me/preferences", GetPreferencesHandler(db))
+		protected.PUT("/me/preferences", UpdatePreferencesHandler(db))
+		protected.GET("/me/searches", ListSavedSearchesHandler(db))
+		protected.POST("/me/searches", CreateSavedSearchHandler(db))
+		protected.DELETE("/me/searches/:searchId", DeleteSavedSearchHandler(db))
+		protected.PUT("/me/searches/:searchId/alerts", SetSavedSearchAlertsHandler(db))
 		protected.GET("/users", GetAllUsersHandler(db))
 		protected.GET("/suggestions", GetSuggestionsHandler(db))
+		protected.GET("/suggestions/:userId/explain", ExplainSuggestionHandler(db))
+		protected.GET("/deck", GetDeckHandler(db))
+		protected.GET("/picks", GetPicksHandler(db))
+		protected.GET("/search", SearchProfilesHandler(db))
 		protected.GET("/user/:userId", GetUserByIdHandler(db))
 
 		protected.GET("/tags", GetUserTagsHandler(db))
 		protected.POST("/tags", PostTagHandler(db))
 		protected.DELETE("/tags", DeleteTagHandler(db))
+		protected.GET("/tags/search", SearchTagsHandler(db))
+		protected.GET("/tags/popular", PopularTagsHandler(db))
 
 		protected.POST("/location", UpdateLocationHandler(db))
 		protected.GET("/location/:userId", GetUserLocationHandler(db))
 		protected.GET("/nearby", GetNearbyUsersHandler(db))
-		protected.POST("/profile/:userId/view", RecordProfileViewHandler(db))
-		protected.POST("/profile/:userId/like", ToggleProfileLikeHandler(db))
+		protected.POST("/profile/:userId/view", RateLimitMiddleware(limiter, profileViewRateLimit), RecordProfileViewHandler(db))
+		protected.POST("/profile/:userId/like", RateLimitMiddleware(limiter, profileLikeRateLimit), ToggleProfileLikeHandler(db))
+		// Likes and passes are the two swipes of the deck and share one budget.
+		protected.POST("/profile/:userId/pass", RateLimitMiddleware(limiter, profileLikeRateLimit), PassProfileHandler(db))
+		protected.DELETE("/profile/passes/last", UndoLastPassHandler(db))
 		protected.GET("/profile/:userId/stats", GetProfileStatsHandler(db))
+		protected.GET("/profile/:userId/fame", ExplainFameHandler(db))
 		protected.GET("/profile/:userId/like-status", CheckLikeStatusHandler(db))
 		protected.GET("/profile/:userId/viewers", GetProfileViewersHandler(db))
 		protected.GET("/profile/:userId/likers", GetProfileLikersHandler(db))
 
 		protected.PUT("/profile/update", UpdateProfileHandler(db))
-		protected.PUT("/profile/email", UpdateEmailHandler(db))
+		protected.PUT("/profile/email", RateLimitMiddleware(limiter, emailChangeRateLimit), UpdateEmailHandler(db))
 		protected.PUT("/profile/tags", UpdateTagsHandler(db))
-		protected.POST("/chat/message", SendMessageHandler(db))
+		protected.POST("/chat/message", RateLimitMiddleware(limiter, chatMessageRateLimit), SendMessageHandler(db))
 		protected.GET("/chat/history/:userId", GetChatHistoryHandler(db))
 		protected.GET("/chat/conversations", GetConversationsHandler(db))
 
 		protected.GET("/notifications", GetNotificationsHandler(db))
 		protected.POST("/notifications/:id/read", MarkNotificationReadHandler(db))
 
-		protected.POST("/user/:userId/block", BlockUserHandler(db))
+		protected.POST("/user/:userId/block", RateLimitMiddleware(limiter, blockUserRateLimit), BlockUserHandler(db))
 		protected.DELETE("/user/:userId/block", UnblockUserHandler(db))
 		protected.GET("/user/blocked", GetBlockedUsersHandler(db))
-		protected.POST("/user/:userId/report", ReportUserHandler(db))
+		protected.POST("/user/:userId/report", RateLimitMiddleware(limiter, reportUserRateLimit), ReportUserHandler(db))
 
-		protected.POST("/profile/image", UploadImageHandler(db))
+		protected.POST("/profile/image", RateLimitMiddleware(limiter, imageUploadRateLimit), UploadImageHandler(db))
 		protected.DELETE("/profile/image/:imageId", DeleteImageHandler(db))
 		protected.GET("/user/:userId/images", GetUserImagesHandler(db))
 		protected.POST("/profile/image/:imageId/set-profile", SetProfilePictureHandler(db))
 	}
+
+	admin := protected.Group("/admin")
+	admin.Use(AdminMiddleware(db))
+	{
+		admin.GET("/tags/aliases", ListTagAliasesHandler(db))
+		admin.POST("/tags/merge", MergeTagHandler(db))
+		admin.DELETE("/tags/aliases/:alias", DeleteTagAliasHandler(db))
+
+		admin.GET("/experiments", ListExperimentsHandler(db))
+		admin.POST("/experiments", StartExperimentHandler(db))
+		admin.POST("/experiments/:experimentId/end", EndExperimentHandler(db))
+		admin.GET("/experiments/:experimentId/report", ExperimentReportHandler(db))
+	}
 }
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const (
+	maxSavedSearches       = 20
+	maxSavedSearchNameLen  = 50
+	savedSearchAlertsBatch = 100
+)
+
+// savedSearchColumns is selected from saved_searches and read by
+// scanSavedSearch.
+const savedSearchColumns = `id, name, min_age, max_age, max_distance_km, min_fame, max_fame, tags, alerts, created_at`
+
+func scanSavedSearch(scan func(dest ...interface{}) error) (SavedSearch, error) {
+	var search SavedSearch
+	var minAge, maxAge sql.NullInt64
+	var maxDistance, minFame, maxFame sql.NullFloat64
+	var tags pq.StringArray
+	err := scan(&search.ID, &search.Name, &minAge, &maxAge, &maxDistance, &minFame, &maxFame, &tags, &search.Alerts, &search.CreatedAt)
+	if err != nil {
+		return search, err
+	}
+	if minAge.Valid {
+		search.MinAge = intPtr(int(minAge.Int64))
+	}
+	if maxAge.Valid {
+		search.MaxAge = intPtr(int(maxAge.Int64))
+	}
+	if maxDistance.Valid {
+		search.MaxDistanceKm = float64Ptr(maxDistance.Float64)
+	}
+	if minFame.Valid {
+		search.MinFame = float64Ptr(minFame.Float64)
+	}
+	if maxFame.Valid {
+		search.MaxFame = float64Ptr(maxFame.Float64)
+	}
+	search.Tags = stringsOrEmpty(tags)
+	return search, nil
+}
+
+// preferences returns the search's criteria as discovery preferences.
+func (s SavedSearch) preferences() DiscoveryPreferences {
+	return DiscoveryPreferences{
+		MinAge:        s.MinAge,
+		MaxAge:        s.MaxAge,
+		MaxDistanceKm: s.MaxDistanceKm,
+		MinFame:       s.MinFame,
+		MaxFame:       s.MaxFame,
+		RequiredTags:  s.Tags,
+		Dealbreakers:  []string{},
+	}
+}
+
+func ListSavedSearchesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userIDVal, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+		userID := userIDVal.(int)
+
+		rows, err := db.QueryContext(ctx, `
+			SELECT `+savedSearchColumns+` FROM saved_searches
+			WHERE user_id = $1
+			ORDER BY created_at, id
+		`, userID)
+		if err != nil {
+			log.Printf("Error fetching saved searches: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		defer rows.Close()
+
+		searches := []SavedSearch{}
+		for rows.Next() {
+			search, err := scanSavedSearch(rows.Scan)
+			if err != nil {
+				log.Printf("Error scanning saved search: %v", err)
+				continue
+			}
+			searches = append(searches, search)
+		}
+
+		c.JSON(200, SavedSearchesResponse{Searches: searches})
+	}
+}
+
+// CreateSavedSearchHandler saves a suggestions filter under a name. The
+// profiles it matches now are recorded, so alerts only report later ones.
+func CreateSavedSearchHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userIDVal, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+		userID := userIDVal.(int)
+
+		var request SavedSearchRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		name := strings.TrimSpace(request.Name)
+		if name == "" || len([]rune(name)) > maxSavedSearchNameLen {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("name must be between 1 and %d characters", maxSavedSearchNameLen)})
+			return
+		}
+
+		// Dealbreakers have no meaning for a search.
+		criteria, err := validatePreferences(DiscoveryPreferences{
+			MinAge:        request.MinAge,
+			MaxAge:        request.MaxAge,
+			MaxDistanceKm: request.MaxDistanceKm,
+			MinFame:       request.MinFame,
+			MaxFame:       request.MaxFame,
+			RequiredTags:  request.Tags,
+		})
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		criteria.RequiredTags, err = canonicalTagNames(ctx, db, criteria.RequiredTags)
+		if err != nil {
+			log.Printf("Error resolving tag aliases: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Error starting transaction"})
+			return
+		}
+		defer tx.Rollback()
+
+		// Locking the user's row serializes concurrent saves, so two requests
+		// cannot both pass the count check below.
+		_, err = tx.ExecContext(ctx, "SELECT 1 FROM users WHERE id = $1 FOR NO KEY UPDATE", userID)
+		if err != nil {
+			log.Printf("Error locking user for saved search: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		var count int
+		err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM saved_searches WHERE user_id = $1", userID).Scan(&count)
+		if err != nil {
+			log.Printf("Error counting saved searches: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		if count >= maxSavedSearches {
+			c.JSON(400, gin.H{"error": fmt.Sprintf("Too many saved searches; maximum is %d", maxSavedSearches)})
+			return
+		}
+
+		search, err := scanSavedSearch(tx.QueryRowContext(ctx, `
+			INSERT INTO saved_searches (user_id, name, min_age, max_age, max_distance_km, min_fame, max_fame, tags, alerts)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (user_id, name) DO NOTHING
+			RETURNING `+savedSearchColumns,
+			userID, name, criteria.MinAge, criteria.MaxAge, criteria.MaxDistanceKm,
+			criteria.MinFame, criteria.MaxFame, pq.Array(criteria.RequiredTags), request.Alerts).Scan)
+		if err == sql.ErrNoRows {
+			c.JSON(400, gin.H{"error": "A saved search with this name already exists"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error saving search: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			log.Printf("Error committing saved search: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		if _, err := runSavedSearch(ctx, db, userID, search); err != nil {
+			log.Printf("Error recording saved search %d results: %v", search.ID, err)
+		}
+
+		c.JSON(200, search)
+	}
+}
+
+func DeleteSavedSearchHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userIDVal, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+		userID := userIDVal.(int)
+
+		searchID, err := strconv.Atoi(c.Param("searchId"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid search ID"})
+			return
+		}
+
+		result, err := db.ExecContext(ctx, "DELETE FROM saved_searches WHERE id = $1 AND user_id = $2", searchID, userID)
+		if err != nil {
+			log.Printf("Error deleting saved search: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			c.JSON(404, gin.H{"error": "Saved search not found"})
+			return
+		}
+
+		c.JSON(200, MessageResponse{Message: "Saved search deleted"})
+	}
+}
+
+// SetSavedSearchAlertsHandler turns new-match alerts of a saved search on or
+// off.
+func SetSavedSearchAlertsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userIDVal, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+		userID := userIDVal.(int)
+
+		searchID, err := strconv.Atoi(c.Param("searchId"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid search ID"})
+			return
+		}
+
+		var request SavedSearchAlertsRequest
+		if err := c.ShouldBindJSON(&request); err != nil || request.Alerts == nil {
+			c.JSON(400, gin.H{"error": "alerts must be true or false"})
+			return
+		}
+
+		search, err := scanSavedSearch(db.QueryRowContext(ctx, `
+			UPDATE saved_searches SET alerts = $1
+			WHERE id = $2 AND user_id = $3
+			RETURNING `+savedSearchColumns, *request.Alerts, searchID, userID).Scan)
+		if err == sql.ErrNoRows {
+			c.JSON(404, gin.H{"error": "Saved search not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error updating saved search alerts: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		c.JSON(200, search)
+	}
+}
+
+// runSavedSearch finds the profiles matching a saved search that it has not
+// matched before, the same way suggestions would with the search's filters,
+// and records them. It returns their IDs, newest profiles first.
+func runSavedSearch(ctx context.Context, db *sql.DB, userID int, search SavedSearch) ([]int, error) {
+	viewer, err := loadSuggestionViewer(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+	preferences, err := loadDiscoveryPreferences(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var filters discoveryFilters
+	filters.applyPreferences(search.preferences())
+	filters.applyPreferences(preferences)
+
+	conditions, args, err := suggestionConditions(userID, viewer, filters)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, search.ID)
+	conditions = append(conditions, sqlCondition{Name: "new",
+		SQL: "NOT EXISTS (SELECT 1 FROM saved_search_results r WHERE r.search_id = $" + strconv.Itoa(len(args)) + " AND r.user_id = u.id)"})
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT u.id
+		FROM users u
+		LEFT JOIN user_locations u_loc ON u.id = u_loc.user_id
+		WHERE `+joinConditions(conditions)+`
+		ORDER BY u.created_at DESC, u.id DESC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO saved_search_results (search_id, user_id)
+		SELECT $1, UNNEST($2::int[])
+		ON CONFLICT DO NOTHING
+	`, search.ID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// checkSavedSearchAlerts runs the saved searches with alerts that were not
+// checked in the last savedSearchInterval, and notifies their owners of new
+// matches. Searches are claimed with SKIP LOCKED, so servers sharing the
+// database split them. It returns the number of searches checked.
+func checkSavedSearchAlerts(ctx context.Context, db *sql.DB) (int, error) {
+	checked := 0
+	for {
+		rows, err := db.QueryContext(ctx, `
+			UPDATE saved_searches SET last_checked_at = NOW()
+			WHERE id IN (
+				SELECT id FROM saved_searches
+				WHERE alerts AND (last_checked_at IS NULL OR last_checked_at <= NOW() - $1 * INTERVAL '1 second')
+				ORDER BY last_checked_at NULLS FIRST
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING user_id, `+savedSearchColumns,
+			(savedSearchInterval * 9 / 10).Seconds(), savedSearchAlertsBatch)
+		if err != nil {
+			return checked, err
+		}
+
+		type claimedSearch struct {
+			userID int
+			search SavedSearch
+		}
+		var claimed []claimedSearch
+		for rows.Next() {
+			var userID int
+			search, err := scanSavedSearch(func(dest ...interface{}) error {
+				return rows.Scan(append([]interface{}{&userID}, dest...)...)
+			})
+			if err != nil {
+				rows.Close()
+				return checked, err
+			}
+			claimed = append(claimed, claimedSearch{userID, search})
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return checked, err
+		}
+		if len(claimed) == 0 {
+			return checked, nil
+		}
+
+		for _, c := range claimed {
+			ids, err := runSavedSearch(ctx, db, c.userID, c.search)
+			if err != nil {
+				log.Printf("Error running saved search %d: %v", c.search.ID, err)
+				continue
+			}
+			checked++
+			if len(ids) == 0 {
+				continue
+			}
+
+			message := fmt.Sprintf("A new profile matches your search \"%s\"", c.search.Name)
+			if len(ids) > 1 {
+				message = fmt.Sprintf("%d new profiles match your search \"%s\"", len(ids), c.search.Name)
+			}
+			CreateAndPushNotification(db, c.userID, "saved_search", ids[0], message)
+		}
+	}
+}
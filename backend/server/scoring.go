@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"matcha/store"
+)
+
+// scoreProfile is what a Scorer knows about either side of a match.
+// Recommendations is only set on the viewer: collaborative-filtering scores
+// by candidate ID, nil when the viewer has none.
+type scoreProfile struct {
+	UserID          int
+	HasLocation     bool
+	Lat, Lon        float64
+	Tags            []string
+	FameRating      float64
+	Recommendations map[int]float64
+}
+
+// MatchScore is a 0-100 compatibility score and the features it was built
+// from, keyed by feature name.
+type MatchScore struct {
+	Total     float64
+	Breakdown map[string]ScoreComponent
+}
+
+// Scorer rates how well candidate fits viewer.
+type Scorer interface {
+	Score(viewer, candidate scoreProfile) MatchScore
+}
+
+// Distance decay curves map a distance in km to a 0-100 score; ScaleKm sets
+// how fast the score falls.
+const (
+	decayLinear      = "linear"      // 100 at 0km, 0 at ScaleKm
+	decayExponential = "exponential" // 100*e^(-d/ScaleKm)
+	decayGaussian    = "gaussian"    // 100*e^(-d²/2ScaleKm²)
+)
+
+// What to do with a feature whose data is missing. The distance feature
+// follows MissingLocation; the others are always renormalized.
+const (
+	missingRenormalize = "renormalize" // drop the feature, rescale the other weights
+	missingNeutral     = "neutral"     // score the feature at NeutralScore
+	missingZero        = "zero"        // the whole match scores 0
+)
+
+type scoringConfig struct {
+	DistanceWeight      float64
+	TagsWeight          float64
+	FameWeight          float64
+	CollaborativeWeight float64
+	DistanceDecay       string
+	DistanceScaleKm     float64
+	MissingLocation     string
+	NeutralScore        float64
+}
+
+// defaultScoringConfig keeps the original formula (0.5 distance losing 0.2
+// point per km, 0.3 tag Jaccard, 0.2 fame) but no longer scores a profile
+// without a location 0: its distance weight goes to the other features.
+// Viewers with collaborative-filtering recommendations also get that signal.
+var defaultScoringConfig = scoringConfig{
+	DistanceWeight:      0.5,
+	TagsWeight:          0.3,
+	FameWeight:          0.2,
+	CollaborativeWeight: 0.15,
+	DistanceDecay:       decayLinear,
+	DistanceScaleKm:     500,
+	MissingLocation:     missingRenormalize,
+	NeutralScore:        50,
+}
+
+// loadScoringConfig reads MATCH_* environment variables over the defaults:
+// MATCH_WEIGHT_DISTANCE, MATCH_WEIGHT_TAGS, MATCH_WEIGHT_FAME,
+// MATCH_WEIGHT_COLLABORATIVE, MATCH_DISTANCE_DECAY, MATCH_DISTANCE_SCALE_KM, MATCH_MISSING_LOCATION and
+// MATCH_NEUTRAL_SCORE. Invalid values are logged and ignored.
+func loadScoringConfig() scoringConfig {
+	config := defaultScoringConfig
+
+	envFloat := func(name string, target *float64) {
+		value := os.Getenv(name)
+		if value == "" {
+			return
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f < 0 {
+			log.Printf("Invalid %s %q, keeping %v", name, value, *target)
+			return
+		}
+		*target = f
+	}
+	envChoice := func(name string, target *string, choices ...string) {
+		value := strings.ToLower(os.Getenv(name))
+		if value == "" {
+			return
+		}
+		for _, choice := range choices {
+			if value == choice {
+				*target = value
+				return
+			}
+		}
+		log.Printf("Invalid %s %q, keeping %s", name, value, *target)
+	}
+
+	envFloat("MATCH_WEIGHT_DISTANCE", &config.DistanceWeight)
+	envFloat("MATCH_WEIGHT_TAGS", &config.TagsWeight)
+	envFloat("MATCH_WEIGHT_FAME", &config.FameWeight)
+	envFloat("MATCH_WEIGHT_COLLABORATIVE", &config.CollaborativeWeight)
+	envChoice("MATCH_DISTANCE_DECAY", &config.DistanceDecay, decayLinear, decayExponential, decayGaussian)
+	envFloat("MATCH_DISTANCE_SCALE_KM", &config.DistanceScaleKm)
+	envChoice("MATCH_MISSING_LOCATION", &config.MissingLocation, missingRenormalize, missingNeutral, missingZero)
+	envFloat("MATCH_NEUTRAL_SCORE", &config.NeutralScore)
+
+	if config.DistanceScaleKm == 0 {
+		log.Printf("MATCH_DISTANCE_SCALE_KM must be positive, keeping %v", defaultScoringConfig.DistanceScaleKm)
+		config.DistanceScaleKm = defaultScoringConfig.DistanceScaleKm
+	}
+	// The collaborative signal is missing for many viewers, so it cannot be
+	// the only one.
+	if config.DistanceWeight+config.TagsWeight+config.FameWeight == 0 {
+		log.Printf("MATCH_WEIGHT_DISTANCE, MATCH_WEIGHT_TAGS and MATCH_WEIGHT_FAME are all 0, using the default weights")
+		config.DistanceWeight = defaultScoringConfig.DistanceWeight
+		config.TagsWeight = defaultScoringConfig.TagsWeight
+		config.FameWeight = defaultScoringConfig.FameWeight
+	}
+	return config
+}
+
+// matchConfig is the scoring configuration of the API, and matchScorer its
+// scorer. Experiment variants override matchConfig's weights.
+var (
+	matchConfig        = loadScoringConfig()
+	matchScorer Scorer = newWeightedScorer(matchConfig)
+)
+
+// scoreFeature returns a 0-100 score, or false when the data it needs is
+// missing, in which case onMissing applies.
+type scoreFeature struct {
+	name      string
+	weight    float64
+	score     func(viewer, candidate scoreProfile) (float64, bool)
+	onMissing string
+}
+
+// weightedScorer sums weighted feature scores. Weights are normalized, so
+// they only need to be correct relative to each other.
+type weightedScorer struct {
+	config   scoringConfig
+	features []scoreFeature
+}
+
+func newWeightedScorer(config scoringConfig) *weightedScorer {
+	s := &weightedScorer{config: config}
+	s.features = []scoreFeature{
+		{"distance", config.DistanceWeight, s.distanceScore, config.MissingLocation},
+		{"tags", config.TagsWeight, tagsScore, missingRenormalize},
+		{"fame", config.FameWeight, fameScore, missingRenormalize},
+		{"collaborative", config.CollaborativeWeight, collaborativeScore, missingRenormalize},
+	}
+	return s
+}
+
+// ScoreComponent is the part of a match score one feature accounts for.
+// Contribution is Score times the weight actually applied; Fallback marks a
+// feature scored without its data.
+type ScoreComponent struct {
+	Score        float64 `json:"score"`
+	Weight       float64 `json:"weight"`
+	Contribution float64 `json:"contribution"`
+	Fallback     bool    `json:"fallback,omitempty"`
+}
+
+func (s *weightedScorer) Score(viewer, candidate scoreProfile) MatchScore {
+	type featureResult struct {
+		feature  scoreFeature
+		score    float64
+		fallback bool
+		skipped  bool
+	}
+
+	results := make([]featureResult, 0, len(s.features))
+	totalWeight := 0.0
+	zeroed := false
+	for _, feature := range s.features {
+		score, ok := feature.score(viewer, candidate)
+		result := featureResult{feature: feature, score: score}
+		if !ok {
+			result.fallback = true
+			switch feature.onMissing {
+			case missingNeutral:
+				result.score = s.config.NeutralScore
+			case missingZero:
+				result.score = 0
+				zeroed = true
+			default:
+				result.score = 0
+				result.skipped = true
+			}
+		}
+		if !result.skipped {
+			totalWeight += feature.weight
+		}
+		results = append(results, result)
+	}
+
+	match := MatchScore{Breakdown: make(map[string]ScoreComponent, len(results))}
+	for _, result := range results {
+		weight := 0.0
+		if !result.skipped && !zeroed && totalWeight > 0 {
+			weight = result.feature.weight / totalWeight
+		}
+		contribution := result.score * weight
+		match.Total += contribution
+		match.Breakdown[result.feature.name] = ScoreComponent{
+			Score:        result.score,
+			Weight:       weight,
+			Contribution: contribution,
+			Fallback:     result.fallback,
+		}
+	}
+	return match
+}
+
+func (s *weightedScorer) distanceScore(viewer, candidate scoreProfile) (float64, bool) {
+	if !viewer.HasLocation || !candidate.HasLocation {
+		return 0, false
+	}
+	distance := haversineDistance(viewer.Lat, viewer.Lon, candidate.Lat, candidate.Lon)
+	return distanceDecay(s.config.DistanceDecay, distance, s.config.DistanceScaleKm), true
+}
+
+func distanceDecay(curve string, distanceKm, scaleKm float64) float64 {
+	switch curve {
+	case decayExponential:
+		return 100 * math.Exp(-distanceKm/scaleKm)
+	case decayGaussian:
+		return 100 * math.Exp(-(distanceKm*distanceKm)/(2*scaleKm*scaleKm))
+	default:
+		return math.Max(0, 100*(1-distanceKm/scaleKm))
+	}
+}
+
+// tagsScore is the Jaccard index of both tag sets, as a percentage.
+func tagsScore(viewer, candidate scoreProfile) (float64, bool) {
+	common := countCommonTags(viewer.Tags, candidate.Tags)
+	total := len(viewer.Tags) + len(candidate.Tags) - common
+	if total == 0 {
+		return 0, true
+	}
+	return float64(common) / float64(total) * 100, true
+}
+
+func fameScore(_, candidate scoreProfile) (float64, bool) {
+	return candidate.FameRating, true
+}
+
+// collaborativeScore is the candidate's score among the profiles liked by
+// users who liked the same profiles as the viewer (see
+// store.RecomputeRecommendations). Candidates outside the viewer's
+// recommendations score 0.
+func collaborativeScore(viewer, candidate scoreProfile) (float64, bool) {
+	if viewer.Recommendations == nil {
+		return 0, false
+	}
+	return viewer.Recommendations[candidate.UserID], true
+}
+
+func countCommonTags(a, b []string) int {
+	seen := make(map[string]bool, len(a))
+	for _, tag := range a {
+		seen[tag] = true
+	}
+	common := 0
+	for _, tag := range b {
+		if seen[tag] {
+			common++
+		}
+	}
+	return common
+}
+
+// commonTags returns the tags of b that are also in a, in b's order.
+func commonTags(a, b []string) []string {
+	seen := make(map[string]bool, len(a))
+	for _, tag := range a {
+		seen[tag] = true
+	}
+	common := []string{}
+	for _, tag := range b {
+		if seen[tag] {
+			common = append(common, tag)
+		}
+	}
+	return common
+}
+
+func newScoreProfile(userID int, lat, lon sql.NullFloat64, tags []string, fameRating float64) scoreProfile {
+	return scoreProfile{
+		UserID:      userID,
+		HasLocation: lat.Valid && lon.Valid,
+		Lat:         lat.Float64,
+		Lon:         lon.Float64,
+		Tags:        tags,
+		FameRating:  fameRating,
+	}
+}
+
+// newViewerScoreProfile is the profile of the user suggestions are scored
+// for, with their collaborative-filtering recommendations.
+func newViewerScoreProfile(ctx context.Context, db *sql.DB, userID int, lat, lon sql.NullFloat64, tags []string) (scoreProfile, error) {
+	viewer := newScoreProfile(userID, lat, lon, tags, 0)
+	recommendations, err := store.New(db).RecommendationScores(ctx, userID)
+	if err != nil {
+		return viewer, err
+	}
+	viewer.Recommendations = recommendations
+	return viewer, nil
+}
+
+// applyMatchScore sets match_score and score_breakdown on a candidate's
+// profile.
+func applyMatchScore(user *UserResponse, scorer Scorer, viewer, candidate scoreProfile) {
+	match := scorer.Score(viewer, candidate)
+	user.MatchScore = float64Ptr(match.Total)
+	user.ScoreBreakdown = match.Breakdown
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSearchQueryLen caps the length of a search query, in characters.
+const maxSearchQueryLen = 200
+
+// bioLanguages are the text search configurations a bio can be indexed
+// with, as allowed by the users.bio_language check.
+var bioLanguages = map[string]bool{"simple": true, "english": true, "french": true}
+
+// searchQuery is the tsquery of the search text bound to $2, parsed once per
+// text search configuration a profile can be indexed with (see the
+// user_search_vector SQL function).
+const searchQuery = `(websearch_to_tsquery('simple', $2)
+	|| websearch_to_tsquery('english', $2)
+	|| websearch_to_tsquery('french', $2))`
+
+// searchSnippet highlights the matches of the bio in <mark> tags. The bio is
+// HTML-escaped first, so the snippet is safe to render as HTML.
+const searchSnippet = `ts_headline(u.bio_language::regconfig,
+	REPLACE(REPLACE(REPLACE(u.bio, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+	q.query, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5, FragmentDelimiter=" … "')`
+
+// SearchProfilesHandler searches profiles by username, first name, tags and
+// bio with Postgres full-text search, best matches first. It only returns
+// profiles the current user could be suggested: discoverable, not ruling
+// the searcher out with their dealbreakers, and searchable.
+func SearchProfilesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userIDVal, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+		userID := userIDVal.(int)
+
+		text := strings.TrimSpace(c.Query("q"))
+		if text == "" {
+			c.JSON(400, gin.H{"error": "q is required"})
+			return
+		}
+		if len([]rune(text)) > maxSearchQueryLen {
+			c.JSON(400, gin.H{"error": "q must be at most " + strconv.Itoa(maxSearchQueryLen) + " characters"})
+			return
+		}
+
+		page, err := parsePageParams(c, "search")
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		conditions := append(discoverableConditions(),
+			sqlCondition{Name: "searchable", SQL: "u.searchable"},
+			sqlCondition{Name: "dealbreakers", SQL: dealbreakerFilter},
+		)
+		rows, err := db.QueryContext(ctx, `
+			WITH q AS (SELECT `+searchQuery+` AS query)
+			SELECT u.id, u.username, u.first_name, u.avatar_url, `+searchSnippet+`, r.rank
+			FROM users u
+			LEFT JOIN user_locations u_loc ON u.id = u_loc.user_id
+			CROSS JOIN q
+			CROSS JOIN LATERAL (SELECT ts_rank(u.search_vector, q.query)::float8 AS rank) r
+			WHERE u.search_vector @@ q.query
+			  AND `+joinConditions(conditions)+`
+			  AND ($3::float8 IS NULL OR (r.rank, u.id) < ($3::float8, $4))
+			ORDER BY r.rank DESC, u.id DESC
+			LIMIT $5
+		`, userID, text, page.cursorRank(), page.cursorID(), page.Limit+1)
+		if err != nil {
+			log.Printf("Error searching profiles: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		defer rows.Close()
+
+		results := []SearchResult{}
+		for rows.Next() {
+			var result SearchResult
+			var avatarURL, snippet sql.NullString
+			if err := rows.Scan(&result.ID, &result.Username, &result.FirstName, &avatarURL, &snippet, &result.Rank); err != nil {
+				log.Printf("Error scanning search result: %v", err)
+				c.JSON(500, gin.H{"error": "Database error"})
+				return
+			}
+			if avatarURL.Valid {
+				result.AvatarURL = &avatarURL.String
+			}
+			if snippet.Valid && snippet.String != "" {
+				result.Snippet = &snippet.String
+			}
+			results = append(results, result)
+		}
+		if err := rows.Err(); err != nil {
+			log.Printf("Error searching profiles: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		var nextCursor *string
+		if len(results) > page.Limit {
+			results = results[:page.Limit]
+			last := results[len(results)-1]
+			nextCursor = rankCursor("search", last.Rank, last.ID)
+		}
+
+		ids := make([]int, len(results))
+		for i, result := range results {
+			ids[i] = result.ID
+		}
+		tagsByUser, err := loadUserTags(ctx, db, ids)
+		if err != nil {
+			log.Printf("Error fetching tags: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		for i := range results {
+			results[i].Tags = stringsOrEmpty(tagsByUser[results[i].ID])
+		}
+
+		c.JSON(200, SearchResponse{Results: results, NextCursor: nextCursor})
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"matcha/geo"
+)
+
+// spatialIndex is the index radius filters are written for. It is detected
+// when the routes are registered: PostGIS when the database has its index,
+// geohashes otherwise.
+var spatialIndex = geo.GeohashIndex
+
+// DetectSpatialIndex sets spatialIndex from the database.
+func DetectSpatialIndex(db *sql.DB) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	index, err := geo.DetectIndex(ctx, db)
+	if err != nil {
+		log.Printf("Error detecting the spatial index, using %s: %v", index, err)
+	}
+	spatialIndex = index
+	log.Printf("Radius queries use the %s index", index)
+}
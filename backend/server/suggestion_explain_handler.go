@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExplainSuggestionHandler explains the ranking of a profile in the current
+// user's suggestions. It takes the same filters as GetSuggestionsHandler and
+// goes through the same conditions, scanning and scoring, so the explanation
+// matches what suggestions return.
+func ExplainSuggestionHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userIDVal, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+		userID := userIDVal.(int)
+
+		candidateID, err := strconv.Atoi(c.Param("userId"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		if candidateID == userID {
+			c.JSON(400, gin.H{"error": "Cannot explain own profile"})
+			return
+		}
+
+		currentUser, err := loadSuggestionViewer(ctx, db, userID)
+		if err != nil {
+			log.Printf("Error fetching current user: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		filters := suggestionFiltersFromQuery(c)
+		preferences, err := loadDiscoveryPreferences(ctx, db, userID)
+		if err != nil {
+			log.Printf("Error fetching discovery preferences: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		filters.applyPreferences(preferences)
+
+		conditions, args, err := suggestionConditions(userID, currentUser, filters)
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Banned and blocked profiles are not explained, as they are not
+		// shown at all.
+		candidates, err := loadSnapshotPage(ctx, db, userID, []int{candidateID}, currentUser.Lat, currentUser.Lon)
+		if err == nil && len(candidates) == 1 {
+			var viewer scoreProfile
+			var scorer Scorer
+			viewer, err = newViewerScoreProfile(ctx, db, userID, currentUser.Lat, currentUser.Lon, currentUser.Tags)
+			if err == nil {
+				scorer, _, err = suggestionScorer(ctx, db, userID)
+			}
+			if err == nil {
+				err = scoreSuggestions(ctx, db, candidates, scorer, viewer)
+			}
+		}
+		if err != nil {
+			log.Printf("Error loading suggestion: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+		if len(candidates) == 0 {
+			c.JSON(404, gin.H{"error": "User not found"})
+			return
+		}
+		candidate := candidates[0].user
+
+		met, err := evaluateConditions(ctx, db, conditions, args, candidateID)
+		if err != nil {
+			log.Printf("Error evaluating suggestion conditions: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		response := SuggestionExplanationResponse{
+			UserID:           candidateID,
+			Suggested:        true,
+			MatchScore:       *candidate.MatchScore,
+			ScoreBreakdown:   candidate.ScoreBreakdown,
+			SharedTags:       commonTags(currentUser.Tags, candidate.Tags),
+			FameRating:       candidate.FameRating,
+			FameContribution: candidate.ScoreBreakdown["fame"].Contribution,
+			Preferences:      []PreferenceMatch{},
+			Penalties:        []SuggestionPenalty{},
+		}
+		if *candidate.DistanceKm >= 0 {
+			response.DistanceKm = candidate.DistanceKm
+		}
+		for i, condition := range conditions {
+			if !met[i] {
+				response.Suggested = false
+				response.Penalties = append(response.Penalties, SuggestionPenalty{Kind: "excluded", Subject: condition.Name})
+			}
+			if condition.Filter {
+				response.Preferences = append(response.Preferences, PreferenceMatch{Filter: condition.Name, Matches: met[i]})
+			}
+		}
+		features := make([]string, 0, len(candidate.ScoreBreakdown))
+		for feature, component := range candidate.ScoreBreakdown {
+			if component.Fallback {
+				features = append(features, feature)
+			}
+		}
+		sort.Strings(features)
+		for _, feature := range features {
+			response.Penalties = append(response.Penalties, SuggestionPenalty{Kind: "missing_data", Subject: feature})
+		}
+
+		c.JSON(200, response)
+	}
+}
+
+// evaluateConditions returns whether the profile userID meets each
+// condition. A condition evaluating to NULL is not met, as in a WHERE clause.
+func evaluateConditions(ctx context.Context, db *sql.DB, conditions []sqlCondition, args []interface{}, userID int) ([]bool, error) {
+	columns := ""
+	for i, condition := range conditions {
+		if i > 0 {
+			columns += ", "
+		}
+		columns += "COALESCE((" + condition.SQL + "), false)"
+	}
+	args = append(args, userID)
+
+	met := make([]bool, len(conditions))
+	dest := make([]interface{}, len(met))
+	for i := range met {
+		dest[i] = &met[i]
+	}
+	err := db.QueryRowContext(ctx, `
+		SELECT `+columns+`
+		FROM users u
+		LEFT JOIN user_locations u_loc ON u.id = u_loc.user_id
+		WHERE u.id = $`+strconv.Itoa(len(args)), args...).Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+	return met, nil
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"matcha/geo"
+	"matcha/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// suggestionSnapshotTTL is how long the ranking behind a suggestions cursor
+// stays valid. Past it the client reloads the first page.
+const suggestionSnapshotTTL = 30 * time.Minute
+
+var errSnapshotExpired = errors.New("Suggestions expired, reload the first page")
+
+// suggestionColumns is selected from users u LEFT JOIN user_locations u_loc
+// and read by scanSuggestions. Locations are the published ones: other
+// users never get exact coordinates.
+const suggestionColumns = `
+	u.id, u.username, u.first_name, u.last_name, u.email, ` + genderColumn + `, ` + interestedInColumn + `,
+	u.birthday, u.bio, u.avatar_url, u.fame_rating, u.last_seen, u_loc.public_lat, u_loc.public_lon`
+
+// sqlCondition is a named SQL condition on users u and user_locations
+// u_loc. The name lets the explain endpoint report which ones a profile
+// fails; Filter marks the conditions coming from discoveryFilters.
+type sqlCondition struct {
+	Name   string
+	SQL    string
+	Filter bool
+}
+
+// joinConditions returns the conditions ANDed together.
+func joinConditions(conditions []sqlCondition) string {
+	parts := make([]string, len(conditions))
+	for i, condition := range conditions {
+		parts[i] = "(" + condition.SQL + ")"
+	}
+	return strings.Join(parts, " AND ")
+}
+
+// discoverableConditions are the conditions for a profile to be shown to
+// the viewer bound to $1 at all: verified, not banned, mutually interested
+// and not blocked either way.
+func discoverableConditions() []sqlCondition {
+	return []sqlCondition{
+		{Name: "verified", SQL: "u.verified = true"},
+		{Name: "not_banned", SQL: "u.banned_at IS NULL"},
+		{Name: "not_self", SQL: "u.id != $1"},
+		{Name: "gender", SQL: viewerInterestedFilter},
+		{Name: "interested_back", SQL: interestedBackFilter},
+		{Name: "not_blocked", SQL: `NOT EXISTS (
+			SELECT 1 FROM blocks
+			WHERE (blocker_id = $1 AND blocked_id = u.id)
+			   OR (blocker_id = u.id AND blocked_id = $1)
+		)`},
+	}
+}
+
+// suggestionConditions returns the conditions a profile must meet to be
+// suggested to userID, with their arguments: discoverable, not passed,
+// within filters and not ruled out by its own dealbreakers.
+func suggestionConditions(userID int, viewer *suggestionViewer, filters discoveryFilters) ([]sqlCondition, []interface{}, error) {
+	args := []interface{}{userID}
+	conditions := append(discoverableConditions(),
+		sqlCondition{Name: "not_passed", SQL: "NOT EXISTS (SELECT 1 FROM profile_passes WHERE passer_id = $1 AND passed_id = u.id)"})
+
+	filterConditions, args, err := filters.clauses(args, viewer.Lat, viewer.Lon)
+	if err != nil {
+		return nil, nil, err
+	}
+	conditions = append(conditions, filterConditions...)
+	conditions = append(conditions, sqlCondition{Name: "dealbreakers", SQL: dealbreakerFilter})
+	return conditions, args, nil
+}
+
+// suggestionFiltersFromQuery reads the discovery filters of a suggestions
+// request.
+func suggestionFiltersFromQuery(c *gin.Context) discoveryFilters {
+	return discoveryFilters{
+		MinAge:      c.Query("minAge"),
+		MaxAge:      c.Query("maxAge"),
+		MinFame:     c.Query("minFame"),
+		MaxFame:     c.Query("maxFame"),
+		MaxDistance: c.Query("maxDistance"),
+		Tags:        c.Query("tags"),
+	}
+}
+
+// maxRequiredTags caps the tags a suggestions request or the preferences
+// can require.
+const maxRequiredTags = 10
+
+// discoveryFilters are the candidate filters of suggestions and the deck, as
+// received in the query string. Empty fields do not filter.
+type discoveryFilters struct {
+	MinAge, MaxAge   string
+	MinFame, MaxFame string
+	MaxDistance      string
+	Tags             string // comma separated
+}
+
+// clauses appends the filter values to args and returns the matching
+// conditions. Unparsable numbers are ignored; too many tags is an error.
+func (f discoveryFilters) clauses(args []interface{}, viewerLat, viewerLon sql.NullFloat64) ([]sqlCondition, []interface{}, error) {
+	var additionalFilters []sqlCondition
+
+	if f.Tags != "" {
+		requiredTags := strings.Split(f.Tags, ",")
+		uniqueRequiredTags := make([]string, 0, len(requiredTags))
+		seenTags := make(map[string]struct{}, len(requiredTags))
+
+		for _, tag := range requiredTags {
+			trimmed := strings.TrimSpace(tag)
+			if trimmed == "" {
+				continue
+			}
+			trimmed = strings.ToLower(trimmed)
+			if _, exists := seenTags[trimmed]; exists {
+				continue
+			}
+
+			seenTags[trimmed] = struct{}{}
+			uniqueRequiredTags = append(uniqueRequiredTags, trimmed)
+
+			if len(uniqueRequiredTags) > maxRequiredTags {
+				return nil, nil, fmt.Errorf("Too many tags provided; maximum is %d", maxRequiredTags)
+			}
+		}
+
+		for _, tag := range uniqueRequiredTags {
+			args = append(args, tag)
+			placeholder := "$" + strconv.Itoa(len(args))
+			additionalFilters = append(additionalFilters, sqlCondition{Filter: true, Name: "tag:" + tag, SQL: fmt.Sprintf(`EXISTS (
+				SELECT 1 FROM user_tags ut
+				JOIN tags t ON ut.tag_id = t.id
+				WHERE ut.user_id = u.id AND t.name = canonical_tag_name(%s)
+			)`, placeholder)})
+		}
+	}
+
+	if f.MinAge != "" || f.MaxAge != "" {
+		minAge := 18
+		maxAge := 99
+
+		if f.MinAge != "" {
+			if v, err := strconv.Atoi(f.MinAge); err == nil {
+				minAge = v
+			}
+		}
+		if f.MaxAge != "" {
+			if v, err := strconv.Atoi(f.MaxAge); err == nil {
+				maxAge = v
+			}
+		}
+
+		args = append(args, minAge, maxAge)
+		minAgePlaceholder := "$" + strconv.Itoa(len(args)-1)
+		maxAgePlaceholder := "$" + strconv.Itoa(len(args))
+		additionalFilters = append(additionalFilters, sqlCondition{Filter: true, Name: "age",
+			SQL: "EXTRACT(YEAR FROM AGE(NOW(), u.birthday)) BETWEEN " + minAgePlaceholder + " AND " + maxAgePlaceholder})
+	}
+
+	if f.MinFame != "" {
+		if v, err := strconv.ParseFloat(f.MinFame, 64); err == nil {
+			args = append(args, v)
+			additionalFilters = append(additionalFilters, sqlCondition{Filter: true, Name: "min_fame", SQL: "u.fame_rating >= $" + strconv.Itoa(len(args))})
+		}
+	}
+
+	if f.MaxFame != "" {
+		if v, err := strconv.ParseFloat(f.MaxFame, 64); err == nil {
+			args = append(args, v)
+			additionalFilters = append(additionalFilters, sqlCondition{Filter: true, Name: "max_fame", SQL: "u.fame_rating <= $" + strconv.Itoa(len(args))})
+		}
+	}
+
+	if f.MaxDistance != "" && viewerLat.Valid && viewerLon.Valid {
+		if maxDist, err := strconv.ParseFloat(f.MaxDistance, 64); err == nil && maxDist > 0 {
+			var within string
+			within, args = spatialIndex.Within("u_loc", geo.Point{Lat: viewerLat.Float64, Lon: viewerLon.Float64}, maxDist, args)
+			additionalFilters = append(additionalFilters, sqlCondition{Filter: true, Name: "max_distance", SQL: "(" + within + " OR u_loc.lat IS NULL)"})
+		}
+	}
+
+	return additionalFilters, args, nil
+}
+
+// applyPreferences fills the filters the request left empty from the user's
+// saved preferences.
+func (f *discoveryFilters) applyPreferences(p DiscoveryPreferences) {
+	setInt := func(field *string, value *int) {
+		if *field == "" && value != nil {
+			*field = strconv.Itoa(*value)
+		}
+	}
+	setFloat := func(field *string, value *float64) {
+		if *field == "" && value != nil {
+			*field = strconv.FormatFloat(*value, 'f', -1, 64)
+		}
+	}
+	setInt(&f.MinAge, p.MinAge)
+	setInt(&f.MaxAge, p.MaxAge)
+	setFloat(&f.MinFame, p.MinFame)
+	setFloat(&f.MaxFame, p.MaxFame)
+	setFloat(&f.MaxDistance, p.MaxDistanceKm)
+	if f.Tags == "" {
+		f.Tags = strings.Join(p.RequiredTags, ",")
+	}
+}
+
+// suggestion is a candidate profile with the raw values it is scored and
+// sorted on.
+type suggestion struct {
+	user       UserResponse
+	lat, lon   sql.NullFloat64
+	birthday   sql.NullTime
+	fameRating float64
+}
+
+// suggestionSort is the order of a suggestions list. Desc is the direction
+// requested, or the natural one of the key.
+type suggestionSort struct {
+	Key  string
+	Desc bool
+}
+
+// suggestionSortDefaults maps each sort key to whether it sorts descending
+// by default: best matches, most fame and most common tags first; nearest
+// and youngest first.
+var suggestionSortDefaults = map[string]bool{
+	"match":       true,
+	"distance":    false,
+	"age":         false,
+	"fame":        true,
+	"common_tags": true,
+}
+
+// parseSuggestionSort reads sort (default match) and order (asc or desc).
+func parseSuggestionSort(c *gin.Context) (suggestionSort, error) {
+	key := c.DefaultQuery("sort", "match")
+	desc, ok := suggestionSortDefaults[key]
+	if !ok {
+		return suggestionSort{}, errors.New("sort must be one of match, distance, age, fame, common_tags")
+	}
+	switch c.Query("order") {
+	case "":
+	case "asc":
+		desc = false
+	case "desc":
+		desc = true
+	default:
+		return suggestionSort{}, errors.New("order must be asc or desc")
+	}
+	return suggestionSort{Key: key, Desc: desc}, nil
+}
+
+func (s suggestionSort) String() string {
+	if s.Desc {
+		return s.Key + " desc"
+	}
+	return s.Key + " asc"
+}
+
+// scanSuggestions reads suggestionColumns rows and computes each distance
+// from the viewer's location, rounded to its bucket.
+func scanSuggestions(rows *sql.Rows, viewerLat, viewerLon sql.NullFloat64) ([]suggestion, error) {
+	suggestions := []suggestion{}
+	for rows.Next() {
+		var userID int
+		var username, email, firstName, lastName string
+		var gender, bio, avatarURL sql.NullString
+		var interestedIn pq.StringArray
+		var birthday, lastSeen sql.NullTime
+		var fameRating, lat, lon sql.NullFloat64
+
+		err := rows.Scan(
+			&userID, &username, &firstName, &lastName, &email,
+			&gender, &interestedIn, &birthday, &bio, &avatarURL, &fameRating, &lastSeen, &lat, &lon,
+		)
+		if err != nil {
+			log.Printf("Error scanning user: %v", err)
+			continue
+		}
+
+		user := UserResponse{
+			ID:        userID,
+			Username:  username,
+			Email:     email,
+			FirstName: firstName,
+			LastName:  lastName,
+		}
+		applyPresence(&user, lastSeen)
+		applyProfileFields(&user, gender, interestedIn, birthday, bio, avatarURL, fameRating)
+
+		user.DistanceKm = float64Ptr(-1)
+		if lat.Valid && lon.Valid {
+			user.Latitude = float64Ptr(lat.Float64)
+			user.Longitude = float64Ptr(lon.Float64)
+			if viewerLat.Valid && viewerLon.Valid {
+				user.DistanceKm = float64Ptr(geo.RoundDistanceKm(haversineDistance(viewerLat.Float64, viewerLon.Float64, lat.Float64, lon.Float64)))
+			}
+		}
+
+		suggestions = append(suggestions, suggestion{
+			user:       user,
+			lat:        lat,
+			lon:        lon,
+			birthday:   birthday,
+			fameRating: fameRating.Float64,
+		})
+	}
+	return suggestions, rows.Err()
+}
+
+// scoreSuggestions loads the tags of every suggestion and sets tags,
+// common_tags, match_score and score_breakdown.
+func scoreSuggestions(ctx context.Context, db *sql.DB, suggestions []suggestion, scorer Scorer, viewer scoreProfile) error {
+	ids := make([]int, len(suggestions))
+	for i := range suggestions {
+		ids[i] = suggestions[i].user.ID
+	}
+	tagsByUser, err := loadUserTags(ctx, db, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range suggestions {
+		s := &suggestions[i]
+		tags := stringsOrEmpty(tagsByUser[s.user.ID])
+		s.user.Tags = tags
+		s.user.CommonTags = intPtr(countCommonTags(viewer.Tags, tags))
+		applyMatchScore(&s.user, scorer, viewer, newScoreProfile(s.user.ID, s.lat, s.lon, tags, s.fameRating))
+	}
+	return nil
+}
+
+// sortSuggestions orders suggestions by order, then by ID. Profiles without
+// the sorted value (no location for distance, no birthday for age) come last
+// in both directions.
+func sortSuggestions(suggestions []suggestion, order suggestionSort) {
+	// value returns the sort value and whether it is known.
+	value := func(s *suggestion) (float64, bool) {
+		switch order.Key {
+		case "distance":
+			return *s.user.DistanceKm, *s.user.DistanceKm >= 0
+		case "age":
+			// An earlier birthday is an older user.
+			return -float64(s.birthday.Time.Unix()), s.birthday.Valid
+		case "fame":
+			return s.user.FameRating, true
+		case "common_tags":
+			return float64(*s.user.CommonTags), true
+		default:
+			return *s.user.MatchScore, true
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, aKnown := value(&suggestions[i])
+		b, bKnown := value(&suggestions[j])
+		if aKnown != bKnown {
+			return aKnown
+		}
+		if a != b {
+			if order.Desc {
+				return a > b
+			}
+			return a < b
+		}
+		return suggestions[i].user.ID < suggestions[j].user.ID
+	})
+}
+
+// saveSuggestionSnapshot stores a ranked list of user IDs and returns its
+// token. Expired snapshots are pruned on the way.
+func saveSuggestionSnapshot(ctx context.Context, db *sql.DB, userID int, order suggestionSort, ids []int) (string, error) {
+	if _, err := db.ExecContext(ctx, `
+		DELETE FROM suggestion_snapshots WHERE created_at < NOW() - $1 * INTERVAL '1 second'
+	`, suggestionSnapshotTTL.Seconds()); err != nil {
+		log.Printf("Error pruning suggestion snapshots: %v", err)
+	}
+
+	token := utils.GenerateToken()
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO suggestion_snapshots (token, user_id, sort, user_ids)
+		VALUES ($1, $2, $3, $4)
+	`, token, userID, order.String(), pq.Array(ids))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// loadSuggestionSnapshot returns the ranked user IDs of one of userID's
+// snapshots, or errSnapshotExpired.
+func loadSuggestionSnapshot(ctx context.Context, db *sql.DB, userID int, token string) ([]int, error) {
+	var ids pq.Int64Array
+	err := db.QueryRowContext(ctx, `
+		SELECT user_ids FROM suggestion_snapshots
+		WHERE token = $1 AND user_id = $2 AND created_at >= NOW() - $3 * INTERVAL '1 second'
+	`, token, userID, suggestionSnapshotTTL.Seconds()).Scan(&ids)
+	if err == sql.ErrNoRows {
+		return nil, errSnapshotExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]int, len(ids))
+	for i, id := range ids {
+		result[i] = int(id)
+	}
+	return result, nil
+}
+
+// loadSnapshotPage fetches the suggestions at ids, in that order. Profiles
+// banned or blocked since the snapshot was taken are left out.
+func loadSnapshotPage(ctx context.Context, db *sql.DB, userID int, ids []int, viewerLat, viewerLon sql.NullFloat64) ([]suggestion, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT`+suggestionColumns+`
+		FROM users u
+		LEFT JOIN user_locations u_loc ON u.id = u_loc.user_id
+		WHERE u.id = ANY($2)
+		  AND u.banned_at IS NULL
+		  AND NOT EXISTS (
+		    SELECT 1 FROM blocks
+		    WHERE (blocker_id = $1 AND blocked_id = u.id)
+		       OR (blocker_id = u.id AND blocked_id = $1)
+		  )
+	`, userID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions, err := scanSuggestions(rows, viewerLat, viewerLon)
+	if err != nil {
+		return nil, err
+	}
+
+	position := make(map[int]int, len(ids))
+	for i, id := range ids {
+		position[id] = i
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		return position[suggestions[i].user.ID] < position[suggestions[j].user.ID]
+	})
+	return suggestions, nil
+}
+
+func suggestionUsers(suggestions []suggestion) []UserResponse {
+	users := make([]UserResponse, len(suggestions))
+	for i := range suggestions {
+		users[i] = suggestions[i].user
+	}
+	return users
+}
+
+// snapshotCursor returns the cursor of the page starting at offset in a
+// ranked snapshot.
+func snapshotCursor(kind, token string, offset int) *string {
+	return encodeCursor(pageCursor{Kind: kind, Snapshot: token, Offset: offset})
+}
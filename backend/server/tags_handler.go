@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"matcha/geo"
+	"matcha/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// addUserTagQuery gives user $1 the tag named $2, or the tag $2 is an alias
+// of, creating the tag when needed. The upsert locks the tag's row, so the
+// tag garbage collection skips it until the user_tags row is written.
+const addUserTagQuery = `
+	WITH tag AS (
+		INSERT INTO tags (name) VALUES (canonical_tag_name($2))
+		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id
+	)
+	INSERT INTO user_tags (user_id, tag_id) SELECT $1, id FROM tag
+	ON CONFLICT DO NOTHING`
+
+const (
+	defaultTagListLimit = 10
+	maxTagListLimit     = 50
+)
+
+// tagListLimit reads the limit query parameter of the tag lists.
+func tagListLimit(c *gin.Context) (int, bool) {
+	limit := defaultTagListLimit
+	if limitStr := c.Query("limit"); limitStr != "" {
+		v, err := strconv.Atoi(limitStr)
+		if err != nil || v <= 0 {
+			c.JSON(400, gin.H{"error": "limit must be a positive integer"})
+			return 0, false
+		}
+		limit = min(v, maxTagListLimit)
+	}
+	return limit, true
+}
+
+// canonicalTagNames resolves aliases in normalized tag names, keeping the
+// order and dropping duplicates.
+func canonicalTagNames(ctx context.Context, db *sql.DB, names []string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT canonical_tag_name(name) FROM UNNEST($1::text[]) WITH ORDINALITY AS n(name, i)
+		ORDER BY i
+	`, pq.Array(names))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	canonical := []string{}
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if !seen[name] {
+			seen[name] = true
+			canonical = append(canonical, name)
+		}
+	}
+	return canonical, rows.Err()
+}
+
+// SearchTagsHandler autocompletes tag names: tags whose name or an alias
+// starts with q come first, then tags with a similar name (pg_trgm), each
+// ranked by how many users have them. Tags nobody has are not returned.
+func SearchTagsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		query, err := normalizeTagName(c.Query("q"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		limit, ok := tagListLimit(c)
+		if !ok {
+			return
+		}
+
+		// Underscores are allowed in tags but are LIKE wildcards.
+		prefix := strings.ReplaceAll(query, "_", `\_`) + "%"
+		tags, err := queryTagCounts(ctx, db, `
+			SELECT t.id, t.name, COUNT(*) AS users
+			FROM tags t
+			JOIN user_tags ut ON ut.tag_id = t.id
+			JOIN users u ON u.id = ut.user_id
+			CROSS JOIN LATERAL (
+				SELECT t.name LIKE $2 OR EXISTS (
+					SELECT 1 FROM tag_aliases a WHERE a.tag_id = t.id AND a.alias LIKE $2
+				) AS prefix
+			) m
+			WHERE (m.prefix OR t.name % $1) AND u.banned_at IS NULL
+			GROUP BY t.id, m.prefix
+			ORDER BY m.prefix DESC, users DESC, similarity(t.name, $1) DESC, t.name
+			LIMIT $3
+		`, query, prefix, limit)
+		if err != nil {
+			log.Printf("Error searching tags: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		c.JSON(200, TagCountsResponse{Tags: tags})
+	}
+}
+
+// PopularTagsHandler returns the tags most users have. With radius, only
+// users within radius kilometers of the current user count.
+func PopularTagsHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		userIDVal, exists := c.Get("userID")
+		if !exists {
+			c.JSON(401, gin.H{"error": "Unauthorized"})
+			return
+		}
+		userID := userIDVal.(int)
+
+		limit, ok := tagListLimit(c)
+		if !ok {
+			return
+		}
+
+		radiusStr := c.Query("radius")
+		if radiusStr == "" {
+			tags, err := queryTagCounts(ctx, db, `
+				SELECT t.id, t.name, COUNT(*) AS users
+				FROM tags t
+				JOIN user_tags ut ON ut.tag_id = t.id
+				JOIN users u ON u.id = ut.user_id
+				WHERE u.banned_at IS NULL
+				GROUP BY t.id
+				ORDER BY users DESC, t.name
+				LIMIT $1
+			`, limit)
+			if err != nil {
+				log.Printf("Error fetching popular tags: %v", err)
+				c.JSON(500, gin.H{"error": "Database error"})
+				return
+			}
+			c.JSON(200, TagCountsResponse{Tags: tags})
+			return
+		}
+
+		radiusKm, err := strconv.ParseFloat(radiusStr, 64)
+		if err != nil || radiusKm <= 0 {
+			c.JSON(400, gin.H{"error": "radius must be a positive number"})
+			return
+		}
+		var lat, lon float64
+		err = db.QueryRowContext(ctx, "SELECT lat, lon FROM user_locations WHERE user_id = $1", userID).Scan(&lat, &lon)
+		if err == sql.ErrNoRows {
+			c.JSON(400, gin.H{"error": "Location not set"})
+			return
+		} else if err != nil {
+			log.Printf("Error fetching location: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		within, args := spatialIndex.Within("l", geo.Point{Lat: lat, Lon: lon}, radiusKm, []interface{}{limit})
+		tags, err := queryTagCounts(ctx, db, `
+			SELECT t.id, t.name, COUNT(*) AS users
+			FROM tags t
+			JOIN user_tags ut ON ut.tag_id = t.id
+			JOIN users u ON u.id = ut.user_id
+			JOIN user_locations l ON l.user_id = ut.user_id
+			WHERE u.banned_at IS NULL AND `+within+`
+			GROUP BY t.id
+			ORDER BY users DESC, t.name
+			LIMIT $1
+		`, args...)
+		if err != nil {
+			log.Printf("Error fetching popular tags: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		c.JSON(200, TagCountsResponse{Tags: tags})
+	}
+}
+
+func queryTagCounts(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]TagCount, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []TagCount{}
+	for rows.Next() {
+		var tag TagCount
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Users); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// ListTagAliasesHandler lists every tag alias.
+func ListTagAliasesHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		aliases, err := store.New(db).TagAliases(c.Request.Context())
+		if err != nil {
+			log.Printf("Error fetching tag aliases: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		response := TagAliasesResponse{Aliases: make([]TagAlias, len(aliases))}
+		for i, alias := range aliases {
+			response.Aliases[i] = TagAlias{Alias: alias.Alias, Tag: alias.Tag}
+		}
+		c.JSON(200, response)
+	}
+}
+
+// MergeTagHandler makes a tag an alias of another: its users, aliases,
+// preferences and saved searches move to the target tag.
+func MergeTagHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var request MergeTagRequest
+		if err := c.BindJSON(&request); err != nil {
+			c.JSON(400, gin.H{"error": "Invalid request data"})
+			return
+		}
+		source, err := normalizeTagName(request.Source)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "source: " + err.Error()})
+			return
+		}
+		target, err := normalizeTagName(request.Target)
+		if err != nil {
+			c.JSON(400, gin.H{"error": "target: " + err.Error()})
+			return
+		}
+
+		target, moved, err := store.New(db).MergeTag(ctx, source, target)
+		if errors.Is(err, store.ErrSameTag) {
+			c.JSON(400, gin.H{"error": "Cannot merge a tag into itself"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error merging tag %s into %s: %v", source, target, err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		log.Printf("Admin %d merged tag %s into %s", c.GetInt("userID"), source, target)
+		c.JSON(200, MergeTagResponse{Source: source, Target: target, MovedUsers: moved})
+	}
+}
+
+// DeleteTagAliasHandler removes an alias, so the name becomes a tag of its
+// own again on next use. Merged users keep the tag they were moved to.
+func DeleteTagAliasHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		alias, err := normalizeTagName(c.Param("alias"))
+		if err != nil {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+
+		err = store.New(db).DeleteTagAlias(ctx, alias)
+		if errors.Is(err, store.ErrNotFound) {
+			c.JSON(404, gin.H{"error": "Alias not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("Error deleting tag alias: %v", err)
+			c.JSON(500, gin.H{"error": "Database error"})
+			return
+		}
+
+		c.JSON(200, MessageResponse{Message: "Alias deleted"})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultQueryTimeout bounds the database work of a request unless its
+// apiOperation sets a Timeout. DB_QUERY_TIMEOUT overrides it (e.g. "3s").
+var defaultQueryTimeout = 5 * time.Second
+
+func init() {
+	if value := os.Getenv("DB_QUERY_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			log.Printf("Invalid DB_QUERY_TIMEOUT %q, keeping %s", value, defaultQueryTimeout)
+			return
+		}
+		defaultQueryTimeout = timeout
+	}
+}
+
+// backgroundQueryContext is for database work that outlives the request that
+// triggered it, such as last_seen updates and notifications.
+func backgroundQueryContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), defaultQueryTimeout)
+}
+
+// QueryTimeoutMiddleware puts a deadline on the request context, which every
+// handler passes to its queries. The context is also cancelled when the
+// client disconnects, so abandoned queries stop running.
+//
+// Handlers report query errors as they always have; once the deadline has
+// passed, their error response is replaced by a 503 with code db_timeout.
+func QueryTimeoutMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout := defaultQueryTimeout
+		if op := findAPIOperation(c.Request.Method, c.FullPath()); op != nil && op.Timeout > 0 {
+			timeout = op.Timeout
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Writer = &timeoutResponseWriter{ResponseWriter: c.Writer, ctx: ctx}
+		c.Next()
+	}
+}
+
+type timeoutResponseWriter struct {
+	gin.ResponseWriter
+	ctx      context.Context
+	timedOut bool
+	written  bool
+}
+
+func (w *timeoutResponseWriter) WriteHeader(code int) {
+	if code >= 400 && errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		w.timedOut = true
+		code = http.StatusServiceUnavailable
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutResponseWriter) Write(data []byte) (int, error) {
+	if !w.timedOut {
+		return w.ResponseWriter.Write(data)
+	}
+	if !w.written {
+		w.written = true
+		body, _ := json.Marshal(ErrorResponse{Error: "Database timeout", Code: "db_timeout"})
+		if _, err := w.ResponseWriter.Write(body); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+func (w *timeoutResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// List handlers scan a page of users first and then load the per-user
+// collections for the whole page in one query each, instead of one query per
+// row.
+
+// loadUserTags returns the tag names of each user, sorted by name. Users
+// without tags are absent from the map.
+func loadUserTags(ctx context.Context, db *sql.DB, userIDs []int) (map[int][]string, error) {
+	tags := make(map[int][]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return tags, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT ut.user_id, array_agg(t.name ORDER BY t.name)
+		FROM user_tags ut
+		JOIN tags t ON t.id = ut.tag_id
+		WHERE ut.user_id = ANY($1)
+		GROUP BY ut.user_id
+	`, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID int
+		var names pq.StringArray
+		if err := rows.Scan(&userID, &names); err != nil {
+			return nil, err
+		}
+		tags[userID] = names
+	}
+	return tags, rows.Err()
+}
+
+// loadUserImages returns the image paths of each user, profile picture first.
+// Users without images are absent from the map.
+func loadUserImages(ctx context.Context, db *sql.DB, userIDs []int) (map[int][]string, error) {
+	images := make(map[int][]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return images, nil
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT user_id, array_agg(path ORDER BY is_profile_picture DESC, id ASC)
+		FROM user_images
+		WHERE user_id = ANY($1)
+		GROUP BY user_id
+	`, pq.Array(userIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID int
+		var paths pq.StringArray
+		if err := rows.Scan(&userID, &paths); err != nil {
+			return nil, err
+		}
+		images[userID] = paths
+	}
+	return images, rows.Err()
+}
+
+// stringsOrEmpty keeps JSON arrays as [] rather than null for users missing
+// from a loader map.
+func stringsOrEmpty(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
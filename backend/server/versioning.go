@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	apiVersion1 = 1
+	apiVersion2 = 2
+
+	latestStableAPIVersion = apiVersion1
+)
+
+// Root routes (/me, /users, ...) predate the /v1 prefix. They keep working
+// until rootAliasSunset but every response advertises its replacement.
+var (
+	rootAliasDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	rootAliasSunset       = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+)
+
+func init() {
+	if value := os.Getenv("API_ROOT_SUNSET"); value != "" {
+		sunset, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			log.Printf("Invalid API_ROOT_SUNSET %q, keeping %s: %v", value, rootAliasSunset.Format("2006-01-02"), err)
+			return
+		}
+		rootAliasSunset = sunset
+	}
+}
+
+func apiPrefix(version int) string {
+	return fmt.Sprintf("/v%d", version)
+}
+
+// APIVersionMiddleware records which API version a route group serves so
+// handlers can pick the matching response shape with apiVersion(c).
+func APIVersionMiddleware(version int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("apiVersion", version)
+		c.Next()
+	}
+}
+
+func apiVersion(c *gin.Context) int {
+	if version, ok := c.Get("apiVersion"); ok {
+		if v, ok := version.(int); ok {
+			return v
+		}
+	}
+	return latestStableAPIVersion
+}
+
+// DeprecatedAliasMiddleware marks unversioned routes as deprecated
+// (RFC 9745), announces their removal date (RFC 8594) and links to the /v1
+// route that replaces them.
+func DeprecatedAliasMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("Deprecation", fmt.Sprintf("@%d", rootAliasDeprecatedAt.Unix()))
+		header.Set("Sunset", rootAliasSunset.Format(http.TimeFormat))
+		header.Set("Link", fmt.Sprintf("<%s%s>; rel=\"successor-version\"", apiPrefix(latestStableAPIVersion), c.Request.URL.Path))
+		c.Next()
+	}
+}
+
+// apiRoutePath strips the version prefix from a gin route so versioned and
+// legacy routes map onto the same documented operation.
+func apiRoutePath(fullPath string) string {
+	for _, version := range []int{apiVersion1, apiVersion2} {
+		prefix := apiPrefix(version)
+		if fullPath == prefix {
+			return "/"
+		}
+		if strings.HasPrefix(fullPath, prefix+"/") {
+			return strings.TrimPrefix(fullPath, prefix)
+		}
+	}
+	return fullPath
+}
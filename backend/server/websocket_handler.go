@@ -21,9 +21,10 @@ var upgrader = websocket.Upgrader{
 }
 
 type Client struct {
-	UserID int
-	Conn   *websocket.Conn
-	Send   chan []byte
+	UserID     int
+	APIVersion int
+	Conn       *websocket.Conn
+	Send       chan []byte
 }
 
 type Hub struct {
@@ -35,9 +36,12 @@ type Hub struct {
 	db         *sql.DB
 }
 
+// BroadcastMessage carries either a ready payload or a Render function that
+// builds the payload for the API version the receiving client connected with.
 type BroadcastMessage struct {
 	UserID  int
 	Message []byte
+	Render  func(apiVersion int) []byte
 }
 
 var hub = &Hub{
@@ -66,7 +70,9 @@ func (h *Hub) Run() {
 				
 				if h.db != nil {
 					go func(uid int) {
-						_, err := h.db.Exec("UPDATE users SET last_seen = NOW() WHERE id = $1", uid)
+						ctx, cancel := backgroundQueryContext()
+						defer cancel()
+						_, err := h.db.ExecContext(ctx, "UPDATE users SET last_seen = NOW() WHERE id = $1", uid)
 						if err != nil {
 							log.Printf("Error updating last_seen for user %d: %v", uid, err)
 						}
@@ -81,8 +87,16 @@ func (h *Hub) Run() {
 		case msg := <-h.broadcast:
 			h.mutex.RLock()
 			if client, ok := h.clients[msg.UserID]; ok {
+				payload := msg.Message
+				if msg.Render != nil {
+					payload = msg.Render(client.APIVersion)
+				}
+				if payload == nil {
+					h.mutex.RUnlock()
+					continue
+				}
 				select {
-				case client.Send <- msg.Message:
+				case client.Send <- payload:
 				default:
 					close(client.Send)
 					delete(h.clients, msg.UserID)
@@ -127,6 +141,12 @@ func SendToUser(userID int, message []byte) {
 	hub.broadcast <- BroadcastMessage{UserID: userID, Message: message}
 }
 
+// SendRenderedToUser pushes a message whose shape depends on the API version
+// of the user's websocket connection.
+func SendRenderedToUser(userID int, render func(apiVersion int) []byte) {
+	hub.broadcast <- BroadcastMessage{UserID: userID, Render: render}
+}
+
 func IsUserOnline(userID int) bool {
 	hub.mutex.RLock()
 	defer hub.mutex.RUnlock()
@@ -136,6 +156,8 @@ func IsUserOnline(userID int) bool {
 
 func WebSocketHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
 		sessionToken := c.Query("token")
 		if sessionToken == "" {
 			var err error
@@ -148,7 +170,7 @@ func WebSocketHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		var userID int
-		err := db.QueryRow("SELECT id FROM users WHERE session_token = $1", sessionToken).Scan(&userID)
+		err := db.QueryRowContext(ctx, "SELECT id FROM users WHERE session_token = $1 AND banned_at IS NULL", sessionToken).Scan(&userID)
 		if err != nil {
 			log.Printf("WebSocket: Invalid session token")
 			c.JSON(401, gin.H{"error": "Invalid session"})
@@ -162,9 +184,10 @@ func WebSocketHandler(db *sql.DB) gin.HandlerFunc {
 		}
 
 		client := &Client{
-			UserID: userID,
-			Conn:   conn,
-			Send:   make(chan []byte, 256),
+			UserID:     userID,
+			APIVersion: apiVersion(c),
+			Conn:       conn,
+			Send:       make(chan []byte, 256),
 		}
 
 		hub.register <- client
@@ -0,0 +1,286 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrExperimentRunning is returned when starting an experiment while
+	// another one is running.
+	ErrExperimentRunning = errors.New("another experiment is running")
+	// ErrExperimentExists is returned when an experiment name is taken.
+	ErrExperimentExists = errors.New("experiment name already taken")
+)
+
+// Outcomes of a suggested profile recorded for experiments.
+const (
+	OutcomeLike    = "like"
+	OutcomePass    = "pass"
+	OutcomeMatch   = "match"
+	OutcomeMessage = "message"
+)
+
+// Experiment splits suggestion viewers between variants ranked with
+// different scoring weights. It is running until EndedAt is set.
+type Experiment struct {
+	ID          int                 `json:"id"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	StartedAt   time.Time           `json:"started_at"`
+	EndedAt     *time.Time          `json:"ended_at"`
+	Variants    []ExperimentVariant `json:"variants"`
+}
+
+// ExperimentVariant gets Weight shares of the viewers. Nil scoring weights
+// keep the server's configuration.
+type ExperimentVariant struct {
+	Name                string   `json:"name"`
+	Weight              int      `json:"weight"`
+	DistanceWeight      *float64 `json:"distance_weight"`
+	TagsWeight          *float64 `json:"tags_weight"`
+	FameWeight          *float64 `json:"fame_weight"`
+	CollaborativeWeight *float64 `json:"collaborative_weight"`
+}
+
+// VariantReport counts the profiles suggested to a variant's viewers and
+// what the viewers did with them.
+type VariantReport struct {
+	Variant     string `json:"variant"`
+	Weight      int    `json:"weight"`
+	Viewers     int    `json:"viewers"`
+	Impressions int    `json:"impressions"`
+	Likes       int    `json:"likes"`
+	Passes      int    `json:"passes"`
+	Matches     int    `json:"matches"`
+	Messages    int    `json:"messages"`
+}
+
+// StartExperiment creates a running experiment with its variants, in order.
+func (s *Store) StartExperiment(ctx context.Context, experiment Experiment) (*Experiment, error) {
+	err := s.WithTx(ctx, func(tx *Store) error {
+		var running bool
+		err := tx.q.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM experiments WHERE ended_at IS NULL)").Scan(&running)
+		if err != nil {
+			return err
+		}
+		if running {
+			return ErrExperimentRunning
+		}
+
+		err = tx.q.QueryRowContext(ctx, `
+			INSERT INTO experiments (name, description) VALUES ($1, $2)
+			ON CONFLICT (name) DO NOTHING
+			RETURNING id, started_at
+		`, experiment.Name, experiment.Description).Scan(&experiment.ID, &experiment.StartedAt)
+		if err == sql.ErrNoRows {
+			return ErrExperimentExists
+		}
+		if err != nil {
+			return err
+		}
+
+		for i, variant := range experiment.Variants {
+			_, err := tx.q.ExecContext(ctx, `
+				INSERT INTO experiment_variants
+					(experiment_id, name, position, weight, distance_weight, tags_weight, fame_weight, collaborative_weight)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			`, experiment.ID, variant.Name, i, variant.Weight,
+				variant.DistanceWeight, variant.TagsWeight, variant.FameWeight, variant.CollaborativeWeight)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &experiment, nil
+}
+
+// Experiments lists every experiment, latest first.
+func (s *Store) Experiments(ctx context.Context) ([]Experiment, error) {
+	return s.queryExperiments(ctx, "TRUE")
+}
+
+// RunningExperiment returns the running experiment, or nil when there is
+// none.
+func (s *Store) RunningExperiment(ctx context.Context) (*Experiment, error) {
+	experiments, err := s.queryExperiments(ctx, "e.ended_at IS NULL")
+	if err != nil || len(experiments) == 0 {
+		return nil, err
+	}
+	return &experiments[0], nil
+}
+
+// Experiment returns an experiment by ID.
+func (s *Store) Experiment(ctx context.Context, experimentID int) (*Experiment, error) {
+	experiments, err := s.queryExperiments(ctx, "e.id = $1", experimentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(experiments) == 0 {
+		return nil, ErrNotFound
+	}
+	return &experiments[0], nil
+}
+
+func (s *Store) queryExperiments(ctx context.Context, where string, args ...interface{}) ([]Experiment, error) {
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT e.id, e.name, e.description, e.started_at, e.ended_at
+		FROM experiments e
+		WHERE `+where+`
+		ORDER BY e.started_at DESC, e.id DESC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	experiments := []Experiment{}
+	ids := []int{}
+	for rows.Next() {
+		var experiment Experiment
+		var endedAt sql.NullTime
+		if err := rows.Scan(&experiment.ID, &experiment.Name, &experiment.Description, &experiment.StartedAt, &endedAt); err != nil {
+			return nil, err
+		}
+		if endedAt.Valid {
+			experiment.EndedAt = &endedAt.Time
+		}
+		experiment.Variants = []ExperimentVariant{}
+		experiments = append(experiments, experiment)
+		ids = append(ids, experiment.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	variantRows, err := s.q.QueryContext(ctx, `
+		SELECT experiment_id, name, weight, distance_weight, tags_weight, fame_weight, collaborative_weight
+		FROM experiment_variants
+		WHERE experiment_id = ANY($1)
+		ORDER BY experiment_id, position
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer variantRows.Close()
+
+	byID := make(map[int]*Experiment, len(experiments))
+	for i := range experiments {
+		byID[experiments[i].ID] = &experiments[i]
+	}
+	for variantRows.Next() {
+		var experimentID int
+		var variant ExperimentVariant
+		var distance, tags, fame, collaborative sql.NullFloat64
+		if err := variantRows.Scan(&experimentID, &variant.Name, &variant.Weight, &distance, &tags, &fame, &collaborative); err != nil {
+			return nil, err
+		}
+		variant.DistanceWeight = nullFloat(distance)
+		variant.TagsWeight = nullFloat(tags)
+		variant.FameWeight = nullFloat(fame)
+		variant.CollaborativeWeight = nullFloat(collaborative)
+		experiment := byID[experimentID]
+		experiment.Variants = append(experiment.Variants, variant)
+	}
+	return experiments, variantRows.Err()
+}
+
+func nullFloat(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+	return &f.Float64
+}
+
+// EndExperiment stops a running experiment. Its impressions and outcomes
+// are kept for the report, but no new ones are recorded.
+func (s *Store) EndExperiment(ctx context.Context, experimentID int) error {
+	result, err := s.q.ExecContext(ctx, `
+		UPDATE experiments SET ended_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND ended_at IS NULL
+	`, experimentID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordImpressions records that shownIDs were suggested, in order from
+// firstPosition, to a viewer in variant. Profiles already suggested to the
+// viewer during the experiment keep their first impression.
+func (s *Store) RecordImpressions(ctx context.Context, experimentID int, variant string, viewerID int, shownIDs []int, firstPosition int) error {
+	_, err := s.q.ExecContext(ctx, `
+		INSERT INTO experiment_impressions (experiment_id, variant, viewer_id, shown_id, position)
+		SELECT $1, $2, $3, shown.id, $5 + shown.ord - 1
+		FROM UNNEST($4::int[]) WITH ORDINALITY AS shown(id, ord)
+		ON CONFLICT DO NOTHING
+	`, experimentID, variant, viewerID, pq.Array(shownIDs), firstPosition)
+	return err
+}
+
+// RecordOutcome attributes what viewerID did with shownID to the running
+// experiment, when it suggested shownID to them.
+func (s *Store) RecordOutcome(ctx context.Context, viewerID, shownID int, outcome string) error {
+	_, err := s.q.ExecContext(ctx, `
+		INSERT INTO experiment_outcomes (experiment_id, viewer_id, shown_id, outcome)
+		SELECT i.experiment_id, i.viewer_id, i.shown_id, $3
+		FROM experiment_impressions i
+		JOIN experiments e ON e.id = i.experiment_id AND e.ended_at IS NULL
+		WHERE i.viewer_id = $1 AND i.shown_id = $2
+		ON CONFLICT DO NOTHING
+	`, viewerID, shownID, outcome)
+	return err
+}
+
+// ExperimentReport counts impressions and outcomes per variant, in the
+// variants' order.
+func (s *Store) ExperimentReport(ctx context.Context, experimentID int) ([]VariantReport, error) {
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT v.name, v.weight,
+		       COUNT(DISTINCT i.viewer_id), COUNT(i.viewer_id),
+		       COUNT(*) FILTER (WHERE o.liked), COUNT(*) FILTER (WHERE o.passed),
+		       COUNT(*) FILTER (WHERE o.matched), COUNT(*) FILTER (WHERE o.messaged)
+		FROM experiment_variants v
+		LEFT JOIN experiment_impressions i ON i.experiment_id = v.experiment_id AND i.variant = v.name
+		LEFT JOIN LATERAL (
+			SELECT BOOL_OR(outcome = 'like') AS liked, BOOL_OR(outcome = 'pass') AS passed,
+			       BOOL_OR(outcome = 'match') AS matched, BOOL_OR(outcome = 'message') AS messaged
+			FROM experiment_outcomes
+			WHERE experiment_id = i.experiment_id AND viewer_id = i.viewer_id AND shown_id = i.shown_id
+		) o ON TRUE
+		WHERE v.experiment_id = $1
+		GROUP BY v.name, v.weight, v.position
+		ORDER BY v.position
+	`, experimentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []VariantReport{}
+	for rows.Next() {
+		var report VariantReport
+		if err := rows.Scan(&report.Variant, &report.Weight, &report.Viewers, &report.Impressions,
+			&report.Likes, &report.Passes, &report.Matches, &report.Messages); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
@@ -0,0 +1,350 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Parameters of the fame rating, shown with FameFormula by the explain
+// endpoint.
+const (
+	// FameHalfLife is the age at which a like or view counts half.
+	FameHalfLife = 30 * 24 * time.Hour
+	// FamePriorViews and FamePriorRate smooth the like rate: every profile
+	// starts as if FamePriorViews views had produced FamePriorRate likes per
+	// view, so the first few views barely move it.
+	FamePriorViews = 10.0
+	FamePriorRate  = 0.2
+	// FameDamping is the PageRank damping factor of desirability.
+	FameDamping = 0.85
+
+	// Desirability is iterated until no rank moves by more than
+	// fameTolerance, or for at most fameMaxIterations steps.
+	fameMaxIterations = 200
+	fameTolerance     = 1e-7
+
+	// fameLockKey is the advisory lock held while recomputing.
+	fameLockKey = 4242002
+)
+
+// FameFormula describes how fame ratings are computed.
+const FameFormula = "fame = 100 × min(1, (Σ likes decay × liker desirability + prior_rate × prior_views) / (Σ views decay + prior_views)), " +
+	"decay = 0.5^(age / half_life); desirability is a PageRank over decayed likes, each liker's weight split across the profiles they liked, averaging 1"
+
+// FameScore is the fame rating of a user and what it was computed from.
+type FameScore struct {
+	UserID int
+	// Rating is the 0-100 fame rating stored in users.fame_rating.
+	Rating float64
+	// Desirability is the user's PageRank in the like graph; 1 is average.
+	Desirability float64
+	// WeightedLikes sums the decayed likes received, each weighted by the
+	// liker's desirability; DecayedViews sums the decayed views received.
+	WeightedLikes float64
+	DecayedViews  float64
+	Likes         int
+	Views         int
+	ComputedAt    time.Time
+}
+
+// fameLikes are the likes from one user to another, and their sum of
+// decays 0.5^(age / FameHalfLife).
+type fameLikes struct {
+	from, to int
+	count    int
+	decayed  float64
+}
+
+// fameViews are the views a user received, and their sum of decays.
+type fameViews struct {
+	count   int
+	decayed float64
+}
+
+// computeFame rates every user in userIDs from the likes and views they
+// received, aggregated per liker and per viewed user. See FameFormula.
+func computeFame(userIDs []int, likes []fameLikes, views map[int]fameViews, now time.Time) map[int]*FameScore {
+	scores := make(map[int]*FameScore, len(userIDs))
+	for _, id := range userIDs {
+		scores[id] = &FameScore{UserID: id, Desirability: 1, ComputedAt: now}
+	}
+
+	// Desirability: PageRank where a like passes on the liker's rank, split
+	// across their likes by decayed weight. Users who liked no one hand
+	// nothing on, so ranks are rescaled to average 1 after each iteration.
+	outWeight := make(map[int]float64)
+	for _, like := range likes {
+		outWeight[like.from] += like.decayed
+	}
+	for iteration := 0; iteration < fameMaxIterations; iteration++ {
+		previous := make(map[int]float64, len(scores))
+		for id, score := range scores {
+			previous[id] = score.Desirability
+		}
+		next := make(map[int]float64, len(scores))
+		for _, like := range likes {
+			liker, liked := scores[like.from], scores[like.to]
+			if liker == nil || liked == nil || outWeight[like.from] == 0 {
+				continue
+			}
+			next[like.to] += liker.Desirability * like.decayed / outWeight[like.from]
+		}
+		total := 0.0
+		for id, score := range scores {
+			score.Desirability = (1 - FameDamping) + FameDamping*next[id]
+			total += score.Desirability
+		}
+		change := 0.0
+		for id, score := range scores {
+			rank := score.Desirability * float64(len(scores)) / total
+			change = math.Max(change, math.Abs(rank-previous[id]))
+			score.Desirability = rank
+		}
+		if change < fameTolerance {
+			break
+		}
+	}
+
+	for _, like := range likes {
+		liker, liked := scores[like.from], scores[like.to]
+		if liker == nil || liked == nil {
+			continue
+		}
+		liked.WeightedLikes += like.decayed * liker.Desirability
+		liked.Likes += like.count
+	}
+	for id, received := range views {
+		if viewed := scores[id]; viewed != nil {
+			viewed.DecayedViews += received.decayed
+			viewed.Views += received.count
+		}
+	}
+
+	for _, score := range scores {
+		rate := (score.WeightedLikes + FamePriorRate*FamePriorViews) / (score.DecayedViews + FamePriorViews)
+		score.Rating = math.Round(math.Min(1, rate)*10000) / 100
+	}
+	return scores
+}
+
+// RecomputeFameRatings recalculates every user's fame rating (see
+// FameFormula). It stores the components in fame_scores and logs ratings
+// that changed in fame_rating_history. It returns the number of users
+// rated, or 0 while another recomputation holds the lock.
+func (s *Store) RecomputeFameRatings(ctx context.Context) (int, error) {
+	count := 0
+	err := s.WithTx(ctx, func(tx *Store) error {
+		var locked bool
+		if err := tx.q.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", fameLockKey).Scan(&locked); err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		var now time.Time
+		if err := tx.q.QueryRowContext(ctx, "SELECT NOW()::timestamp").Scan(&now); err != nil {
+			return err
+		}
+
+		userIDs, err := tx.queryInts(ctx, "SELECT id FROM users")
+		if err != nil {
+			return err
+		}
+		likes, err := tx.queryFameLikes(ctx)
+		if err != nil {
+			return err
+		}
+		views, err := tx.queryFameViews(ctx)
+		if err != nil {
+			return err
+		}
+
+		scores := computeFame(userIDs, likes, views, now)
+
+		ids := make([]int64, 0, len(scores))
+		ratings := make([]float64, 0, len(scores))
+		desirability := make([]float64, 0, len(scores))
+		weightedLikes := make([]float64, 0, len(scores))
+		decayedViews := make([]float64, 0, len(scores))
+		likeCounts := make([]int64, 0, len(scores))
+		viewCounts := make([]int64, 0, len(scores))
+		for _, score := range scores {
+			ids = append(ids, int64(score.UserID))
+			ratings = append(ratings, score.Rating)
+			desirability = append(desirability, score.Desirability)
+			weightedLikes = append(weightedLikes, score.WeightedLikes)
+			decayedViews = append(decayedViews, score.DecayedViews)
+			likeCounts = append(likeCounts, int64(score.Likes))
+			viewCounts = append(viewCounts, int64(score.Views))
+		}
+
+		_, err = tx.q.ExecContext(ctx, `
+			CREATE TEMPORARY TABLE new_fame ON COMMIT DROP AS
+			SELECT * FROM UNNEST($1::int[], $2::float8[], $3::float8[], $4::float8[], $5::float8[], $6::int[], $7::int[])
+				AS f(user_id, rating, desirability, weighted_likes, decayed_views, likes, views)
+		`, pq.Array(ids), pq.Array(ratings), pq.Array(desirability), pq.Array(weightedLikes),
+			pq.Array(decayedViews), pq.Array(likeCounts), pq.Array(viewCounts))
+		if err != nil {
+			return err
+		}
+
+		statements := []string{`
+			INSERT INTO fame_rating_history (user_id, rating, computed_at)
+			SELECT f.user_id, f.rating, $1
+			FROM new_fame f
+			JOIN users u ON u.id = f.user_id
+			WHERE u.fame_rating IS DISTINCT FROM f.rating::DECIMAL(5,2)
+		`, `
+			UPDATE users u SET fame_rating = f.rating
+			FROM new_fame f
+			WHERE u.id = f.user_id AND u.fame_rating IS DISTINCT FROM f.rating::DECIMAL(5,2)
+		`, `
+			INSERT INTO fame_scores (user_id, rating, desirability, weighted_likes, decayed_views, likes, views, computed_at)
+			SELECT user_id, rating, desirability, weighted_likes, decayed_views, likes, views, $1
+			FROM new_fame
+			ON CONFLICT (user_id) DO UPDATE SET
+				rating = EXCLUDED.rating,
+				desirability = EXCLUDED.desirability,
+				weighted_likes = EXCLUDED.weighted_likes,
+				decayed_views = EXCLUDED.decayed_views,
+				likes = EXCLUDED.likes,
+				views = EXCLUDED.views,
+				computed_at = EXCLUDED.computed_at
+		`}
+		for _, statement := range statements {
+			if _, err := tx.q.ExecContext(ctx, statement, now); err != nil {
+				return err
+			}
+		}
+
+		count = len(scores)
+		return nil
+	})
+	return count, err
+}
+
+// FameScore returns the latest fame computation of a user, or ErrNotFound
+// when the job has not rated them yet.
+func (s *Store) FameScore(ctx context.Context, userID int) (*FameScore, error) {
+	score := FameScore{UserID: userID}
+	err := s.q.QueryRowContext(ctx, `
+		SELECT rating, desirability, weighted_likes, decayed_views, likes, views, computed_at
+		FROM fame_scores WHERE user_id = $1
+	`, userID).Scan(&score.Rating, &score.Desirability, &score.WeightedLikes, &score.DecayedViews,
+		&score.Likes, &score.Views, &score.ComputedAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &score, nil
+}
+
+func (s *Store) queryInts(ctx context.Context, query string) ([]int, error) {
+	rows, err := s.q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []int
+	for rows.Next() {
+		var value int
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// fameDecaySQL is the decay of an interaction at column at, as of the
+// start of the transaction, with the half-life bound to $1.
+func fameDecaySQL(at string) string {
+	return fmt.Sprintf("POWER(0.5, GREATEST(EXTRACT(EPOCH FROM NOW()::timestamp - %s), 0)::float8 / $1)", at)
+}
+
+// queryFameLikes aggregates profile_likes per liker and liked user.
+func (s *Store) queryFameLikes(ctx context.Context) ([]fameLikes, error) {
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT liker_id, liked_id, COUNT(*), SUM(`+fameDecaySQL("liked_at")+`)
+		FROM profile_likes
+		GROUP BY liker_id, liked_id
+	`, FameHalfLife.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var likes []fameLikes
+	for rows.Next() {
+		var l fameLikes
+		if err := rows.Scan(&l.from, &l.to, &l.count, &l.decayed); err != nil {
+			return nil, err
+		}
+		likes = append(likes, l)
+	}
+	return likes, rows.Err()
+}
+
+// queryFameViews aggregates profile_views per viewed user.
+func (s *Store) queryFameViews(ctx context.Context) (map[int]fameViews, error) {
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT viewed_id, COUNT(*), SUM(`+fameDecaySQL("viewed_at")+`)
+		FROM profile_views
+		GROUP BY viewed_id
+	`, FameHalfLife.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	views := make(map[int]fameViews)
+	for rows.Next() {
+		var userID int
+		var v fameViews
+		if err := rows.Scan(&userID, &v.count, &v.decayed); err != nil {
+			return nil, err
+		}
+		views[userID] = v
+	}
+	return views, rows.Err()
+}
+
+// FameHistoryEntry is a fame rating a user had from ComputedAt on.
+type FameHistoryEntry struct {
+	Rating     float64
+	ComputedAt time.Time
+}
+
+// FameHistory returns the last limit changes of a user's fame rating, most
+// recent first.
+func (s *Store) FameHistory(ctx context.Context, userID, limit int) ([]FameHistoryEntry, error) {
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT rating, computed_at FROM fame_rating_history
+		WHERE user_id = $1
+		ORDER BY computed_at DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []FameHistoryEntry
+	for rows.Next() {
+		var entry FameHistoryEntry
+		if err := rows.Scan(&entry.Rating, &entry.ComputedAt); err != nil {
+			return nil, err
+		}
+		history = append(history, entry)
+	}
+	return history, rows.Err()
+}
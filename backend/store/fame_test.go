@@ -0,0 +1,94 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeFamePrior(t *testing.T) {
+	now := time.Date(2026, time.October, 19, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		views fameViews
+		want  float64
+	}{
+		{"no views", fameViews{}, 100 * FamePriorRate},
+		{"a few views without likes", fameViews{count: 2, decayed: 2}, 100 * FamePriorRate * FamePriorViews / (FamePriorViews + 2)},
+		{"many views without likes", fameViews{count: 90, decayed: 90}, 100 * FamePriorRate * FamePriorViews / (FamePriorViews + 90)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores := computeFame([]int{1}, nil, map[int]fameViews{1: tt.views}, now)
+			assert.InDelta(t, tt.want, scores[1].Rating, 0.005)
+			assert.Equal(t, tt.views.count, scores[1].Views)
+			assert.Equal(t, now, scores[1].ComputedAt)
+		})
+	}
+}
+
+func TestComputeFameDecay(t *testing.T) {
+	// User 1 likes 2 now and 3 one half-life ago; both got the same views,
+	// one of them one half-life ago as well.
+	likes := []fameLikes{
+		{from: 1, to: 2, count: 1, decayed: 1},
+		{from: 1, to: 3, count: 1, decayed: 0.5},
+	}
+	views := map[int]fameViews{
+		2: {count: 2, decayed: 1.5},
+		3: {count: 2, decayed: 1.5},
+	}
+	scores := computeFame([]int{1, 2, 3}, likes, views, time.Now())
+
+	assert.InDelta(t, 2*scores[3].WeightedLikes, scores[2].WeightedLikes, 1e-9)
+	assert.Equal(t, 1, scores[2].Likes)
+	assert.Equal(t, 1, scores[3].Likes)
+	assert.Equal(t, 1.5, scores[2].DecayedViews)
+	assert.Equal(t, 2, scores[2].Views)
+	assert.Greater(t, scores[2].Rating, scores[3].Rating)
+}
+
+func TestComputeFameDesirabilityConverges(t *testing.T) {
+	userIDs := []int{1, 2, 3, 4, 5, 6}
+	likes := []fameLikes{
+		{from: 1, to: 4, count: 1, decayed: 1},
+		{from: 2, to: 4, count: 1, decayed: 1},
+		{from: 3, to: 4, count: 1, decayed: 0.5},
+		{from: 4, to: 5, count: 1, decayed: 1},
+		{from: 2, to: 6, count: 1, decayed: 1},
+		{from: 5, to: 1, count: 1, decayed: 0.25},
+	}
+	scores := computeFame(userIDs, likes, nil, time.Now())
+
+	total := 0.0
+	for _, id := range userIDs {
+		total += scores[id].Desirability
+	}
+	assert.InDelta(t, float64(len(userIDs)), total, 1e-9, "desirability averages 1")
+
+	// One more PageRank step leaves the ranks where they are.
+	outWeight := map[int]float64{}
+	for _, like := range likes {
+		outWeight[like.from] += like.decayed
+	}
+	next := map[int]float64{}
+	for _, like := range likes {
+		next[like.to] += scores[like.from].Desirability * like.decayed / outWeight[like.from]
+	}
+	stepTotal := 0.0
+	step := map[int]float64{}
+	for _, id := range userIDs {
+		step[id] = (1 - FameDamping) + FameDamping*next[id]
+		stepTotal += step[id]
+	}
+	for _, id := range userIDs {
+		require.InDelta(t, scores[id].Desirability, step[id]*float64(len(userIDs))/stepTotal, 1e-5, "user %d", id)
+	}
+
+	// A like from the most liked user is worth more than one from a user
+	// nobody likes.
+	assert.Greater(t, scores[5].Desirability, scores[6].Desirability)
+	assert.Greater(t, scores[5].WeightedLikes, scores[6].WeightedLikes)
+}
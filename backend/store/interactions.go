@@ -0,0 +1,33 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// RecordView stores that viewer looked at viewed's profile. A repeated view
+// keeps the first one.
+func (s *Store) RecordView(ctx context.Context, viewerID, viewedID int, at time.Time) error {
+	_, err := s.q.ExecContext(ctx, `
+		INSERT INTO profile_views (viewer_id, viewed_id, viewed_at) VALUES ($1, $2, $3)
+		ON CONFLICT (viewer_id, viewed_id) DO NOTHING
+	`, viewerID, viewedID, at)
+	return err
+}
+
+// AddLike stores that liker likes liked. Liking twice is a no-op.
+func (s *Store) AddLike(ctx context.Context, likerID, likedID int, at time.Time) error {
+	_, err := s.q.ExecContext(ctx, `
+		INSERT INTO profile_likes (liker_id, liked_id, liked_at) VALUES ($1, $2, $3)
+		ON CONFLICT (liker_id, liked_id) DO NOTHING
+	`, likerID, likedID, at)
+	return err
+}
+
+// AddMessage stores a chat message sent at the given time.
+func (s *Store) AddMessage(ctx context.Context, senderID, receiverID int, content string, at time.Time) error {
+	_, err := s.q.ExecContext(ctx, `
+		INSERT INTO messages (sender_id, receiver_id, content, created_at) VALUES ($1, $2, $3, $4)
+	`, senderID, receiverID, content, at)
+	return err
+}
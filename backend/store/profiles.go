@@ -0,0 +1,106 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// NewUser holds the fields needed to create an account with a filled-in
+// profile.
+type NewUser struct {
+	Username     string
+	Email        string
+	PasswordHash string
+	FirstName    string
+	LastName     string
+	// Gender and InterestedIn are names from the genders table.
+	Gender       string
+	InterestedIn []string
+	Birthday     time.Time
+	Bio          string
+	Verified     bool
+}
+
+func (s *Store) CreateUser(ctx context.Context, user NewUser) (int, error) {
+	var id int
+	err := s.q.QueryRowContext(ctx, `
+		WITH new_user AS (
+			INSERT INTO users (username, email, password_hash, first_name, last_name,
+			                   gender_id, birthday, bio, verified)
+			VALUES ($1, $2, $3, $4, $5, (SELECT id FROM genders WHERE name = $6), $7, $8, $9)
+			RETURNING id
+		), interests AS (
+			INSERT INTO user_interested_in (user_id, gender_id)
+			SELECT new_user.id, g.id FROM new_user, genders g WHERE g.name = ANY($10)
+		)
+		SELECT id FROM new_user
+	`, user.Username, user.Email, user.PasswordHash, user.FirstName, user.LastName,
+		user.Gender, user.Birthday, user.Bio, user.Verified, pq.Array(user.InterestedIn)).Scan(&id)
+	return id, err
+}
+
+// SetLocation stores the current position of a user.
+func (s *Store) SetLocation(ctx context.Context, userID int, lat, lon float64) error {
+	_, err := s.q.ExecContext(ctx, `
+		INSERT INTO user_locations (user_id, lat, lon, updated_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET lat = EXCLUDED.lat, lon = EXCLUDED.lon, updated_at = NOW()
+	`, userID, lat, lon)
+	return err
+}
+
+// AddUserTags attaches tags, or the tags they are aliases of, to a user,
+// creating the tags that do not exist.
+func (s *Store) AddUserTags(ctx context.Context, userID int, names []string) error {
+	for _, name := range names {
+		// One statement, so that DeleteUnusedTags cannot delete the tag
+		// between the upsert and the insert.
+		_, err := s.q.ExecContext(ctx, `
+			WITH tag AS (
+				INSERT INTO tags (name) VALUES (canonical_tag_name($2))
+				ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+				RETURNING id
+			)
+			INSERT INTO user_tags (user_id, tag_id) SELECT $1, id FROM tag
+			ON CONFLICT DO NOTHING
+		`, userID, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddImage stores an image path for a user. A profile picture also becomes
+// the user's avatar.
+func (s *Store) AddImage(ctx context.Context, userID int, path string, profilePicture bool) error {
+	_, err := s.q.ExecContext(ctx, `
+		INSERT INTO user_images (user_id, path, is_profile_picture) VALUES ($1, $2, $3)
+	`, userID, path, profilePicture)
+	if err != nil || !profilePicture {
+		return err
+	}
+	_, err = s.q.ExecContext(ctx, "UPDATE users SET avatar_url = $1 WHERE id = $2", path, userID)
+	return err
+}
+
+// CountUsersByEmailDomain counts the accounts whose email ends with
+// "@"+domain.
+func (s *Store) CountUsersByEmailDomain(ctx context.Context, domain string) (int, error) {
+	var count int
+	err := s.q.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE email LIKE '%@' || $1", domain).Scan(&count)
+	return count, err
+}
+
+// DeleteUsersByEmailDomain deletes the accounts whose email ends with
+// "@"+domain, with everything that references them.
+func (s *Store) DeleteUsersByEmailDomain(ctx context.Context, domain string) (int, error) {
+	result, err := s.q.ExecContext(ctx, "DELETE FROM users WHERE email LIKE '%@' || $1", domain)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	return int(affected), err
+}
@@ -0,0 +1,287 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// recommendationsPerUser is the number of candidates kept per user.
+	recommendationsPerUser = 200
+
+	// maxLikesPerUser bounds the likes of one user that count towards
+	// similarities, keeping the job linear in the number of users. The most
+	// recent ones are used, both for co-occurrences and for liker counts.
+	maxLikesPerUser = 200
+
+	// similarProfilesPerProfile is the number of most similar profiles kept
+	// per profile in profile_similarities.
+	similarProfilesPerProfile = 100
+
+	// recommendationsBatchSize is the number of users whose likes are loaded
+	// and scored at once.
+	recommendationsBatchSize = 500
+
+	// recommendationsLockKey is the advisory lock held while recomputing, so
+	// that several servers running the job do not overlap.
+	recommendationsLockKey = 4242001
+)
+
+// recommendation is the collaborative-filtering score of a candidate for a
+// user, from 0 to 100 where 100 is that user's best candidate.
+type recommendation struct {
+	CandidateID int
+	Score       float64
+}
+
+// collaborativeRecommendations scores candidates by item-item collaborative
+// filtering: a user's candidates are the profiles most similar to the ones
+// they liked, scored by the sum of those similarities. liked maps users to
+// the profiles they liked; similar maps a profile to its similar profiles
+// and their similarity, as stored in profile_similarities. Candidates the
+// user already liked are left out.
+func collaborativeRecommendations(liked map[int][]int, similar map[int]map[int]float64, perUser int) map[int][]recommendation {
+	recommendations := make(map[int][]recommendation, len(liked))
+	for userID, items := range liked {
+		alreadyLiked := make(map[int]bool, len(items))
+		for _, item := range items {
+			alreadyLiked[item] = true
+		}
+
+		scores := make(map[int]float64)
+		for _, item := range items {
+			for candidate, similarity := range similar[item] {
+				if candidate == userID || alreadyLiked[candidate] {
+					continue
+				}
+				scores[candidate] += similarity
+			}
+		}
+		if len(scores) == 0 {
+			continue
+		}
+
+		ranked := make([]recommendation, 0, len(scores))
+		for candidate, score := range scores {
+			ranked = append(ranked, recommendation{CandidateID: candidate, Score: score})
+		}
+		sort.Slice(ranked, func(i, j int) bool {
+			if ranked[i].Score != ranked[j].Score {
+				return ranked[i].Score > ranked[j].Score
+			}
+			return ranked[i].CandidateID < ranked[j].CandidateID
+		})
+		if len(ranked) > perUser {
+			ranked = ranked[:perUser]
+		}
+		best := ranked[0].Score
+		for i := range ranked {
+			ranked[i].Score = ranked[i].Score / best * 100
+		}
+		recommendations[userID] = ranked
+	}
+	return recommendations
+}
+
+// RecomputeRecommendations rebuilds profile_similarities and then
+// user_recommendations from profile_likes, and returns the number of users
+// who got recommendations. It returns 0 without doing anything while another
+// recomputation holds the lock.
+func (s *Store) RecomputeRecommendations(ctx context.Context) (int, error) {
+	users := 0
+	err := s.WithTx(ctx, func(tx *Store) error {
+		var locked bool
+		if err := tx.q.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", recommendationsLockKey).Scan(&locked); err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+
+		if err := tx.recomputeSimilarities(ctx); err != nil {
+			return err
+		}
+		if _, err := tx.q.ExecContext(ctx, "DELETE FROM user_recommendations"); err != nil {
+			return err
+		}
+
+		lastLikerID := 0
+		for {
+			likerIDs, err := tx.nextLikers(ctx, lastLikerID, recommendationsBatchSize)
+			if err != nil {
+				return err
+			}
+			if len(likerIDs) == 0 {
+				return nil
+			}
+			lastLikerID = likerIDs[len(likerIDs)-1]
+
+			liked, similar, err := tx.likesAndSimilarities(ctx, likerIDs)
+			if err != nil {
+				return err
+			}
+			recommendations := collaborativeRecommendations(liked, similar, recommendationsPerUser)
+			if err := tx.insertRecommendations(ctx, recommendations); err != nil {
+				return err
+			}
+			users += len(recommendations)
+		}
+	})
+	return users, err
+}
+
+// recomputeSimilarities rebuilds profile_similarities: two profiles are
+// similar when the same users liked them, by cosine similarity of their
+// likers over each user's maxLikesPerUser most recent likes.
+func (s *Store) recomputeSimilarities(ctx context.Context) error {
+	if _, err := s.q.ExecContext(ctx, "DELETE FROM profile_similarities"); err != nil {
+		return err
+	}
+	_, err := s.q.ExecContext(ctx, `
+		WITH recent AS (
+			SELECT liker_id, liked_id
+			FROM (
+				SELECT liker_id, liked_id,
+				       ROW_NUMBER() OVER (PARTITION BY liker_id ORDER BY liked_at DESC, liked_id) AS rank
+				FROM profile_likes
+			) l
+			WHERE rank <= $1
+		),
+		likers AS (
+			SELECT liked_id, COUNT(*) AS count FROM recent GROUP BY liked_id
+		),
+		pairs AS (
+			SELECT a.liked_id AS profile_id, b.liked_id AS similar_id,
+			       COUNT(*) / SQRT(la.count * lb.count) AS similarity
+			FROM recent a
+			JOIN recent b ON b.liker_id = a.liker_id AND b.liked_id <> a.liked_id
+			JOIN likers la ON la.liked_id = a.liked_id
+			JOIN likers lb ON lb.liked_id = b.liked_id
+			GROUP BY a.liked_id, b.liked_id, la.count, lb.count
+		)
+		INSERT INTO profile_similarities (profile_id, similar_id, similarity)
+		SELECT profile_id, similar_id, similarity
+		FROM (
+			SELECT profile_id, similar_id, similarity,
+			       ROW_NUMBER() OVER (PARTITION BY profile_id ORDER BY similarity DESC, similar_id) AS rank
+			FROM pairs
+		) p
+		WHERE rank <= $2
+	`, maxLikesPerUser, similarProfilesPerProfile)
+	return err
+}
+
+// nextLikers returns up to limit users who liked someone, with IDs above
+// afterID, in ID order.
+func (s *Store) nextLikers(ctx context.Context, afterID, limit int) ([]int, error) {
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT DISTINCT liker_id FROM profile_likes WHERE liker_id > $1 ORDER BY liker_id LIMIT $2
+	`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// likesAndSimilarities loads the likes of likerIDs and the similar profiles
+// of everything they liked, in the shape collaborativeRecommendations takes.
+func (s *Store) likesAndSimilarities(ctx context.Context, likerIDs []int) (map[int][]int, map[int]map[int]float64, error) {
+	ids := make([]int64, len(likerIDs))
+	for i, id := range likerIDs {
+		ids[i] = int64(id)
+	}
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT pl.liker_id, pl.liked_id, s.similar_id, s.similarity
+		FROM profile_likes pl
+		LEFT JOIN profile_similarities s ON s.profile_id = pl.liked_id
+		WHERE pl.liker_id = ANY($1)
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	liked := make(map[int][]int)
+	similar := make(map[int]map[int]float64)
+	seen := make(map[[2]int]bool)
+	for rows.Next() {
+		var likerID, likedID int
+		var similarID sql.NullInt64
+		var similarity sql.NullFloat64
+		if err := rows.Scan(&likerID, &likedID, &similarID, &similarity); err != nil {
+			return nil, nil, err
+		}
+		if like := [2]int{likerID, likedID}; !seen[like] {
+			seen[like] = true
+			liked[likerID] = append(liked[likerID], likedID)
+		}
+		if !similarID.Valid {
+			continue
+		}
+		if similar[likedID] == nil {
+			similar[likedID] = make(map[int]float64)
+		}
+		similar[likedID][int(similarID.Int64)] = similarity.Float64
+	}
+	return liked, similar, rows.Err()
+}
+
+func (s *Store) insertRecommendations(ctx context.Context, recommendations map[int][]recommendation) error {
+	for userID, ranked := range recommendations {
+		candidates := make([]int64, len(ranked))
+		scores := make([]float64, len(ranked))
+		for i, r := range ranked {
+			candidates[i] = int64(r.CandidateID)
+			scores[i] = r.Score
+		}
+		_, err := s.q.ExecContext(ctx, `
+			INSERT INTO user_recommendations (user_id, candidate_id, score)
+			SELECT $1, candidate_id, score
+			FROM UNNEST($2::int[], $3::float8[]) AS r(candidate_id, score)
+		`, userID, pq.Array(candidates), pq.Array(scores))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecommendationScores returns the collaborative-filtering scores of a
+// user's candidates by candidate ID, or nil when the user has none (no likes
+// yet, or the job has not run since).
+func (s *Store) RecommendationScores(ctx context.Context, userID int) (map[int]float64, error) {
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT candidate_id, score FROM user_recommendations WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scores map[int]float64
+	for rows.Next() {
+		var candidateID int
+		var score float64
+		if err := rows.Scan(&candidateID, &score); err != nil {
+			return nil, err
+		}
+		if scores == nil {
+			scores = make(map[int]float64)
+		}
+		scores[candidateID] = score
+	}
+	return scores, rows.Err()
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollaborativeRecommendations(t *testing.T) {
+	tests := []struct {
+		name    string
+		liked   map[int][]int
+		similar map[int]map[int]float64
+		perUser int
+		want    map[int][]recommendation
+	}{
+		{
+			name:    "no similar profiles",
+			liked:   map[int][]int{1: {10}},
+			similar: map[int]map[int]float64{},
+			perUser: 10,
+			want:    map[int][]recommendation{},
+		},
+		{
+			name:  "similarities add up and the best candidate scores 100",
+			liked: map[int][]int{1: {10, 11}},
+			similar: map[int]map[int]float64{
+				10: {20: 0.5, 21: 0.2},
+				11: {20: 0.3, 22: 0.4},
+			},
+			perUser: 10,
+			want: map[int][]recommendation{
+				1: {{CandidateID: 20, Score: 100}, {CandidateID: 22, Score: 50}, {CandidateID: 21, Score: 25}},
+			},
+		},
+		{
+			name:  "already liked profiles and the user are left out",
+			liked: map[int][]int{1: {10, 11}},
+			similar: map[int]map[int]float64{
+				10: {11: 0.9, 1: 0.8, 20: 0.4},
+			},
+			perUser: 10,
+			want: map[int][]recommendation{
+				1: {{CandidateID: 20, Score: 100}},
+			},
+		},
+		{
+			name:  "ties are broken by candidate ID and the list is cut at perUser",
+			liked: map[int][]int{1: {10}},
+			similar: map[int]map[int]float64{
+				10: {23: 0.5, 21: 0.5, 22: 0.5},
+			},
+			perUser: 2,
+			want: map[int][]recommendation{
+				1: {{CandidateID: 21, Score: 100}, {CandidateID: 22, Score: 100}},
+			},
+		},
+		{
+			name:  "each user is normalized on their own best candidate",
+			liked: map[int][]int{1: {10}, 2: {11}},
+			similar: map[int]map[int]float64{
+				10: {20: 0.8, 21: 0.2},
+				11: {20: 0.1},
+			},
+			perUser: 10,
+			want: map[int][]recommendation{
+				1: {{CandidateID: 20, Score: 100}, {CandidateID: 21, Score: 25}},
+				2: {{CandidateID: 20, Score: 100}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := collaborativeRecommendations(tt.liked, tt.similar, tt.perUser)
+			assert.Equal(t, len(tt.want), len(got))
+			for userID, want := range tt.want {
+				if assert.Len(t, got[userID], len(want), "user %d", userID) {
+					for i := range want {
+						assert.Equal(t, want[i].CandidateID, got[userID][i].CandidateID, "user %d rank %d", userID, i)
+						assert.InDelta(t, want[i].Score, got[userID][i].Score, 1e-9, "user %d rank %d", userID, i)
+					}
+				}
+			}
+		})
+	}
+}
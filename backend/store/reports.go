@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type Report struct {
+	ID               int        `json:"id"`
+	ReporterID       int        `json:"reporter_id"`
+	ReporterUsername string     `json:"reporter_username"`
+	ReportedID       int        `json:"reported_id"`
+	ReportedUsername string     `json:"reported_username"`
+	Reason           string     `json:"reason"`
+	CreatedAt        time.Time  `json:"created_at"`
+	ResolvedAt       *time.Time `json:"resolved_at"`
+	Resolution       *string    `json:"resolution"`
+}
+
+// ListReports returns reports oldest first. Resolved reports are only
+// included when includeResolved is set.
+func (s *Store) ListReports(ctx context.Context, includeResolved bool) ([]Report, error) {
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT r.id, r.reporter_id, reporter.username, r.reported_id, reported.username,
+		       COALESCE(r.reason, ''), r.created_at, r.resolved_at, r.resolution
+		FROM reports r
+		JOIN users reporter ON reporter.id = r.reporter_id
+		JOIN users reported ON reported.id = r.reported_id
+		WHERE $1 OR r.resolved_at IS NULL
+		ORDER BY r.created_at ASC, r.id ASC
+	`, includeResolved)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := []Report{}
+	for rows.Next() {
+		var report Report
+		var resolvedAt sql.NullTime
+		var resolution sql.NullString
+		if err := rows.Scan(&report.ID, &report.ReporterID, &report.ReporterUsername, &report.ReportedID, &report.ReportedUsername,
+			&report.Reason, &report.CreatedAt, &resolvedAt, &resolution); err != nil {
+			return nil, err
+		}
+		if resolvedAt.Valid {
+			report.ResolvedAt = &resolvedAt.Time
+		}
+		if resolution.Valid {
+			report.Resolution = &resolution.String
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// ResolveReport closes a report with a short description of the action taken.
+func (s *Store) ResolveReport(ctx context.Context, reportID int, resolution string) error {
+	result, err := s.q.ExecContext(ctx, `
+		UPDATE reports SET resolved_at = NOW(), resolution = $1 WHERE id = $2
+	`, resolution, reportID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
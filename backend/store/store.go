@@ -0,0 +1,49 @@
+// Package store holds database operations shared by the API server and the
+// command line tools in cmd/.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrNotFound is returned when the requested row does not exist.
+var ErrNotFound = errors.New("not found")
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type Store struct {
+	db *sql.DB
+	q  querier
+}
+
+func New(db *sql.DB) *Store {
+	return &Store{db: db, q: db}
+}
+
+// DB exposes the underlying connection for callers that need a query the
+// store does not provide yet.
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// WithTx runs fn with a store whose operations share one transaction. The
+// transaction is committed when fn returns nil and rolled back otherwise.
+func (s *Store) WithTx(ctx context.Context, fn func(tx *Store) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&Store{db: s.db, q: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrSameTag is returned when merging a tag into itself or into one of its
+// aliases.
+var ErrSameTag = errors.New("a tag cannot be merged into itself")
+
+// TagAlias is an alternative name of a tag, resolved to the tag on write and
+// in the suggestions tag filter.
+type TagAlias struct {
+	Alias string `json:"alias"`
+	Tag   string `json:"tag"`
+}
+
+// DeleteUnusedTags deletes the tags no user has and returns how many were
+// deleted. Tags with aliases are kept so the aliases keep resolving. Tags
+// being attached concurrently are locked by the upsert that returned their
+// ID, and are skipped.
+func (s *Store) DeleteUnusedTags(ctx context.Context) (int, error) {
+	result, err := s.q.ExecContext(ctx, `
+		DELETE FROM tags WHERE id IN (
+			SELECT t.id FROM tags t
+			WHERE NOT EXISTS (SELECT 1 FROM user_tags ut WHERE ut.tag_id = t.id)
+			  AND NOT EXISTS (SELECT 1 FROM tag_aliases a WHERE a.tag_id = t.id)
+			FOR UPDATE SKIP LOCKED
+		)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
+
+// TagAliases lists every alias with the tag it stands for.
+func (s *Store) TagAliases(ctx context.Context) ([]TagAlias, error) {
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT a.alias, t.name FROM tag_aliases a JOIN tags t ON t.id = a.tag_id
+		ORDER BY t.name, a.alias
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	aliases := []TagAlias{}
+	for rows.Next() {
+		var alias TagAlias
+		if err := rows.Scan(&alias.Alias, &alias.Tag); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases, rows.Err()
+}
+
+// MergeTag makes source an alias of target. Users with the source tag get
+// the target tag instead, the source's aliases follow it, and preferences
+// and saved searches requiring source require target. Target is created if
+// needed; when it is itself an alias, source is merged into its tag. Both
+// names must be normalized. It returns the name source now resolves to and
+// the number of users whose tag was moved.
+func (s *Store) MergeTag(ctx context.Context, source, target string) (string, int, error) {
+	var moved int
+	err := s.WithTx(ctx, func(tx *Store) error {
+		if err := tx.q.QueryRowContext(ctx, "SELECT canonical_tag_name($1)", target).Scan(&target); err != nil {
+			return err
+		}
+		if target == source {
+			return ErrSameTag
+		}
+
+		var targetID int
+		err := tx.q.QueryRowContext(ctx, `
+			INSERT INTO tags (name) VALUES ($1)
+			ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+			RETURNING id
+		`, target).Scan(&targetID)
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.q.ExecContext(ctx, `
+			INSERT INTO user_tags (user_id, tag_id)
+			SELECT ut.user_id, $2 FROM user_tags ut JOIN tags t ON t.id = ut.tag_id WHERE t.name = $1
+			ON CONFLICT DO NOTHING
+		`, source, targetID)
+		if err != nil {
+			return err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		moved = int(affected)
+
+		_, err = tx.q.ExecContext(ctx, `
+			UPDATE tag_aliases SET tag_id = $2
+			WHERE tag_id = (SELECT id FROM tags WHERE name = $1)
+		`, source, targetID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.q.ExecContext(ctx, "DELETE FROM tags WHERE name = $1", source); err != nil {
+			return err
+		}
+		_, err = tx.q.ExecContext(ctx, `
+			INSERT INTO tag_aliases (alias, tag_id) VALUES ($1, $2)
+			ON CONFLICT (alias) DO UPDATE SET tag_id = EXCLUDED.tag_id
+		`, source, targetID)
+		if err != nil {
+			return err
+		}
+
+		for _, table := range []struct{ name, column string }{
+			{"user_preferences", "required_tags"},
+			{"saved_searches", "tags"},
+		} {
+			_, err = tx.q.ExecContext(ctx, `
+				UPDATE `+table.name+`
+				SET `+table.column+` = ARRAY(SELECT DISTINCT UNNEST(ARRAY_REPLACE(`+table.column+`, $1, $2)))
+				WHERE $1 = ANY(`+table.column+`)
+			`, source, target)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return target, moved, err
+}
+
+// DeleteTagAlias removes an alias. Tags merged through it stay merged.
+func (s *Store) DeleteTagAlias(ctx context.Context, alias string) error {
+	result, err := s.q.ExecContext(ctx, "DELETE FROM tag_aliases WHERE alias = $1", alias)
+	if err != nil {
+		return err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// uploadsURLPrefix is how stored paths and avatar URLs refer to files in the
+// uploads directory.
+const uploadsURLPrefix = "/uploads/"
+
+// referencedUploads returns the file names under /uploads/ still used by an
+// image or an avatar.
+func (s *Store) referencedUploads(ctx context.Context) (map[string]bool, error) {
+	rows, err := s.q.QueryContext(ctx, `
+		SELECT path FROM user_images
+		UNION
+		SELECT avatar_url FROM users WHERE avatar_url IS NOT NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	referenced := make(map[string]bool)
+	for rows.Next() {
+		var ref string
+		if err := rows.Scan(&ref); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(ref, uploadsURLPrefix) {
+			referenced[path.Base(ref)] = true
+		}
+	}
+	return referenced, rows.Err()
+}
+
+// OrphanedUploads lists the files in dir that no image or avatar references
+// and that were last modified at least minAge ago. The upload handler writes
+// the file before inserting its row, so a recent file may not be referenced
+// yet.
+func (s *Store) OrphanedUploads(ctx context.Context, dir string, minAge time.Duration) ([]string, error) {
+	referenced, err := s.referencedUploads(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-minAge)
+	orphans := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || referenced[entry.Name()] {
+			continue
+		}
+		info, err := entry.Info()
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if info.ModTime().After(cutoff) {
+			continue
+		}
+		orphans = append(orphans, filepath.Join(dir, entry.Name()))
+	}
+	return orphans, nil
+}
+
+// PurgeOrphanedUploads deletes the files returned by OrphanedUploads and
+// returns the paths it removed, including on error.
+func (s *Store) PurgeOrphanedUploads(ctx context.Context, dir string, minAge time.Duration) ([]string, error) {
+	orphans, err := s.OrphanedUploads(ctx, dir, minAge)
+	if err != nil {
+		return nil, err
+	}
+	for i, file := range orphans {
+		if err := os.Remove(file); err != nil {
+			return orphans[:i], err
+		}
+	}
+	return orphans, nil
+}
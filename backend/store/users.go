@@ -0,0 +1,124 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// User is the account view used by support tooling. It carries account state
+// (verification, session, ban) rather than profile fields.
+type User struct {
+	ID         int        `json:"id"`
+	Username   string     `json:"username"`
+	Email      string     `json:"email"`
+	FirstName  string     `json:"first_name"`
+	LastName   string     `json:"last_name"`
+	Verified   bool       `json:"verified"`
+	HasSession bool       `json:"has_session"`
+	FameRating float64    `json:"fame_rating"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeen   *time.Time `json:"last_seen"`
+	BannedAt   *time.Time `json:"banned_at"`
+	BanReason  *string    `json:"ban_reason"`
+	IsAdmin    bool       `json:"is_admin"`
+}
+
+const userColumns = `
+	id, username, email, first_name, last_name, verified,
+	session_token IS NOT NULL, COALESCE(fame_rating, 0), created_at, last_seen,
+	banned_at, ban_reason, is_admin`
+
+func scanUser(row *sql.Row) (*User, error) {
+	var user User
+	var lastSeen, bannedAt sql.NullTime
+	var banReason sql.NullString
+	err := row.Scan(&user.ID, &user.Username, &user.Email, &user.FirstName, &user.LastName, &user.Verified,
+		&user.HasSession, &user.FameRating, &user.CreatedAt, &lastSeen,
+		&bannedAt, &banReason, &user.IsAdmin)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lastSeen.Valid {
+		user.LastSeen = &lastSeen.Time
+	}
+	if bannedAt.Valid {
+		user.BannedAt = &bannedAt.Time
+	}
+	if banReason.Valid {
+		user.BanReason = &banReason.String
+	}
+	return &user, nil
+}
+
+// FindUser looks a user up by ID, email (anything containing "@") or
+// username.
+func (s *Store) FindUser(ctx context.Context, ref string) (*User, error) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		return scanUser(s.q.QueryRowContext(ctx, "SELECT"+userColumns+" FROM users WHERE id = $1", id))
+	}
+	if strings.Contains(ref, "@") {
+		return scanUser(s.q.QueryRowContext(ctx, "SELECT"+userColumns+" FROM users WHERE LOWER(email) = LOWER($1)", ref))
+	}
+	return scanUser(s.q.QueryRowContext(ctx, "SELECT"+userColumns+" FROM users WHERE username = $1", ref))
+}
+
+// execOnUser runs an UPDATE on a single user and reports ErrNotFound when no
+// row matched.
+func (s *Store) execOnUser(ctx context.Context, query string, args ...interface{}) error {
+	result, err := s.q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// VerifyUser marks the email of a user as verified without the email link.
+func (s *Store) VerifyUser(ctx context.Context, userID int) error {
+	return s.execOnUser(ctx, "UPDATE users SET verified = TRUE, verification_token = NULL WHERE id = $1", userID)
+}
+
+// SetPasswordHash replaces the password of a user, invalidates pending reset
+// tokens and logs the user out.
+func (s *Store) SetPasswordHash(ctx context.Context, userID int, passwordHash string) error {
+	return s.execOnUser(ctx, `
+		UPDATE users
+		SET password_hash = $1, reset_token = NULL, reset_token_expires_at = NULL, session_token = NULL
+		WHERE id = $2
+	`, passwordHash, userID)
+}
+
+// RevokeSessions logs a user out everywhere.
+func (s *Store) RevokeSessions(ctx context.Context, userID int) error {
+	return s.execOnUser(ctx, "UPDATE users SET session_token = NULL WHERE id = $1", userID)
+}
+
+// BanUser blocks a user from logging in and ends their current session.
+func (s *Store) BanUser(ctx context.Context, userID int, reason string) error {
+	return s.execOnUser(ctx, `
+		UPDATE users
+		SET banned_at = NOW(), ban_reason = NULLIF($1, ''), session_token = NULL
+		WHERE id = $2
+	`, reason, userID)
+}
+
+func (s *Store) UnbanUser(ctx context.Context, userID int) error {
+	return s.execOnUser(ctx, "UPDATE users SET banned_at = NULL, ban_reason = NULL WHERE id = $1", userID)
+}
+
+// SetAdmin grants or revokes access to the /admin API.
+func (s *Store) SetAdmin(ctx context.Context, userID int, admin bool) error {
+	return s.execOnUser(ctx, "UPDATE users SET is_admin = $1 WHERE id = $2", admin, userID)
+}
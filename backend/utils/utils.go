@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"crypto/rand"
 	"database/sql"
 	"encoding/base64"
@@ -93,10 +94,10 @@ func GenerateToken() string {
 	return base64.URLEncoding.EncodeToString(token)
 }
 
-func SendVerificationEmail(email string, db *sql.DB) {
+func SendVerificationEmail(ctx context.Context, email string, db *sql.DB) {
 	token := GenerateToken()
 
-	_, err := db.Exec("UPDATE users SET verification_token = $1 WHERE email = $2", token, email)
+	_, err := db.ExecContext(ctx, "UPDATE users SET verification_token = $1 WHERE email = $2", token, email)
 	if err != nil {
 		log.Printf("Error updating verification token: %v", err)
 		return
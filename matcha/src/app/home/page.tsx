@@ -8,8 +8,6 @@ import { useCurrentUser } from "../../hooks/useCurrentUser";
 import { getImageUrl } from "../../services/api";
 import Link from "next/link";
 
-type OrientationStr = "likes men" | "likes women" | "likes men and women";
-
 const today = new Date();
 function ageFromBirthdate(d: Date) {
   const a = today.getFullYear() - d.getFullYear();
@@ -21,27 +19,6 @@ function normalizeTag(t: string) {
   return t.trim().replace(/^#/, "").toLowerCase();
 }
 
-function parseOrientation(pref?: string): OrientationStr {
-  if (!pref) return "likes men and women";
-  const p = pref.toLowerCase().trim();
-
-  if (p === "likes men and women" || p === "both" || p === "likes both") {
-    return "likes men and women";
-  }
-  if (p === "likes men" || p === "men") {
-    return "likes men";
-  }
-  if (p === "likes women" || p === "women") {
-    return "likes women";
-  }
-
-  if (p.endsWith("men and women")) return "likes men and women";
-  if (p.endsWith("women")) return "likes women";
-  if (p.endsWith("men")) return "likes men";
-
-  return "likes men and women";
-}
-
 type SortKey = "best" | "age" | "distance" | "fame";
 
 type Query = {
@@ -126,6 +103,8 @@ export default function SuggestedProfilesPage() {
     minFame: query.minFame,
     maxDistance: query.maxDistanceKm,
     tags: query.selectedTags,
+    sort: query.sortBy === "best" ? "match" : query.sortBy,
+    order: query.sortDir,
   });
 
   const allProfiles = useMemo(() => {
@@ -143,47 +122,9 @@ export default function SuggestedProfilesPage() {
   const displayedProfiles = useMemo(() => {
     if (!currentUser) return [];
 
-    const base = allProfiles.filter((p) => p.id !== currentUser.id);
-
-    const enriched = base.map((p) => {
-      const score = p.matchScore ?? 0;
-      const distanceKm = p.distanceKm ?? Infinity;
-      const commonTags = p.commonTags ?? 0;
-
-      return {
-        profile: p,
-        score,
-        age: ageFromBirthdate(p.birthdate),
-        distanceKm,
-        commonTags,
-      };
-    });
-
-    const sorted = [...enriched].sort((a, b) => {
-      const mult = query.sortDir === "asc" ? 1 : -1;
-      let diff = 0;
-
-      switch (query.sortBy) {
-        case "age":
-          diff = a.age - b.age;
-          break;
-        case "distance":
-          diff = a.distanceKm - b.distanceKm;
-          break;
-        case "fame":
-          diff = a.profile.fameRating - b.profile.fameRating;
-          break;
-        default:
-          diff = a.score - b.score;
-          break;
-      }
-
-      if (diff !== 0) return mult * diff;
-      return (a.profile.id ?? 0) - (b.profile.id ?? 0);
-    });
-
-    return sorted;
-  }, [query, allProfiles, currentUser]);
+    // Profiles are already sorted by the server.
+    return allProfiles.filter((p) => p.id !== currentUser.id);
+  }, [allProfiles, currentUser]);
 
   const handleInputChange = (field: keyof Query, value: unknown) => {
     setQueryInput((prev) => {
@@ -411,7 +352,7 @@ export default function SuggestedProfilesPage() {
                     <div>
                       <h2 className="card-title text-xl">
                         {profile.firstName}, {age}
-                        {profile.gender === "Man" ? " ♂" : " ♀"}
+                        {profile.gender === "Man" ? " ♂" : profile.gender === "Woman" ? " ♀" : ""}
                       </h2>
                       <p className="text-sm opacity-60">
                         {Number.isFinite(distanceKm)
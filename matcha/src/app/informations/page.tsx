@@ -6,12 +6,14 @@ import PicturesPicker from "../../utils/PicturePickers";
 import { useRouter } from "next/navigation";
 import api from "../../services/api";
 import { useCurrentUser } from "../../hooks/useCurrentUser";
+import { useGenders } from "../../hooks/useGenders";
 
 const MAX_BIO_LENGTH = 500;
 
 export default function InformationsPage() {
   const router = useRouter();
   const { currentUser, loading: authLoading } = useCurrentUser();
+  const genders = useGenders();
   const [interests, setInterests] = useState<string[]>([]);
   const [pictures, setPictures] = useState<File[]>([]);
   const [profilePicIdx, setProfilePicIdx] = useState<number>(0);
@@ -191,8 +193,9 @@ export default function InformationsPage() {
               className="select w-full bg-white/80 rounded-xl border-0"
               onChange={(e) => setGender(e.target.value)}
             >
-              <option>Woman</option>
-              <option>Man</option>
+              {genders.map((name) => (
+                <option key={name}>{name}</option>
+              ))}
             </select>
           </fieldset>
 
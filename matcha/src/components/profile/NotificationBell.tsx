@@ -166,6 +166,8 @@ export default function NotificationBell() {
                     case "view":
                     case "match":
                     case "unlike":
+                    case "saved_search":
+                    case "daily_picks":
                       return `/home/profile/${notif.source_id}`;
                     default:
                       return null;
@@ -1,4 +1,5 @@
 import React from "react";
+import { useGenders } from "../../../hooks/useGenders";
 
 interface GenderEditorProps {
   value: string;
@@ -6,14 +7,17 @@ interface GenderEditorProps {
 }
 
 export default function GenderEditor({ value, onChange }: GenderEditorProps) {
+  const genders = useGenders();
+
   return (
     <select
       className="select flex items-center gap-2 px-3 py-2 bg-primary-content transition-all duration-150 w-full relative"
       value={value}
       onChange={(e) => onChange(e.target.value)}
     >
-      <option>Woman</option>
-      <option>Man</option>
+      {genders.map((gender) => (
+        <option key={gender}>{gender}</option>
+      ))}
     </select>
   );
 }
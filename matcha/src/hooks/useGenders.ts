@@ -0,0 +1,19 @@
+import { useState, useEffect } from 'react';
+import api from '../services/api';
+
+// Genders offered until the list is loaded.
+const DEFAULT_GENDERS = ["Woman", "Man"];
+
+export function useGenders() {
+  const [genders, setGenders] = useState<string[]>(DEFAULT_GENDERS);
+
+  useEffect(() => {
+    api.getGenders().then((result) => {
+      if (!result.error && result.data?.genders?.length) {
+        setGenders(result.data.genders);
+      }
+    });
+  }, []);
+
+  return genders;
+}
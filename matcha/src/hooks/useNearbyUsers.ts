@@ -8,7 +8,6 @@ export interface NearbyUser {
   latitude: number;
   longitude: number;
   accuracy?: number;
-  updated_at: string;
   distance_km: number;
 }
 
@@ -10,6 +10,8 @@ export function useProfiles(filters?: {
   maxFame?: number;
   maxDistance?: number;
   tags?: string[];
+  sort?: "match" | "distance" | "age" | "fame" | "common_tags";
+  order?: "asc" | "desc";
 }) {
   const [profiles, setProfiles] = useState<BackendUserProfile[]>([]);
   const [loading, setLoading] = useState(true);
@@ -153,6 +153,12 @@ class ApiService {
     return this.requestForm("/auth/reset-password", formData);
   }
 
+  async getGenders(): Promise<ApiResponse<{ genders: string[] }>> {
+    return this.request("/genders", {
+      method: "GET",
+    });
+  }
+
   async getTags(): Promise<
     ApiResponse<{ tags: Array<{ id: number; name: string }> }>
   > {
@@ -189,8 +195,9 @@ class ApiService {
       location: {
         latitude: number;
         longitude: number;
-        accuracy?: number;
-        updated_at: string;
+        accuracy?: number | null;
+        source?: string | null;
+        updated_at?: string | null;
       };
     }>
   > {
@@ -211,7 +218,6 @@ class ApiService {
         latitude: number;
         longitude: number;
         accuracy?: number;
-        updated_at: string;
         distance_km: number;
       }>;
       count: number;
@@ -289,6 +295,7 @@ class ApiService {
       last_name: string;
       gender?: string;
       orientation?: string;
+      interested_in?: string[];
       birthday?: string;
       bio?: string;
       avatar_url?: string;
@@ -337,6 +344,8 @@ class ApiService {
     maxFame?: number;
     maxDistance?: number;
     tags?: string[];
+    sort?: "match" | "distance" | "age" | "fame" | "common_tags";
+    order?: "asc" | "desc";
   }): Promise<
     ApiResponse<{
       users: Array<{
@@ -373,6 +382,8 @@ class ApiService {
         params.append("maxDistance", filters.maxDistance.toString());
       if (filters.tags && filters.tags.length > 0)
         params.append("tags", filters.tags.join(","));
+      if (filters.sort) params.append("sort", filters.sort);
+      if (filters.order) params.append("order", filters.order);
 
       const queryString = params.toString();
       if (queryString) {
@@ -394,6 +405,7 @@ class ApiService {
       last_name: string;
       gender?: string;
       orientation?: string;
+      interested_in?: string[];
       birthday?: string;
       bio?: string;
       avatar_url?: string;
@@ -410,6 +422,7 @@ class ApiService {
 
   async updateProfile(data: {
     gender?: string;
+    interested_in?: string[];
     orientation?: string;
     bio?: string;
     first_name?: string;
@@ -553,5 +566,5 @@ class ApiService {
   }
 }
 
-export const api = new ApiService(API_BASE_URL);
+export const api = new ApiService(`${API_BASE_URL}/v1`);
 export default api;
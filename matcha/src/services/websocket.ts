@@ -31,8 +31,8 @@ class WebSocketService {
 
     const token = getSessionToken();
     const wsUrl = token
-      ? `${protocol}//localhost:8080/ws?token=${encodeURIComponent(token)}`
-      : `${protocol}//localhost:8080/ws`;
+      ? `${protocol}//localhost:8080/v1/ws?token=${encodeURIComponent(token)}`
+      : `${protocol}//localhost:8080/v1/ws`;
 
     try {
       this.ws = new WebSocket(wsUrl);
@@ -35,6 +35,7 @@ export interface BackendUserProfile {
   email?: string;
   gender?: string;
   orientation?: string;
+  interested_in?: string[];
   birthday?: string;
   bio?: string;
   avatar_url?: string;
@@ -86,7 +87,11 @@ export function backendUserToProfile(backendUser: BackendUserProfile): Profile {
     username: backendUser.username,
     images,
     gender: backendUser.gender || "Not specified",
-    preferences: backendUser.orientation || "likes men and women",
+    preferences:
+      backendUser.orientation ||
+      (backendUser.interested_in?.length
+        ? "likes " + backendUser.interested_in.join(", ")
+        : "likes men and women"),
     bio: backendUser.bio || "No bio yet",
     interests: backendUser.tags || [],
     birthdate: backendUser.birthday